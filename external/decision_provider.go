@@ -1,21 +1,41 @@
 package external
 
-import "math/rand"
+import (
+	"math/rand"
+	"sync"
+)
 
 type decisionProvider interface {
 	TrueFalse(chance int) bool
 }
 
-type randomDecisionProvider struct{}
+// Draws decisions from a source of its own, seeded explicitly, so the same seed replays the same
+// decisions. Safe for concurrent use.
+type randomDecisionProvider struct {
+	sync.Mutex
+	seed   int64
+	random *rand.Rand
+}
 
 func (p *randomDecisionProvider) TrueFalse(chance int) bool {
 	if chance == 0 {
 		return false
 	}
-	randomInt := rand.Int()
+
+	p.Lock()
+	randomInt := p.random.Int()
+	p.Unlock()
+
 	return randomInt%chance == 0
 }
 
-func newDecisionProvider() decisionProvider {
-	return &randomDecisionProvider{}
+func (p *randomDecisionProvider) Seed() int64 {
+	return p.seed
+}
+
+func newDecisionProvider(seed int64) *randomDecisionProvider {
+	return &randomDecisionProvider{
+		seed:   seed,
+		random: rand.New(rand.NewSource(seed)),
+	}
 }
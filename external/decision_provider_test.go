@@ -2,13 +2,14 @@ package external
 
 import (
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
 )
 
 func TestRandomDecisionProvider(t *testing.T) {
 
 	setup := func() *randomDecisionProvider {
-		return &randomDecisionProvider{}
+		return newDecisionProvider(1)
 	}
 
 	t.Run("TrueFalse", func(t *testing.T) {
@@ -55,5 +56,55 @@ func TestRandomDecisionProvider(t *testing.T) {
 			assert.True(t, trueCounter > 3000)
 			assert.True(t, trueCounter < 4000)
 		})
+
+		t.Run("when providers have the same seed they return the same decisions", func(t *testing.T) {
+			decisionProvider := newDecisionProvider(42)
+			replayProvider := newDecisionProvider(42)
+			otherProvider := newDecisionProvider(43)
+
+			decisions := make([]bool, 0)
+			replayedDecisions := make([]bool, 0)
+			otherDecisions := make([]bool, 0)
+			for i := 0; i < 100; i++ {
+				decisions = append(decisions, decisionProvider.TrueFalse(2))
+				replayedDecisions = append(replayedDecisions, replayProvider.TrueFalse(2))
+				otherDecisions = append(otherDecisions, otherProvider.TrueFalse(2))
+			}
+
+			assert.Equal(t, decisions, replayedDecisions)
+			assert.NotEqual(t, decisions, otherDecisions)
+			assert.Equal(t, int64(42), decisionProvider.Seed())
+		})
+
+		t.Run("when used concurrently it returns every decision of its seed", func(t *testing.T) {
+			decisionProvider := newDecisionProvider(7)
+			replayProvider := newDecisionProvider(7)
+
+			trueCounter := 0
+			var counterLock sync.Mutex
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						if decisionProvider.TrueFalse(3) {
+							counterLock.Lock()
+							trueCounter++
+							counterLock.Unlock()
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			replayedTrueCounter := 0
+			for i := 0; i < 1000; i++ {
+				if replayProvider.TrueFalse(3) {
+					replayedTrueCounter++
+				}
+			}
+			assert.Equal(t, replayedTrueCounter, trueCounter)
+		})
 	})
 }
@@ -0,0 +1,352 @@
+package external
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/Zedronar/go-dummy-app.git/external/metrics"
+)
+
+const (
+	metricNameEventBusDroppedEvents = "eventbus.dropped_events"
+	metricNameEventBusDisconnects   = "eventbus.disconnects"
+
+	DefaultEventBusQueueSize = 256
+)
+
+// Every topic carries a single type of event
+type Topic string
+
+const (
+	TopicScoreUpdate         Topic = "score_update"
+	TopicWinningTeamUpdate   Topic = "winning_team_update"
+	TopicFixtureStatusUpdate Topic = "fixture_status_update"
+	TopicPlacementUpdate     Topic = "placement_update"
+)
+
+// What publishing does when a subscriber's queue is full
+type OverflowPolicy int
+
+const (
+	// Wait until there's room in the queue, nothing is lost but the publisher is held back
+	OverflowBlock OverflowPolicy = iota
+	// Discard the oldest queued event to make room for the new one
+	OverflowDropOldest
+	// Discard the new event
+	OverflowDropNewest
+	// Discard the new event and unsubscribe the subscriber
+	OverflowDisconnect
+)
+
+type SubscriptionOptions struct {
+	// Defaults to the bus' queue size
+	QueueSize      int
+	OverflowPolicy OverflowPolicy
+}
+
+// Delivers the live updates to their subscribers.
+// Every subscriber has its own goroutine and bounded queue, so a slow subscriber doesn't delay the others.
+// What happens once a subscriber's queue is full depends on its overflow policy. Events are delivered in publish order.
+type EventBus struct {
+	sync.RWMutex
+	subscriptions map[Topic]map[*Subscription]struct{}
+	queueSize     int
+	closed        bool
+}
+
+// Handle returned by Subscribe, used to unsubscribe
+type Subscription struct {
+	// Events discarded because of the overflow policy, read it with Dropped()
+	dropped uint64
+
+	bus            *EventBus
+	topics         []Topic
+	handler        func(topic Topic, event interface{})
+	queue          chan queuedEvent
+	overflowPolicy OverflowPolicy
+
+	// Closed by Unsubscribe (stop right away) and Close (deliver the queued events first)
+	stop     chan struct{}
+	drain    chan struct{}
+	stopOnce sync.Once
+
+	// Closed once the subscriber's goroutine has returned
+	done chan struct{}
+}
+
+// Events are queued along with their topic, which tells subscribers to several topics what they received
+type queuedEvent struct {
+	topic Topic
+	event interface{}
+}
+
+func NewEventBus(queueSize int) *EventBus {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	return &EventBus{
+		subscriptions: make(map[Topic]map[*Subscription]struct{}),
+		queueSize:     queueSize,
+	}
+}
+
+func (b *EventBus) SubscribeScoreUpdates(receiver ScoreUpdateReceiver, options SubscriptionOptions) *Subscription {
+	return b.subscribe([]Topic{TopicScoreUpdate}, options, func(_ Topic, event interface{}) {
+		receiver.Receive(event.(ScoreUpdate))
+	})
+}
+
+func (b *EventBus) SubscribeWinningTeamUpdates(receiver WinningTeamUpdateReceiver, options SubscriptionOptions) *Subscription {
+	return b.subscribe([]Topic{TopicWinningTeamUpdate}, options, func(_ Topic, event interface{}) {
+		receiver.Receive(event.(WinningTeamUpdate))
+	})
+}
+
+func (b *EventBus) SubscribeFixtureStatusUpdates(receiver FixtureStatusUpdateReceiver, options SubscriptionOptions) *Subscription {
+	return b.subscribe([]Topic{TopicFixtureStatusUpdate}, options, func(_ Topic, event interface{}) {
+		receiver.Receive(event.(FixtureStatusUpdate))
+	})
+}
+
+func (b *EventBus) SubscribePlacementUpdates(receiver PlacementUpdateReceiver, options SubscriptionOptions) *Subscription {
+	return b.subscribe([]Topic{TopicPlacementUpdate}, options, func(_ Topic, event interface{}) {
+		receiver.Receive(event.(PlacementUpdate))
+	})
+}
+
+// Subscribe to score, placement, winner and status updates through a single queue, so they're received in
+// the order they were published (e.g. a fixture going live before its first score, and its winner after
+// its last score)
+func (b *EventBus) SubscribeLiveUpdates(
+	scoreUpdateReceiver ScoreUpdateReceiver,
+	placementUpdateReceiver PlacementUpdateReceiver,
+	winningTeamUpdateReceiver WinningTeamUpdateReceiver,
+	fixtureStatusUpdateReceiver FixtureStatusUpdateReceiver,
+	options SubscriptionOptions) *Subscription {
+	topics := []Topic{TopicScoreUpdate, TopicPlacementUpdate, TopicWinningTeamUpdate, TopicFixtureStatusUpdate}
+	return b.subscribe(topics, options, func(topic Topic, event interface{}) {
+		// Dispatched on the topic rather than the event's type, which can satisfy several update interfaces
+		switch topic {
+		case TopicScoreUpdate:
+			scoreUpdateReceiver.Receive(event.(ScoreUpdate))
+		case TopicPlacementUpdate:
+			placementUpdateReceiver.Receive(event.(PlacementUpdate))
+		case TopicWinningTeamUpdate:
+			winningTeamUpdateReceiver.Receive(event.(WinningTeamUpdate))
+		case TopicFixtureStatusUpdate:
+			fixtureStatusUpdateReceiver.Receive(event.(FixtureStatusUpdate))
+		}
+	})
+}
+
+func (b *EventBus) PublishScoreUpdate(update ScoreUpdate) {
+	b.publish(TopicScoreUpdate, update)
+}
+
+func (b *EventBus) PublishWinningTeamUpdate(update WinningTeamUpdate) {
+	b.publish(TopicWinningTeamUpdate, update)
+}
+
+func (b *EventBus) PublishFixtureStatusUpdate(update FixtureStatusUpdate) {
+	b.publish(TopicFixtureStatusUpdate, update)
+}
+
+func (b *EventBus) PublishPlacementUpdate(update PlacementUpdate) {
+	b.publish(TopicPlacementUpdate, update)
+}
+
+// Stop delivering events to the subscription. The queued events are discarded, the one being delivered
+// (if any) isn't interrupted. It's safe to call more than once, and from the subscriber itself.
+func (b *EventBus) Unsubscribe(subscription *Subscription) {
+	b.Lock()
+	for _, topic := range subscription.topics {
+		if subscribers, found := b.subscriptions[topic]; found {
+			delete(subscribers, subscription)
+		}
+	}
+	b.Unlock()
+
+	subscription.stopOnce.Do(func() {
+		close(subscription.stop)
+	})
+}
+
+func (s *Subscription) Unsubscribe() {
+	s.bus.Unsubscribe(s)
+}
+
+// Number of events discarded because the queue was full
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Closed once no more events will be delivered (after Unsubscribe, Close or a disconnect)
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Stop accepting events and wait for the subscribers to receive the queued ones
+func (b *EventBus) Close() {
+	b.Lock()
+	b.closed = true
+	subscriptions := make(map[*Subscription]struct{})
+	for topic, subscribers := range b.subscriptions {
+		for subscription := range subscribers {
+			subscriptions[subscription] = struct{}{}
+		}
+		delete(b.subscriptions, topic)
+	}
+	b.Unlock()
+
+	for subscription := range subscriptions {
+		close(subscription.drain)
+	}
+	for subscription := range subscriptions {
+		<-subscription.done
+	}
+}
+
+// Number of subscriptions to the topic
+func (b *EventBus) subscriberCount(topic Topic) int {
+	b.RLock()
+	defer b.RUnlock()
+
+	return len(b.subscriptions[topic])
+}
+
+func (b *EventBus) subscribe(topics []Topic, options SubscriptionOptions, handler func(topic Topic, event interface{})) *Subscription {
+	queueSize := options.QueueSize
+	if queueSize < 1 {
+		queueSize = b.queueSize
+	}
+
+	subscription := &Subscription{
+		bus:            b,
+		topics:         topics,
+		handler:        handler,
+		queue:          make(chan queuedEvent, queueSize),
+		overflowPolicy: options.OverflowPolicy,
+		stop:           make(chan struct{}),
+		drain:          make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	// Subscribing to a closed bus returns a subscription that never receives anything
+	if b.closed {
+		close(subscription.done)
+		return subscription
+	}
+
+	for _, topic := range topics {
+		subscribers, found := b.subscriptions[topic]
+		if !found {
+			subscribers = make(map[*Subscription]struct{})
+			b.subscriptions[topic] = subscribers
+		}
+		subscribers[subscription] = struct{}{}
+	}
+
+	go subscription.deliver()
+
+	return subscription
+}
+
+func (b *EventBus) publish(topic Topic, event interface{}) {
+	// Enqueue outside of the lock, so a full queue doesn't block (un)subscribing
+	b.RLock()
+	if b.closed {
+		b.RUnlock()
+		return
+	}
+	subscriptions := make([]*Subscription, 0, len(b.subscriptions[topic]))
+	for subscription := range b.subscriptions[topic] {
+		subscriptions = append(subscriptions, subscription)
+	}
+	b.RUnlock()
+
+	for _, subscription := range subscriptions {
+		subscription.enqueue(queuedEvent{topic: topic, event: event})
+	}
+}
+
+func (s *Subscription) enqueue(event queuedEvent) {
+	select {
+	case s.queue <- event:
+		return
+	default:
+	}
+
+	// The queue is full
+	switch s.overflowPolicy {
+	case OverflowDropOldest:
+		// The subscriber may take events in the meantime, so only drop one when there's still no room
+		for {
+			select {
+			case s.queue <- event:
+				return
+			default:
+			}
+			select {
+			case <-s.queue:
+				s.drop()
+			default:
+			}
+		}
+	case OverflowDropNewest:
+		s.drop()
+	case OverflowDisconnect:
+		s.drop()
+		s.bus.Unsubscribe(s)
+		metrics.Increment(metricNameEventBusDisconnects)
+	default:
+		select {
+		case s.queue <- event:
+		case <-s.stop:
+		case <-s.done:
+		}
+	}
+}
+
+func (s *Subscription) drop() {
+	atomic.AddUint64(&s.dropped, 1)
+	metrics.Increment(metricNameEventBusDroppedEvents)
+}
+
+func (s *Subscription) deliver() {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.drain:
+			s.deliverQueued()
+			return
+		case queued := <-s.queue:
+			// Unsubscribing wins over the events still queued
+			select {
+			case <-s.stop:
+				return
+			default:
+			}
+			s.handler(queued.topic, queued.event)
+		}
+	}
+}
+
+func (s *Subscription) deliverQueued() {
+	for {
+		select {
+		case <-s.stop:
+			return
+		case queued := <-s.queue:
+			s.handler(queued.topic, queued.event)
+		default:
+			return
+		}
+	}
+}
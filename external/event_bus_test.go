@@ -0,0 +1,330 @@
+package external
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Signals every received update, and can be held to simulate a slow subscriber
+type blockingScoreUpdateReceiver struct {
+	entered  chan ScoreUpdate
+	received chan ScoreUpdate
+	release  chan struct{}
+}
+
+func newBlockingScoreUpdateReceiver() *blockingScoreUpdateReceiver {
+	return &blockingScoreUpdateReceiver{
+		entered:  make(chan ScoreUpdate, 10),
+		received: make(chan ScoreUpdate, 10),
+		release:  make(chan struct{}),
+	}
+}
+
+func (t *blockingScoreUpdateReceiver) Receive(update ScoreUpdate) {
+	t.entered <- update
+	<-t.release
+	t.received <- update
+}
+
+func (t *blockingScoreUpdateReceiver) receivedScores() []int {
+	scores := make([]int, 0)
+	for len(t.received) > 0 {
+		scores = append(scores, (<-t.received).Score())
+	}
+	return scores
+}
+
+type funcScoreUpdateReceiver struct {
+	receive func(update ScoreUpdate)
+}
+
+func (t *funcScoreUpdateReceiver) Receive(update ScoreUpdate) {
+	t.receive(update)
+}
+
+type funcWinningTeamUpdateReceiver struct {
+	receive func(update WinningTeamUpdate)
+}
+
+func (t *funcWinningTeamUpdateReceiver) Receive(update WinningTeamUpdate) {
+	t.receive(update)
+}
+
+type funcFixtureStatusUpdateReceiver struct {
+	receive func(update FixtureStatusUpdate)
+}
+
+func (t *funcFixtureStatusUpdateReceiver) Receive(update FixtureStatusUpdate) {
+	t.receive(update)
+}
+
+type funcPlacementUpdateReceiver struct {
+	receive func(update PlacementUpdate)
+}
+
+func (t *funcPlacementUpdateReceiver) Receive(update PlacementUpdate) {
+	t.receive(update)
+}
+
+func TestEventBus(t *testing.T) {
+
+	t.Run("when updates are published it should deliver them to every subscriber of the topic in order", func(t *testing.T) {
+		// Arrange
+		bus := NewEventBus(DefaultEventBusQueueSize)
+		first := &testScoreUpdateReceiver{}
+		second := &testScoreUpdateReceiver{}
+		winningTeamReceiver := &testWinningTeamUpdateReceiver{}
+		bus.SubscribeScoreUpdates(first, SubscriptionOptions{})
+		bus.SubscribeScoreUpdates(second, SubscriptionOptions{})
+		bus.SubscribeWinningTeamUpdates(winningTeamReceiver, SubscriptionOptions{})
+
+		// Act
+		for score := 1; score <= 10; score++ {
+			bus.PublishScoreUpdate(&scoreUpdate{fixtureId: "F1", teamId: "TE1", score: score})
+		}
+		bus.Close()
+
+		// Assert
+		for _, receiver := range []*testScoreUpdateReceiver{first, second} {
+			assert.Equal(t, 10, len(receiver.receivedUpdates))
+			for i, update := range receiver.receivedUpdates {
+				assert.Equal(t, i+1, update.Score())
+			}
+		}
+		assert.Equal(t, 0, len(winningTeamReceiver.receivedUpdates))
+	})
+
+	t.Run("when subscribed to live updates it should receive statuses, scores, placements and winners in publish order", func(t *testing.T) {
+		// Arrange
+		bus := NewEventBus(DefaultEventBusQueueSize)
+		received := make([]string, 0)
+		scoreReceiver := &funcScoreUpdateReceiver{receive: func(update ScoreUpdate) {
+			received = append(received, fmt.Sprintf("score %d", update.Score()))
+		}}
+		winningTeamReceiver := &funcWinningTeamUpdateReceiver{receive: func(update WinningTeamUpdate) {
+			received = append(received, "winner "+update.TeamId())
+		}}
+		statusReceiver := &funcFixtureStatusUpdateReceiver{receive: func(update FixtureStatusUpdate) {
+			received = append(received, update.FixtureId()+" "+update.Status())
+		}}
+		placementReceiver := &funcPlacementUpdateReceiver{receive: func(update PlacementUpdate) {
+			received = append(received, fmt.Sprintf("placement %s %d", update.TeamId(), update.Placement()))
+		}}
+		bus.SubscribeLiveUpdates(scoreReceiver, placementReceiver, winningTeamReceiver, statusReceiver, SubscriptionOptions{})
+
+		// Act
+		bus.PublishFixtureStatusUpdate(&fixtureStatusUpdate{fixtureId: "F1", status: FixtureStatusLive})
+		bus.PublishScoreUpdate(&scoreUpdate{fixtureId: "F1", teamId: "TE1", score: 1})
+		bus.PublishPlacementUpdate(&placementUpdate{fixtureId: "F1", teamId: "TE2", placement: 2})
+		bus.PublishPlacementUpdate(&placementUpdate{fixtureId: "F1", teamId: "TE1", placement: 1})
+		bus.PublishWinningTeamUpdate(&winningTeamUpdate{fixtureId: "F1", teamId: "TE1"})
+		bus.PublishFixtureStatusUpdate(&fixtureStatusUpdate{fixtureId: "F1", status: FixtureStatusFinished})
+		bus.PublishScoreUpdate(&scoreUpdate{fixtureId: "F2", teamId: "TE2", score: 2})
+		bus.Close()
+
+		// Assert
+		assert.Equal(t, []string{"F1 live", "score 1", "placement TE2 2", "placement TE1 1", "winner TE1", "F1 finished", "score 2"}, received)
+	})
+
+	t.Run("when subscribed to live updates it should dispatch events on their topic rather than their type", func(t *testing.T) {
+		// Arrange
+		bus := NewEventBus(DefaultEventBusQueueSize)
+		received := make([]string, 0)
+		scoreReceiver := &funcScoreUpdateReceiver{receive: func(update ScoreUpdate) {
+			received = append(received, "score "+update.TeamId())
+		}}
+		winningTeamReceiver := &funcWinningTeamUpdateReceiver{receive: func(update WinningTeamUpdate) {
+			received = append(received, "winner "+update.TeamId())
+		}}
+		bus.SubscribeLiveUpdates(scoreReceiver, &funcPlacementUpdateReceiver{}, winningTeamReceiver, &funcFixtureStatusUpdateReceiver{}, SubscriptionOptions{})
+
+		// Act
+		// A score update also satisfies WinningTeamUpdate
+		bus.PublishWinningTeamUpdate(&scoreUpdate{fixtureId: "F1", teamId: "TE1", score: 1})
+		bus.Close()
+
+		// Assert
+		assert.Equal(t, []string{"winner TE1"}, received)
+	})
+
+	t.Run("when a subscriber unsubscribes it should not receive updates anymore", func(t *testing.T) {
+		// Arrange
+		bus := NewEventBus(DefaultEventBusQueueSize)
+		unsubscribed := &testWinningTeamUpdateReceiver{}
+		subscribed := &testWinningTeamUpdateReceiver{}
+		subscription := bus.SubscribeWinningTeamUpdates(unsubscribed, SubscriptionOptions{})
+		bus.SubscribeWinningTeamUpdates(subscribed, SubscriptionOptions{})
+
+		// Act
+		subscription.Unsubscribe()
+		bus.Unsubscribe(subscription)
+		bus.PublishWinningTeamUpdate(&winningTeamUpdate{fixtureId: "F1", teamId: "TE1"})
+		subscriberCount := bus.subscriberCount(TopicWinningTeamUpdate)
+		bus.Close()
+
+		// Assert
+		assert.Equal(t, 1, subscriberCount)
+		assert.Equal(t, 0, len(unsubscribed.receivedUpdates))
+		assert.Equal(t, 1, len(subscribed.receivedUpdates))
+	})
+
+	t.Run("when a subscriber is slow it should not delay the other subscribers", func(t *testing.T) {
+		// Arrange
+		bus := NewEventBus(DefaultEventBusQueueSize)
+		slow := newBlockingScoreUpdateReceiver()
+		fast := newBlockingScoreUpdateReceiver()
+		close(fast.release)
+		bus.SubscribeScoreUpdates(slow, SubscriptionOptions{})
+		bus.SubscribeScoreUpdates(fast, SubscriptionOptions{})
+
+		// Act
+		bus.PublishScoreUpdate(&scoreUpdate{fixtureId: "F1", teamId: "TE1", score: 1})
+
+		// Assert
+		select {
+		case update := <-fast.received:
+			assert.Equal(t, 1, update.Score())
+		case <-time.After(time.Second):
+			assert.Fail(t, "fast subscriber didn't receive the update")
+		}
+		close(slow.release)
+		bus.Close()
+		assert.Equal(t, 1, (<-slow.received).Score())
+	})
+
+	t.Run("when a subscriber's queue is full it should wait until there's room", func(t *testing.T) {
+		// Arrange
+		bus := NewEventBus(1)
+		slow := newBlockingScoreUpdateReceiver()
+		bus.SubscribeScoreUpdates(slow, SubscriptionOptions{})
+		published := make(chan struct{})
+
+		// Act
+		go func() {
+			for score := 1; score <= 3; score++ {
+				bus.PublishScoreUpdate(&scoreUpdate{fixtureId: "F1", teamId: "TE1", score: score})
+			}
+			close(published)
+		}()
+
+		// Assert
+		select {
+		case <-published:
+			assert.Fail(t, "publishing didn't wait for the full queue")
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(slow.release)
+		<-published
+		bus.Close()
+		assert.Equal(t, 3, len(slow.received))
+	})
+
+	// Publish a first update and wait until the receiver holds it, then publish the others
+	publishToHeldReceiver := func(bus *EventBus, receiver *blockingScoreUpdateReceiver, scores ...int) {
+		bus.PublishScoreUpdate(&scoreUpdate{fixtureId: "F1", teamId: "TE1", score: scores[0]})
+		<-receiver.entered
+		for _, score := range scores[1:] {
+			bus.PublishScoreUpdate(&scoreUpdate{fixtureId: "F1", teamId: "TE1", score: score})
+		}
+	}
+
+	t.Run("when the queue is full and the policy is drop oldest it should discard the oldest queued update", func(t *testing.T) {
+		// Arrange
+		bus := NewEventBus(DefaultEventBusQueueSize)
+		receiver := newBlockingScoreUpdateReceiver()
+		subscription := bus.SubscribeScoreUpdates(receiver, SubscriptionOptions{QueueSize: 2, OverflowPolicy: OverflowDropOldest})
+
+		// Act
+		publishToHeldReceiver(bus, receiver, 1, 2, 3, 4)
+		close(receiver.release)
+		bus.Close()
+
+		// Assert
+		assert.Equal(t, []int{1, 3, 4}, receiver.receivedScores())
+		assert.Equal(t, uint64(1), subscription.Dropped())
+	})
+
+	t.Run("when the queue is full and the policy is drop newest it should discard the new update", func(t *testing.T) {
+		// Arrange
+		bus := NewEventBus(DefaultEventBusQueueSize)
+		receiver := newBlockingScoreUpdateReceiver()
+		subscription := bus.SubscribeScoreUpdates(receiver, SubscriptionOptions{QueueSize: 2, OverflowPolicy: OverflowDropNewest})
+
+		// Act
+		publishToHeldReceiver(bus, receiver, 1, 2, 3, 4, 5)
+		close(receiver.release)
+		bus.Close()
+
+		// Assert
+		assert.Equal(t, []int{1, 2, 3}, receiver.receivedScores())
+		assert.Equal(t, uint64(2), subscription.Dropped())
+	})
+
+	t.Run("when the queue is full and the policy is disconnect it should unsubscribe the subscriber", func(t *testing.T) {
+		// Arrange
+		bus := NewEventBus(DefaultEventBusQueueSize)
+		receiver := newBlockingScoreUpdateReceiver()
+		other := &testScoreUpdateReceiver{}
+		subscription := bus.SubscribeScoreUpdates(receiver, SubscriptionOptions{QueueSize: 2, OverflowPolicy: OverflowDisconnect})
+		bus.SubscribeScoreUpdates(other, SubscriptionOptions{})
+
+		// Act
+		publishToHeldReceiver(bus, receiver, 1, 2, 3, 4)
+		close(receiver.release)
+		<-subscription.Done()
+		bus.PublishScoreUpdate(&scoreUpdate{fixtureId: "F1", teamId: "TE1", score: 5})
+		subscriberCount := bus.subscriberCount(TopicScoreUpdate)
+		bus.Close()
+
+		// Assert
+		assert.Equal(t, []int{1}, receiver.receivedScores())
+		assert.Equal(t, uint64(1), subscription.Dropped())
+		assert.Equal(t, 1, subscriberCount)
+		assert.Equal(t, 5, len(other.receivedUpdates))
+	})
+
+	t.Run("when subscribers are added and removed while publishing it should not race", func(t *testing.T) {
+		// Arrange
+		bus := NewEventBus(DefaultEventBusQueueSize)
+		var wg sync.WaitGroup
+
+		// Act
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				bus.PublishScoreUpdate(&scoreUpdate{fixtureId: "F1", teamId: "TE1", score: i})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				bus.SubscribeScoreUpdates(&testScoreUpdateReceiver{}, SubscriptionOptions{}).Unsubscribe()
+			}
+		}()
+		wg.Wait()
+		bus.Close()
+
+		// Assert
+		assert.Equal(t, 0, bus.subscriberCount(TopicScoreUpdate))
+	})
+
+	t.Run("when the bus is closed it should not deliver new updates", func(t *testing.T) {
+		// Arrange
+		bus := NewEventBus(DefaultEventBusQueueSize)
+		receiver := &testScoreUpdateReceiver{}
+		bus.SubscribeScoreUpdates(receiver, SubscriptionOptions{})
+
+		// Act
+		bus.Close()
+		bus.PublishScoreUpdate(&scoreUpdate{fixtureId: "F1", teamId: "TE1", score: 1})
+		bus.SubscribeScoreUpdates(receiver, SubscriptionOptions{}).Unsubscribe()
+
+		// Assert
+		assert.Equal(t, 0, len(receiver.receivedUpdates))
+	})
+}
@@ -1,46 +1,152 @@
 package external
 
 import (
+	"fmt"
 	"sync"
 	"time"
 )
 
-var receiversLock sync.RWMutex
-var registeredScoreUpdateReceivers []ScoreUpdateReceiver
-var registeredWinningTeamUpdateReceivers []WinningTeamUpdateReceiver
+// Metadata wrapping every live update
+type EventEnvelope struct {
+	// Unique per event, redelivered events keep their id
+	EventId string
+	// Incremented on every event of a fixture by a producer (score and winner updates share it),
+	// so receivers can detect gaps and duplicates. 0 when the producer doesn't sequence its events.
+	Sequence   uint64
+	EmittedAt  time.Time
+	ProducerId string
+	// Set when the event corrects a value already published (e.g. a wrong winner), which would
+	// otherwise be refused
+	Correction bool
+}
+
+func NewEventEnvelope(producerId string, fixtureId string, sequence uint64, emittedAt time.Time) EventEnvelope {
+	return EventEnvelope{
+		EventId:    fmt.Sprintf("%s/%s/%d", producerId, fixtureId, sequence),
+		Sequence:   sequence,
+		EmittedAt:  emittedAt,
+		ProducerId: producerId,
+	}
+}
+
+// Fixture lifecycle: scheduled -> live -> finished, scheduled or live fixtures can also be postponed
+// (and scheduled again) or cancelled
+const (
+	FixtureStatusScheduled = "scheduled"
+	FixtureStatusLive      = "live"
+	FixtureStatusFinished  = "finished"
+	FixtureStatusPostponed = "postponed"
+	FixtureStatusCancelled = "cancelled"
+)
+
+type FixtureStatusUpdateReceiver interface {
+	Receive(update FixtureStatusUpdate)
+}
 
-func RegisterScoreUpdateReceivers(receivers ...ScoreUpdateReceiver) {
-	receiversLock.Lock()
-	defer receiversLock.Unlock()
+type FixtureStatusUpdate interface {
+	Envelope() EventEnvelope
+	FixtureId() string
+	Status() string
+}
+
+type fixtureStatusUpdate struct {
+	envelope  EventEnvelope
+	fixtureId string
+	status    string
+}
 
-	for _, receiver := range receivers {
-		registeredScoreUpdateReceivers = append(registeredScoreUpdateReceivers, receiver)
+func NewFixtureStatusUpdate(envelope EventEnvelope, fixtureId string, status string) FixtureStatusUpdate {
+	return &fixtureStatusUpdate{
+		envelope:  envelope,
+		fixtureId: fixtureId,
+		status:    status,
 	}
 }
 
-func RegisterWinningTeamUpdateReceivers(receivers ...WinningTeamUpdateReceiver) {
-	receiversLock.Lock()
-	defer receiversLock.Unlock()
+func (u *fixtureStatusUpdate) Envelope() EventEnvelope {
+	return u.envelope
+}
+
+func (u *fixtureStatusUpdate) FixtureId() string {
+	return u.fixtureId
+}
+
+func (u *fixtureStatusUpdate) Status() string {
+	return u.status
+}
+
+type PlacementUpdateReceiver interface {
+	Receive(update PlacementUpdate)
+}
+
+// Final placement of a team in a free-for-all fixture, 1 for the last team standing
+type PlacementUpdate interface {
+	Envelope() EventEnvelope
+	FixtureId() string
+	TeamId() string
+	Placement() int
+}
+
+type placementUpdate struct {
+	envelope  EventEnvelope
+	fixtureId string
+	teamId    string
+	placement int
+}
 
-	for _, receiver := range receivers {
-		registeredWinningTeamUpdateReceivers = append(registeredWinningTeamUpdateReceivers, receiver)
+func NewPlacementUpdate(envelope EventEnvelope, fixtureId string, teamId string, placement int) PlacementUpdate {
+	return &placementUpdate{
+		envelope:  envelope,
+		fixtureId: fixtureId,
+		teamId:    teamId,
+		placement: placement,
 	}
 }
 
+func (u *placementUpdate) Envelope() EventEnvelope {
+	return u.envelope
+}
+
+func (u *placementUpdate) FixtureId() string {
+	return u.fixtureId
+}
+
+func (u *placementUpdate) TeamId() string {
+	return u.teamId
+}
+
+func (u *placementUpdate) Placement() int {
+	return u.placement
+}
+
 type WinningTeamUpdateReceiver interface {
 	Receive(update WinningTeamUpdate)
 }
 
 type WinningTeamUpdate interface {
+	Envelope() EventEnvelope
 	FixtureId() string
 	TeamId() string
 }
 
 type winningTeamUpdate struct {
+	envelope  EventEnvelope
 	fixtureId string
 	teamId    string
 }
 
+func NewWinningTeamUpdate(envelope EventEnvelope, fixtureId string, teamId string) WinningTeamUpdate {
+	return &winningTeamUpdate{
+		envelope:  envelope,
+		fixtureId: fixtureId,
+		teamId:    teamId,
+	}
+}
+
+func (u *winningTeamUpdate) Envelope() EventEnvelope {
+	return u.envelope
+}
+
 func (u *winningTeamUpdate) FixtureId() string {
 	return u.fixtureId
 }
@@ -54,21 +160,44 @@ type ScoreUpdateReceiver interface {
 }
 
 type ScoreUpdate interface {
+	Envelope() EventEnvelope
 	FixtureId() string
+	// Map the score is for (1 for the first map), 0 if the producer doesn't keep track of maps
+	Map() int
 	TeamId() string
 	Score() int
 }
 
 type scoreUpdate struct {
+	envelope  EventEnvelope
 	fixtureId string
+	mapNumber int
 	teamId    string
 	score     int
 }
 
+func NewScoreUpdate(envelope EventEnvelope, fixtureId string, mapNumber int, teamId string, score int) ScoreUpdate {
+	return &scoreUpdate{
+		envelope:  envelope,
+		fixtureId: fixtureId,
+		mapNumber: mapNumber,
+		teamId:    teamId,
+		score:     score,
+	}
+}
+
+func (u *scoreUpdate) Envelope() EventEnvelope {
+	return u.envelope
+}
+
 func (u *scoreUpdate) FixtureId() string {
 	return u.fixtureId
 }
 
+func (u *scoreUpdate) Map() int {
+	return u.mapNumber
+}
+
 func (u *scoreUpdate) TeamId() string {
 	return u.teamId
 }
@@ -79,11 +208,81 @@ func (u *scoreUpdate) Score() int {
 
 type randomLiveScorePublisher struct {
 	sync.Mutex
-	fixtures         []*fixture
-	fixtureScores    map[string][]int
-	teamScoreLimit   int
+	fixtures []*fixture
+	// Progress of every fixture's series, by fixture id
+	series           map[string]*simulatedSeries
+	matchRules       MatchRules
 	tickerDuration   time.Duration
 	decisionProvider decisionProvider
+	bus              *EventBus
+
+	// Envelope of the published updates, sequences are kept per fixture
+	producerId string
+	sequences  map[string]uint64
+
+	// Fixtures are scheduled until their start time, then live until their series is over
+	statuses map[string]string
+
+	// Simulated time, moved forward by a tick on every tick rather than read from the wall clock,
+	// so a replayed run starts the same fixtures and emits the same times
+	clock time.Time
+}
+
+// Scores on the map being played (kills in free-for-all fixtures), maps won and placements,
+// in the fixture's team order
+type simulatedSeries struct {
+	mapNumber  int
+	mapScores  []int
+	mapsWon    []int
+	mapsPlayed int
+	// 0 while the team is still in a free-for-all fixture
+	placements []int
+	result     MatchResult
+}
+
+func newSimulatedSeries(teamCount int) *simulatedSeries {
+	return &simulatedSeries{
+		mapNumber:  1,
+		mapScores:  make([]int, teamCount),
+		mapsWon:    make([]int, teamCount),
+		placements: make([]int, teamCount),
+		result:     undecided,
+	}
+}
+
+// Record the map's result, then either decide the series or move on to the next map
+func (s *simulatedSeries) finishMap(rules MatchRules, mapResult MatchResult) {
+	s.mapsPlayed++
+	if mapResult.Winner >= 0 {
+		s.mapsWon[mapResult.Winner]++
+	}
+
+	s.result = rules.SeriesResult(s.mapsWon, s.mapsPlayed)
+	if !s.result.Decided() {
+		s.mapNumber++
+		for i := range s.mapScores {
+			s.mapScores[i] = 0
+		}
+	}
+}
+
+// Indexes of the teams still in a free-for-all fixture
+func (s *simulatedSeries) remainingTeams() []int {
+	remaining := make([]int, 0, len(s.placements))
+	for i, placement := range s.placements {
+		if placement == 0 {
+			remaining = append(remaining, i)
+		}
+	}
+	return remaining
+}
+
+// Updates generated on every tick, published in this order so a fixture's sequences are published in order
+type simulatedUpdates struct {
+	scoreUpdates       []ScoreUpdate
+	placementUpdates   []PlacementUpdate
+	winningTeamUpdates []WinningTeamUpdate
+	statusUpdates      []FixtureStatusUpdate
 }
 
 func (p *randomLiveScorePublisher) StartRandomPublish() {
@@ -97,116 +296,234 @@ func (p *randomLiveScorePublisher) StartRandomPublish() {
 }
 
 func (p *randomLiveScorePublisher) doGenerateRandomScoreAndPublish() {
-	scoreUpdates := p.generateRandomScoreUpdates()
-	for _, scoreUpdate := range scoreUpdates {
-		p.publish(scoreUpdate)
+	p.tick()
+	for _, statusUpdate := range p.startDueFixtures() {
+		p.bus.PublishFixtureStatusUpdate(statusUpdate)
 	}
-	p.calculateAndPublishWinningTeamUpdates(scoreUpdates)
-}
 
-func (p *randomLiveScorePublisher) calculateAndPublishWinningTeamUpdates(scoreUpdates []ScoreUpdate) {
-	for _, scoreUpdate := range scoreUpdates {
-		if scoreUpdate.Score() == p.teamScoreLimit {
-			p.publishWinningTeamUpdate(&winningTeamUpdate{
-				fixtureId: scoreUpdate.FixtureId(),
-				teamId:    scoreUpdate.TeamId(),
-			})
-		}
+	// Placements and winners (or draws) are published after the scores deciding them, then the fixtures
+	// are finished
+	updates := p.generateRandomScoreUpdates()
+	for _, scoreUpdate := range updates.scoreUpdates {
+		p.publish(scoreUpdate)
+	}
+	for _, placementUpdate := range updates.placementUpdates {
+		p.bus.PublishPlacementUpdate(placementUpdate)
+	}
+	for _, winningTeamUpdate := range updates.winningTeamUpdates {
+		p.publishWinningTeamUpdate(winningTeamUpdate)
+	}
+	for _, statusUpdate := range updates.statusUpdates {
+		p.bus.PublishFixtureStatusUpdate(statusUpdate)
 	}
 }
 
-func (p *randomLiveScorePublisher) generateRandomScoreUpdates() []ScoreUpdate {
+func (p *randomLiveScorePublisher) generateRandomScoreUpdates() *simulatedUpdates {
 	p.Lock()
 	defer p.Unlock()
 
-	scoreUpdates := make([]ScoreUpdate, 0)
+	if p.series == nil {
+		p.series = make(map[string]*simulatedSeries)
+	}
+
+	updates := &simulatedUpdates{
+		scoreUpdates:       make([]ScoreUpdate, 0),
+		placementUpdates:   make([]PlacementUpdate, 0),
+		winningTeamUpdates: make([]WinningTeamUpdate, 0),
+		statusUpdates:      make([]FixtureStatusUpdate, 0),
+	}
 
 	for _, fixture := range p.fixtures {
-		fixtureScores, found := p.fixtureScores[fixture.Id]
+		series, found := p.series[fixture.Id]
 		if !found {
-			fixtureScores = p.initialiseFixtureScores(*fixture)
-			p.fixtureScores[fixture.Id] = fixtureScores
+			series = newSimulatedSeries(len(fixture.Teams))
+			p.series[fixture.Id] = series
 		}
 
-		if !p.isFixtureLive(*fixture) || p.hasTeamWonFixture(fixtureScores) {
+		if !p.isFixtureLive(*fixture) || series.result.Decided() {
 			continue
 		}
 
 		shouldUpdateFixture := p.decisionProvider.TrueFalse(3)
 		if shouldUpdateFixture {
-			for i, team := range fixture.Teams {
-				shouldUpdateTeam := p.decisionProvider.TrueFalse(5)
-				if shouldUpdateTeam {
-					fixtureScores[i] = fixtureScores[i] + 1
-					p.fixtureScores[fixture.Id] = fixtureScores
-					scoreUpdates = append(scoreUpdates, &scoreUpdate{
-						fixtureId: fixture.Id,
-						teamId:    team.Id,
-						score:     fixtureScores[i],
-					})
-
-					hasTeamWon := fixtureScores[i] >= p.teamScoreLimit
-					if hasTeamWon {
-						break
-					}
-				}
+			if fixture.Points != nil {
+				p.generateFreeForAllUpdates(*fixture, series, updates)
+			} else {
+				p.generateMapUpdates(*fixture, series, updates)
+			}
+		}
+
+		if series.result.Decided() {
+			if series.result.Winner >= 0 {
+				updates.winningTeamUpdates = append(updates.winningTeamUpdates, &winningTeamUpdate{
+					envelope:  p.nextEnvelope(fixture.Id),
+					fixtureId: fixture.Id,
+					teamId:    fixture.Teams[series.result.Winner].Id,
+				})
 			}
+			updates.statusUpdates = append(updates.statusUpdates, p.setStatus(fixture.Id, FixtureStatusFinished))
 		}
 	}
 
-	return scoreUpdates
+	return updates
 }
 
-func (p *randomLiveScorePublisher) isFixtureLive(fixture fixture) bool {
-	return fixture.ScheduledStartTime < time.Now().UTC().Unix()
+// Teams score on the map being played until the rules decide it. Must be called holding the publisher's lock.
+func (p *randomLiveScorePublisher) generateMapUpdates(fixture fixture, series *simulatedSeries, updates *simulatedUpdates) {
+	rules := p.rules(fixture)
+	for i, team := range fixture.Teams {
+		shouldUpdateTeam := p.decisionProvider.TrueFalse(5)
+		if shouldUpdateTeam {
+			series.mapScores[i] = series.mapScores[i] + 1
+			updates.scoreUpdates = append(updates.scoreUpdates, &scoreUpdate{
+				envelope:  p.nextEnvelope(fixture.Id),
+				fixtureId: fixture.Id,
+				mapNumber: series.mapNumber,
+				teamId:    team.Id,
+				score:     series.mapScores[i],
+			})
+
+			mapResult := rules.MapResult(series.mapScores)
+			if mapResult.Decided() {
+				series.finishMap(rules, mapResult)
+				break
+			}
+		}
+	}
 }
 
-func (p *randomLiveScorePublisher) hasTeamWonFixture(fixtureScores []int) bool {
-	for _, score := range fixtureScores {
-		if score >= p.teamScoreLimit {
-			return true
+// The remaining teams score kills, then one of them may be eliminated. Must be called holding the
+// publisher's lock.
+func (p *randomLiveScorePublisher) generateFreeForAllUpdates(fixture fixture, series *simulatedSeries, updates *simulatedUpdates) {
+	remaining := series.remainingTeams()
+	for _, i := range remaining {
+		shouldUpdateTeam := p.decisionProvider.TrueFalse(5)
+		if shouldUpdateTeam {
+			series.mapScores[i] = series.mapScores[i] + 1
+			updates.scoreUpdates = append(updates.scoreUpdates, &scoreUpdate{
+				envelope:  p.nextEnvelope(fixture.Id),
+				fixtureId: fixture.Id,
+				teamId:    fixture.Teams[i].Id,
+				score:     series.mapScores[i],
+			})
+		}
+	}
+
+	// One of the remaining teams may be eliminated, taking the last placement left
+	for _, i := range remaining {
+		if !p.decisionProvider.TrueFalse(len(remaining)) {
+			continue
+		}
+
+		placement := len(remaining)
+		series.placements[i] = placement
+		updates.placementUpdates = append(updates.placementUpdates, NewPlacementUpdate(
+			p.nextEnvelope(fixture.Id), fixture.Id, fixture.Teams[i].Id, placement))
+
+		// The last team standing is placed first and wins
+		if placement <= 2 {
+			winner := i
+			if placement == 2 {
+				winner = series.remainingTeams()[0]
+				series.placements[winner] = 1
+				updates.placementUpdates = append(updates.placementUpdates, NewPlacementUpdate(
+					p.nextEnvelope(fixture.Id), fixture.Id, fixture.Teams[winner].Id, 1))
+			}
+			series.result = MatchResult{Winner: winner}
 		}
+		break
 	}
-	return false
 }
 
-func (p *randomLiveScorePublisher) initialiseFixtureScores(fixture fixture) []int {
-	scores := make([]int, len(fixture.Teams))
-	for i, _ := range fixture.Teams {
-		scores[i] = 0
+// Fixtures without valid rules of their own are played under the publisher's
+func (p *randomLiveScorePublisher) rules(fixture fixture) MatchRules {
+	if fixture.Rules != nil && fixture.Rules.Valid() {
+		return *fixture.Rules
 	}
-	return scores
+	return p.matchRules
 }
 
-func (p *randomLiveScorePublisher) publish(scoreUpdate ScoreUpdate) {
-	receiversLock.RLock()
-	defer receiversLock.RUnlock()
+func (p *randomLiveScorePublisher) isFixtureLive(fixture fixture) bool {
+	return p.status(fixture.Id) == FixtureStatusLive
+}
+
+// Move the simulated clock forward by a tick
+func (p *randomLiveScorePublisher) tick() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.clock = p.clock.Add(p.tickerDuration)
+}
+
+// Move the scheduled fixtures whose start time has passed to live
+func (p *randomLiveScorePublisher) startDueFixtures() []FixtureStatusUpdate {
+	p.Lock()
+	defer p.Unlock()
 
-	for _, receiver := range registeredScoreUpdateReceivers {
-		receiver.Receive(scoreUpdate)
+	now := p.clock.Unix()
+	statusUpdates := make([]FixtureStatusUpdate, 0)
+	for _, fixture := range p.fixtures {
+		if p.status(fixture.Id) == FixtureStatusScheduled && fixture.ScheduledStartTime < now {
+			statusUpdates = append(statusUpdates, p.setStatus(fixture.Id, FixtureStatusLive))
+		}
 	}
+
+	return statusUpdates
 }
 
-func (p *randomLiveScorePublisher) publishWinningTeamUpdate(update WinningTeamUpdate) {
-	receiversLock.RLock()
-	defer receiversLock.RUnlock()
+// Must be called holding the publisher's lock
+func (p *randomLiveScorePublisher) status(fixtureId string) string {
+	if status, found := p.statuses[fixtureId]; found {
+		return status
+	}
+	return FixtureStatusScheduled
+}
 
-	for _, receiver := range registeredWinningTeamUpdateReceivers {
-		receiver.Receive(update)
+// Must be called holding the publisher's lock
+func (p *randomLiveScorePublisher) setStatus(fixtureId string, status string) FixtureStatusUpdate {
+	if p.statuses == nil {
+		p.statuses = make(map[string]string)
 	}
+	p.statuses[fixtureId] = status
+	return NewFixtureStatusUpdate(p.nextEnvelope(fixtureId), fixtureId, status)
+}
+
+// Must be called holding the publisher's lock
+func (p *randomLiveScorePublisher) nextEnvelope(fixtureId string) EventEnvelope {
+	if p.sequences == nil {
+		p.sequences = make(map[string]uint64)
+	}
+	p.sequences[fixtureId]++
+	return NewEventEnvelope(p.producerId, fixtureId, p.sequences[fixtureId], p.clock)
+}
+
+func (p *randomLiveScorePublisher) publish(scoreUpdate ScoreUpdate) {
+	p.bus.PublishScoreUpdate(scoreUpdate)
+}
+
+func (p *randomLiveScorePublisher) publishWinningTeamUpdate(update WinningTeamUpdate) {
+	p.bus.PublishWinningTeamUpdate(update)
 }
 
 func newRandomLiveScorePublisher(
 	fixtures []*fixture,
 	publishTickDuration time.Duration,
 	decisionProvider decisionProvider,
-	teamScoreLimit int) *randomLiveScorePublisher {
+	matchRules MatchRules,
+	bus *EventBus,
+	producerId string,
+	startTime time.Time) *randomLiveScorePublisher {
 
 	return &randomLiveScorePublisher{
 		fixtures:         fixtures,
-		fixtureScores:    make(map[string][]int),
+		series:           make(map[string]*simulatedSeries),
+		matchRules:       matchRules,
 		tickerDuration:   publishTickDuration,
 		decisionProvider: decisionProvider,
-		teamScoreLimit:   teamScoreLimit,
+		bus:              bus,
+		producerId:       producerId,
+		sequences:        make(map[string]uint64),
+		statuses:         make(map[string]string),
+		clock:            startTime,
 	}
 }
@@ -1,6 +1,7 @@
 package external
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
@@ -29,86 +30,45 @@ func (t *testWinningTeamUpdateReceiver) Receive(update WinningTeamUpdate) {
 	t.receivedUpdates = append(t.receivedUpdates, update)
 }
 
-func TestRegisterScoreUpdateReceivers(t *testing.T) {
-
-	tearDown := func() {
-		registeredScoreUpdateReceivers = make([]ScoreUpdateReceiver, 0)
-	}
-
-	t.Run("when single receiver registers receiver", func(t *testing.T) {
-		receiver := &testScoreUpdateReceiver{}
-
-		RegisterScoreUpdateReceivers(receiver)
-
-		assert.Equal(t, 1, len(registeredScoreUpdateReceivers))
-		assert.Equal(t, receiver, registeredScoreUpdateReceivers[0])
-
-		tearDown()
-	})
-
-	t.Run("when single receiver registers receiver", func(t *testing.T) {
-		receiver1 := &testScoreUpdateReceiver{}
-		receiver2 := &testScoreUpdateReceiver{}
-
-		RegisterScoreUpdateReceivers(receiver1, receiver2)
-
-		assert.Equal(t, 2, len(registeredScoreUpdateReceivers))
-
-		tearDown()
-	})
+type testFixtureStatusUpdateReceiver struct {
+	receivedUpdates []FixtureStatusUpdate
 }
 
-func TestWinningTeamUpdateReceivers(t *testing.T) {
-
-	tearDown := func() {
-		registeredWinningTeamUpdateReceivers = make([]WinningTeamUpdateReceiver, 0)
-	}
-
-	t.Run("when single receiver registers receiver", func(t *testing.T) {
-		receiver := &testWinningTeamUpdateReceiver{}
-
-		RegisterWinningTeamUpdateReceivers(receiver)
-
-		assert.Equal(t, 1, len(registeredWinningTeamUpdateReceivers))
-		assert.Equal(t, receiver, registeredWinningTeamUpdateReceivers[0])
-
-		tearDown()
-	})
-
-	t.Run("when single receiver registers receiver", func(t *testing.T) {
-		receiver1 := &testWinningTeamUpdateReceiver{}
-		receiver2 := &testWinningTeamUpdateReceiver{}
-
-		RegisterWinningTeamUpdateReceivers(receiver1, receiver2)
-
-		assert.Equal(t, 2, len(registeredWinningTeamUpdateReceivers))
-
-		tearDown()
-	})
+func (t *testFixtureStatusUpdateReceiver) Receive(update FixtureStatusUpdate) {
+	t.receivedUpdates = append(t.receivedUpdates, update)
 }
 
 func TestRandomLiveScorePublisher(t *testing.T) {
 
 	var scoreUpdateReceiver *testScoreUpdateReceiver
 	var winningTeamUpdateReceiver *testWinningTeamUpdateReceiver
+	var fixtureStatusUpdateReceiver *testFixtureStatusUpdateReceiver
 	var decisionProvider *decisionProviderMock
+	var bus *EventBus
 
 	setup := func() *randomLiveScorePublisher {
 		scoreUpdateReceiver = &testScoreUpdateReceiver{}
 		winningTeamUpdateReceiver = &testWinningTeamUpdateReceiver{}
+		fixtureStatusUpdateReceiver = &testFixtureStatusUpdateReceiver{}
 		decisionProvider = new(decisionProviderMock)
-		RegisterScoreUpdateReceivers(scoreUpdateReceiver)
-		RegisterWinningTeamUpdateReceivers(winningTeamUpdateReceiver)
+		bus = NewEventBus(DefaultEventBusQueueSize)
+		bus.SubscribeScoreUpdates(scoreUpdateReceiver, SubscriptionOptions{})
+		bus.SubscribeWinningTeamUpdates(winningTeamUpdateReceiver, SubscriptionOptions{})
+		bus.SubscribeFixtureStatusUpdates(fixtureStatusUpdateReceiver, SubscriptionOptions{})
 		return &randomLiveScorePublisher{
 			fixtures:         make([]*fixture, 0),
-			fixtureScores:    make(map[string][]int),
+			series:           make(map[string]*simulatedSeries),
 			decisionProvider: decisionProvider,
-			teamScoreLimit:   2,
+			matchRules:       FirstTo(2),
+			bus:              bus,
+			producerId:       "test-producer",
+			clock:            time.Now().UTC(),
 		}
 	}
 
-	tearDown := func() {
-		registeredScoreUpdateReceivers = make([]ScoreUpdateReceiver, 0)
+	// Wait for the receivers to get the published updates
+	waitForUpdates := func() {
+		bus.Close()
 	}
 
 	defaultFixture := &fixture{
@@ -136,10 +96,9 @@ func TestRandomLiveScorePublisher(t *testing.T) {
 			decisionProvider.On("TrueFalse", mock.Anything).Return(false)
 
 			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
 
 			assert.Equal(t, 1, len(scoreUpdateReceiver.receivedUpdates))
-
-			tearDown()
 		})
 
 		t.Run("when publishes score has matching fixture id", func(t *testing.T) {
@@ -151,12 +110,11 @@ func TestRandomLiveScorePublisher(t *testing.T) {
 			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
 
 			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
 
 			resultScoreUpdate := scoreUpdateReceiver.receivedUpdates[0]
 
 			assert.Equal(t, testFixture.Id, resultScoreUpdate.FixtureId())
-
-			tearDown()
 		})
 
 		t.Run("when publishes score has matching team id", func(t *testing.T) {
@@ -168,14 +126,13 @@ func TestRandomLiveScorePublisher(t *testing.T) {
 			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
 
 			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
 
 			resultScoreUpdateTeam1 := scoreUpdateReceiver.receivedUpdates[0]
 			resultScoreUpdateTeam2 := scoreUpdateReceiver.receivedUpdates[1]
 
 			assert.Equal(t, testFixture.Teams[0].Id, resultScoreUpdateTeam1.TeamId())
 			assert.Equal(t, testFixture.Teams[1].Id, resultScoreUpdateTeam2.TeamId())
-
-			tearDown()
 		})
 
 		t.Run("when publishes score score is incremented by 1", func(t *testing.T) {
@@ -183,18 +140,17 @@ func TestRandomLiveScorePublisher(t *testing.T) {
 
 			testFixture := defaultFixture
 			publisher.fixtures = []*fixture{testFixture}
-			publisher.teamScoreLimit = 3
+			publisher.matchRules = FirstTo(3)
 
 			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
 
 			publisher.doGenerateRandomScoreAndPublish()
 			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
 
 			resultScoreUpdate := scoreUpdateReceiver.receivedUpdates[2]
 
 			assert.Equal(t, 2, resultScoreUpdate.Score())
-
-			tearDown()
 		})
 
 		t.Run("when decision provider returns false for should update fixture does not publish score update", func(t *testing.T) {
@@ -202,15 +158,14 @@ func TestRandomLiveScorePublisher(t *testing.T) {
 
 			testFixture := defaultFixture
 			publisher.fixtures = []*fixture{testFixture}
-			publisher.teamScoreLimit = 3
+			publisher.matchRules = FirstTo(3)
 
 			decisionProvider.On("TrueFalse", mock.Anything).Return(false)
 
 			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
 
 			assert.Equal(t, 0, len(scoreUpdateReceiver.receivedUpdates))
-
-			tearDown()
 		})
 
 		t.Run("when decision provider returns false for should update team does not publish score update", func(t *testing.T) {
@@ -218,16 +173,15 @@ func TestRandomLiveScorePublisher(t *testing.T) {
 
 			testFixture := defaultFixture
 			publisher.fixtures = []*fixture{testFixture}
-			publisher.teamScoreLimit = 3
+			publisher.matchRules = FirstTo(3)
 
 			decisionProvider.On("TrueFalse", mock.Anything).Times(1).Return(true)
 			decisionProvider.On("TrueFalse", mock.Anything).Return(false)
 
 			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
 
 			assert.Equal(t, 0, len(scoreUpdateReceiver.receivedUpdates))
-
-			tearDown()
 		})
 
 		t.Run("when fixture scheduled to start in future should not publish update", func(t *testing.T) {
@@ -236,15 +190,14 @@ func TestRandomLiveScorePublisher(t *testing.T) {
 			testFixture := defaultFixture
 			testFixture.ScheduledStartTime = time.Now().UTC().Add(1 * time.Hour).Unix()
 			publisher.fixtures = []*fixture{testFixture}
-			publisher.teamScoreLimit = 3
+			publisher.matchRules = FirstTo(3)
 
 			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
 
 			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
 
 			assert.Equal(t, 0, len(scoreUpdateReceiver.receivedUpdates))
-
-			tearDown()
 		})
 
 		t.Run("when fixture scheduled start time is in past should publish update", func(t *testing.T) {
@@ -253,15 +206,14 @@ func TestRandomLiveScorePublisher(t *testing.T) {
 			testFixture := defaultFixture
 			testFixture.ScheduledStartTime = time.Now().UTC().Add(-1 * time.Hour).Unix()
 			publisher.fixtures = []*fixture{testFixture}
-			publisher.teamScoreLimit = 3
+			publisher.matchRules = FirstTo(3)
 
 			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
 
 			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
 
 			assert.Equal(t, 2, len(scoreUpdateReceiver.receivedUpdates))
-
-			tearDown()
 		})
 
 		t.Run("when team has won fixture should not publish update", func(t *testing.T) {
@@ -270,16 +222,15 @@ func TestRandomLiveScorePublisher(t *testing.T) {
 			testFixture := defaultFixture
 			testFixture.ScheduledStartTime = time.Now().UTC().Add(-1 * time.Hour).Unix()
 			publisher.fixtures = []*fixture{testFixture}
-			publisher.teamScoreLimit = 1
+			publisher.matchRules = FirstTo(1)
 
 			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
 
 			publisher.doGenerateRandomScoreAndPublish()
 			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
 
 			assert.Equal(t, 1, len(scoreUpdateReceiver.receivedUpdates))
-
-			tearDown()
 		})
 
 		t.Run("when team reaches score limit publishes winning team update with correct values", func(t *testing.T) {
@@ -292,17 +243,16 @@ func TestRandomLiveScorePublisher(t *testing.T) {
 			testFixture.Id = expectedFixtureId
 			testFixture.Teams[0].Id = expectedTeamId
 			publisher.fixtures = []*fixture{testFixture}
-			publisher.teamScoreLimit = 1
+			publisher.matchRules = FirstTo(1)
 
 			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
 
 			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
 
 			assert.Equal(t, 1, len(winningTeamUpdateReceiver.receivedUpdates))
 			assert.Equal(t, expectedFixtureId, winningTeamUpdateReceiver.receivedUpdates[0].FixtureId())
 			assert.Equal(t, expectedTeamId, winningTeamUpdateReceiver.receivedUpdates[0].TeamId())
-
-			tearDown()
 		})
 
 		t.Run("when team has already reached score limit does not publish winning team update", func(t *testing.T) {
@@ -315,16 +265,182 @@ func TestRandomLiveScorePublisher(t *testing.T) {
 			testFixture.Id = expectedFixtureId
 			testFixture.Teams[0].Id = expectedTeamId
 			publisher.fixtures = []*fixture{testFixture}
-			publisher.teamScoreLimit = 1
+			publisher.matchRules = FirstTo(1)
 
 			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
 
 			publisher.doGenerateRandomScoreAndPublish()
 			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
 
 			assert.Equal(t, 1, len(winningTeamUpdateReceiver.receivedUpdates))
+		})
+
+		t.Run("when publishes updates they are sequenced per fixture", func(t *testing.T) {
+			publisher := setup()
+
+			testFixture := &fixture{Id: "fixture-id", Teams: []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-2"}}}
+			otherFixture := &fixture{Id: "other-fixture-id", Teams: []fixtureTeam{{Id: "team-id-3"}}}
+			publisher.fixtures = []*fixture{testFixture, otherFixture}
+			publisher.matchRules = FirstTo(1)
+
+			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
+
+			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
+
+			scoreEnvelope := scoreUpdateReceiver.receivedUpdates[0].Envelope()
+			otherFixtureEnvelope := scoreUpdateReceiver.receivedUpdates[1].Envelope()
+			winningTeamEnvelope := winningTeamUpdateReceiver.receivedUpdates[0].Envelope()
+
+			// Fixtures going live come first
+			assert.Equal(t, uint64(2), scoreEnvelope.Sequence)
+			assert.Equal(t, "test-producer", scoreEnvelope.ProducerId)
+			assert.Equal(t, "test-producer/fixture-id/2", scoreEnvelope.EventId)
+			assert.False(t, scoreEnvelope.EmittedAt.IsZero())
+			assert.Equal(t, uint64(2), otherFixtureEnvelope.Sequence)
+			assert.Equal(t, "fixture-id", winningTeamUpdateReceiver.receivedUpdates[0].FixtureId())
+			assert.Equal(t, uint64(3), winningTeamEnvelope.Sequence)
+		})
+
+		t.Run("when fixture start time has passed publishes it's live and when a team wins publishes it's finished", func(t *testing.T) {
+			publisher := setup()
+
+			testFixture := &fixture{Id: "fixture-id", Teams: []fixtureTeam{{Id: "team-id-1"}}}
+			futureFixture := &fixture{Id: "future-fixture-id", ScheduledStartTime: time.Now().UTC().Add(time.Hour).Unix()}
+			publisher.fixtures = []*fixture{testFixture, futureFixture}
+			publisher.matchRules = FirstTo(2)
+
+			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
+
+			publisher.doGenerateRandomScoreAndPublish()
+			publisher.doGenerateRandomScoreAndPublish()
+			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
+
+			assert.Equal(t, 2, len(fixtureStatusUpdateReceiver.receivedUpdates))
+			assert.Equal(t, "fixture-id", fixtureStatusUpdateReceiver.receivedUpdates[0].FixtureId())
+			assert.Equal(t, FixtureStatusLive, fixtureStatusUpdateReceiver.receivedUpdates[0].Status())
+			assert.Equal(t, FixtureStatusFinished, fixtureStatusUpdateReceiver.receivedUpdates[1].Status())
+			assert.Equal(t, uint64(5), fixtureStatusUpdateReceiver.receivedUpdates[1].Envelope().Sequence)
+			assert.Equal(t, 2, len(scoreUpdateReceiver.receivedUpdates))
+		})
+
+		t.Run("when a team wins a map of a series moves on to the next map until the series is won", func(t *testing.T) {
+			publisher := setup()
+
+			testFixture := &fixture{Id: "fixture-id", Teams: []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-2"}}}
+			publisher.fixtures = []*fixture{testFixture}
+			publisher.matchRules = MatchRules{BestOf: 3, MapScoreLimit: 1}
+
+			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
+
+			publisher.doGenerateRandomScoreAndPublish()
+			publisher.doGenerateRandomScoreAndPublish()
+			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
+
+			assert.Equal(t, 2, len(scoreUpdateReceiver.receivedUpdates))
+			assert.Equal(t, 1, scoreUpdateReceiver.receivedUpdates[0].Map())
+			assert.Equal(t, 2, scoreUpdateReceiver.receivedUpdates[1].Map())
+			assert.Equal(t, 1, scoreUpdateReceiver.receivedUpdates[1].Score())
+			assert.Equal(t, 1, len(winningTeamUpdateReceiver.receivedUpdates))
+			assert.Equal(t, "team-id-1", winningTeamUpdateReceiver.receivedUpdates[0].TeamId())
+		})
+
+		t.Run("when a fixture has its own rules and ends in a draw publishes it's finished without a winner", func(t *testing.T) {
+			publisher := setup()
+
+			testFixture := &fixture{
+				Id:    "fixture-id",
+				Teams: []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-2"}},
+				Rules: &MatchRules{BestOf: 1, MapScoreLimit: 2, AllowDraws: true},
+			}
+			publisher.fixtures = []*fixture{testFixture}
+			publisher.matchRules = FirstTo(1)
+
+			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
+
+			publisher.doGenerateRandomScoreAndPublish()
+			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
+
+			assert.Equal(t, 2, len(scoreUpdateReceiver.receivedUpdates))
+			assert.Equal(t, 0, len(winningTeamUpdateReceiver.receivedUpdates))
+			assert.Equal(t, 2, len(fixtureStatusUpdateReceiver.receivedUpdates))
+			assert.Equal(t, FixtureStatusFinished, fixtureStatusUpdateReceiver.receivedUpdates[1].Status())
+		})
+
+		t.Run("when a free-for-all fixture is played publishes kills and placements until the last team standing wins", func(t *testing.T) {
+			publisher := setup()
+
+			testFixture := &fixture{
+				Id:     "fixture-id",
+				Teams:  []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-2"}, {Id: "team-id-3"}},
+				Points: &PointsTable{PlacementPoints: []int{10, 6, 3}, KillPoints: 1},
+			}
+			publisher.fixtures = []*fixture{testFixture}
+
+			placementReceiver := &funcPlacementUpdateReceiver{}
+			placements := make([]PlacementUpdate, 0)
+			placementReceiver.receive = func(update PlacementUpdate) {
+				placements = append(placements, update)
+			}
+			bus.SubscribePlacementUpdates(placementReceiver, SubscriptionOptions{})
+
+			decisionProvider.On("TrueFalse", mock.Anything).Return(true)
+
+			publisher.doGenerateRandomScoreAndPublish()
+			publisher.doGenerateRandomScoreAndPublish()
+			publisher.doGenerateRandomScoreAndPublish()
+			waitForUpdates()
+
+			assert.Equal(t, 5, len(scoreUpdateReceiver.receivedUpdates))
+			assert.Equal(t, 3, len(placements))
+			assert.Equal(t, "team-id-1", placements[0].TeamId())
+			assert.Equal(t, 3, placements[0].Placement())
+			assert.Equal(t, "team-id-2", placements[1].TeamId())
+			assert.Equal(t, 2, placements[1].Placement())
+			assert.Equal(t, "team-id-3", placements[2].TeamId())
+			assert.Equal(t, 1, placements[2].Placement())
+			assert.Equal(t, 1, len(winningTeamUpdateReceiver.receivedUpdates))
+			assert.Equal(t, "team-id-3", winningTeamUpdateReceiver.receivedUpdates[0].TeamId())
+			assert.Equal(t, FixtureStatusFinished, fixtureStatusUpdateReceiver.receivedUpdates[1].Status())
+		})
 
-			tearDown()
+		t.Run("when publishers use the same seed they publish the same updates, emitted at the same times", func(t *testing.T) {
+			play := func(seed int64) []string {
+				publisher := setup()
+				publisher.decisionProvider = newDecisionProvider(seed)
+				publisher.clock = time.Unix(1600000000, 0).UTC()
+				publisher.tickerDuration = time.Second
+				publisher.matchRules = FirstTo(5)
+				publisher.fixtures = []*fixture{{
+					Id:                 "fixture-id",
+					Teams:              []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-2"}},
+					ScheduledStartTime: publisher.clock.Unix(),
+				}}
+				for i := 0; i < 30; i++ {
+					publisher.doGenerateRandomScoreAndPublish()
+				}
+				waitForUpdates()
+
+				updates := make([]string, 0)
+				for _, update := range scoreUpdateReceiver.receivedUpdates {
+					updates = append(updates, fmt.Sprintf("%s/%d/%s/%d/%d", update.Envelope().EventId, update.Map(), update.TeamId(), update.Score(), update.Envelope().EmittedAt.UnixNano()))
+				}
+				for _, update := range winningTeamUpdateReceiver.receivedUpdates {
+					updates = append(updates, fmt.Sprintf("%s/%s/%d", update.Envelope().EventId, update.TeamId(), update.Envelope().EmittedAt.UnixNano()))
+				}
+				return updates
+			}
+
+			updates := play(42)
+			replayedUpdates := play(42)
+
+			assert.NotEmpty(t, updates)
+			assert.Equal(t, updates, replayedUpdates)
+			assert.NotEqual(t, updates, play(43))
 		})
 	})
 }
@@ -0,0 +1,140 @@
+package external
+
+// Rules deciding who wins each map of a fixture and the fixture (series) itself.
+// Shared by the random live score publisher and the live data server, so both agree on the results.
+type MatchRules struct {
+	// Maps in the series, a team wins it once it has won more than half of them (best-of-1, 3, 5...)
+	BestOf int `json:"bestOf" yaml:"bestOf"`
+	// Score (e.g. rounds) a team needs to win a map
+	MapScoreLimit int `json:"mapScoreLimit" yaml:"mapScoreLimit"`
+	// Once the two leading teams are both one point away from the limit, the map goes to overtime and
+	// is won by the first team leading by this many points. 0 means no overtime.
+	OvertimeWinBy int `json:"overtimeWinBy,omitempty" yaml:"overtimeWinBy,omitempty"`
+	// Without overtime, a map where the two leading teams are both one point away from the limit ends in
+	// a draw, and so does a series where every map has been played without a winner. Otherwise the next
+	// point decides the map and a decider map is played.
+	AllowDraws bool `json:"allowDraws,omitempty" yaml:"allowDraws,omitempty"`
+}
+
+// Single map, won by the first team to reach the score limit
+func FirstTo(scoreLimit int) MatchRules {
+	return MatchRules{
+		BestOf:        1,
+		MapScoreLimit: scoreLimit,
+	}
+}
+
+// Outcome of a map or series
+type MatchResult struct {
+	// Index of the winning team, -1 if there's no winner (yet)
+	Winner int
+	Draw   bool
+}
+
+var undecided = MatchResult{Winner: -1}
+
+func (result MatchResult) Decided() bool {
+	return result.Winner >= 0 || result.Draw
+}
+
+func (rules MatchRules) Valid() bool {
+	return rules.BestOf > 0 && rules.MapScoreLimit > 0 && rules.OvertimeWinBy >= 0
+}
+
+// Number of maps a team has to win to win the series
+func (rules MatchRules) MapsToWin() int {
+	return rules.BestOf/2 + 1
+}
+
+// Result of a map given every team's score on it
+func (rules MatchRules) MapResult(scores []int) MatchResult {
+	leader, leaderScore, runnerUpScore := leaders(scores)
+	if leader < 0 {
+		return undecided
+	}
+
+	inOvertime := rules.MapScoreLimit > 1 && runnerUpScore >= rules.MapScoreLimit-1
+	switch {
+	case rules.OvertimeWinBy > 0 && inOvertime:
+		if leaderScore-runnerUpScore >= rules.OvertimeWinBy {
+			return MatchResult{Winner: leader}
+		}
+	case leaderScore >= rules.MapScoreLimit && leaderScore > runnerUpScore:
+		return MatchResult{Winner: leader}
+	case rules.AllowDraws && inOvertime && leaderScore == runnerUpScore:
+		return MatchResult{Winner: -1, Draw: true}
+	}
+
+	return undecided
+}
+
+// Result of the series given the maps won by every team and the number of maps played (drawn ones included)
+func (rules MatchRules) SeriesResult(mapsWon []int, mapsPlayed int) MatchResult {
+	leader, leaderMaps, runnerUpMaps := leaders(mapsWon)
+	if leader < 0 {
+		return undecided
+	}
+
+	switch {
+	case leaderMaps >= rules.MapsToWin():
+		return MatchResult{Winner: leader}
+	case mapsPlayed < rules.BestOf:
+		return undecided
+	case leaderMaps > runnerUpMaps:
+		// Drawn maps left the leader short of a majority
+		return MatchResult{Winner: leader}
+	case rules.AllowDraws:
+		return MatchResult{Winner: -1, Draw: true}
+	default:
+		// Tied, a decider map is played
+		return undecided
+	}
+}
+
+// Index and value of the highest value (the first one when tied), and the second highest value.
+// The index is -1 if there are no values.
+func leaders(values []int) (int, int, int) {
+	leader, leaderValue, runnerUpValue := -1, 0, 0
+	for i, value := range values {
+		switch {
+		case leader < 0:
+			leader, leaderValue = i, value
+		case value > leaderValue:
+			runnerUpValue = leaderValue
+			leader, leaderValue = i, value
+		case value > runnerUpValue:
+			runnerUpValue = value
+		}
+	}
+	return leader, leaderValue, runnerUpValue
+}
+
+// Points of free-for-all (e.g. battle royale) fixtures, where teams are ranked by placement and kills
+// instead of playing maps
+type PointsTable struct {
+	// Points by placement, the first entry is for the team placed first. Placements past the end of
+	// the table get none.
+	PlacementPoints []int `json:"placementPoints" yaml:"placementPoints"`
+	KillPoints      int   `json:"killPoints" yaml:"killPoints"`
+}
+
+func (table PointsTable) Valid() bool {
+	for _, points := range table.PlacementPoints {
+		if points < 0 {
+			return false
+		}
+	}
+	return table.KillPoints >= 0
+}
+
+// Points for a placement (0 if the team hasn't been placed yet)
+func (table PointsTable) PointsForPlacement(placement int) int {
+	if placement < 1 || placement > len(table.PlacementPoints) {
+		return 0
+	}
+	return table.PlacementPoints[placement-1]
+}
+
+func (table PointsTable) PointsForKills(kills int) int {
+	return kills * table.KillPoints
+}
@@ -0,0 +1,96 @@
+package external
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchRules(t *testing.T) {
+
+	t.Run("MapResult", func(t *testing.T) {
+
+		t.Run("when a team reaches the score limit it wins the map", func(t *testing.T) {
+			rules := FirstTo(10)
+
+			assert.Equal(t, MatchResult{Winner: 1}, rules.MapResult([]int{4, 10}))
+			assert.False(t, rules.MapResult([]int{9, 9}).Decided())
+			assert.Equal(t, MatchResult{Winner: 2}, rules.MapResult([]int{3, 9, 10, 1}))
+		})
+
+		t.Run("when both teams are one point away from the limit with overtime the map is won by the required lead", func(t *testing.T) {
+			rules := MatchRules{BestOf: 1, MapScoreLimit: 13, OvertimeWinBy: 2}
+
+			assert.Equal(t, MatchResult{Winner: 0}, rules.MapResult([]int{13, 11}))
+			assert.False(t, rules.MapResult([]int{13, 12}).Decided())
+			assert.False(t, rules.MapResult([]int{15, 14}).Decided())
+			assert.Equal(t, MatchResult{Winner: 1}, rules.MapResult([]int{14, 16}))
+		})
+
+		t.Run("when both teams are one point away from the limit without overtime the map is a draw if allowed", func(t *testing.T) {
+			withDraws := MatchRules{BestOf: 2, MapScoreLimit: 16, AllowDraws: true}
+			withoutDraws := MatchRules{BestOf: 2, MapScoreLimit: 16}
+
+			assert.Equal(t, MatchResult{Winner: -1, Draw: true}, withDraws.MapResult([]int{15, 15}))
+			assert.False(t, withDraws.MapResult([]int{14, 14}).Decided())
+			assert.False(t, withoutDraws.MapResult([]int{15, 15}).Decided())
+			assert.Equal(t, MatchResult{Winner: 0}, withoutDraws.MapResult([]int{16, 15}))
+		})
+
+		t.Run("when there are no teams the map is undecided", func(t *testing.T) {
+			assert.False(t, FirstTo(1).MapResult([]int{}).Decided())
+		})
+	})
+
+	t.Run("SeriesResult", func(t *testing.T) {
+
+		t.Run("when a team wins a majority of the maps it wins the series", func(t *testing.T) {
+			rules := MatchRules{BestOf: 5, MapScoreLimit: 13}
+
+			assert.Equal(t, MatchResult{Winner: 1}, rules.SeriesResult([]int{1, 3}, 4))
+			assert.False(t, rules.SeriesResult([]int{2, 2}, 4).Decided())
+		})
+
+		t.Run("when every map is played with the teams tied it's a draw if allowed, or goes to a decider", func(t *testing.T) {
+			withDraws := MatchRules{BestOf: 2, MapScoreLimit: 16, AllowDraws: true}
+			withoutDraws := MatchRules{BestOf: 2, MapScoreLimit: 16}
+
+			assert.Equal(t, MatchResult{Winner: -1, Draw: true}, withDraws.SeriesResult([]int{1, 1}, 2))
+			assert.Equal(t, MatchResult{Winner: 0}, withDraws.SeriesResult([]int{2, 0}, 2))
+			assert.False(t, withoutDraws.SeriesResult([]int{1, 1}, 2).Decided())
+			assert.Equal(t, MatchResult{Winner: 0}, withoutDraws.SeriesResult([]int{2, 1}, 3))
+		})
+
+		t.Run("when drawn maps leave the leader short of a majority it still wins once every map is played", func(t *testing.T) {
+			rules := MatchRules{BestOf: 3, MapScoreLimit: 16, AllowDraws: true}
+
+			assert.False(t, rules.SeriesResult([]int{1, 0}, 2).Decided())
+			assert.Equal(t, MatchResult{Winner: 0}, rules.SeriesResult([]int{1, 0}, 3))
+		})
+	})
+
+	t.Run("when rules have no maps or no score limit they're invalid", func(t *testing.T) {
+		assert.True(t, FirstTo(10).Valid())
+		assert.False(t, MatchRules{MapScoreLimit: 10}.Valid())
+		assert.False(t, MatchRules{BestOf: 3}.Valid())
+	})
+}
+
+func TestPointsTable(t *testing.T) {
+
+	t.Run("when a team is placed and has kills it gets the points of the table", func(t *testing.T) {
+		table := PointsTable{PlacementPoints: []int{10, 6, 3}, KillPoints: 2}
+
+		assert.Equal(t, 10, table.PointsForPlacement(1))
+		assert.Equal(t, 3, table.PointsForPlacement(3))
+		assert.Equal(t, 0, table.PointsForPlacement(4))
+		assert.Equal(t, 0, table.PointsForPlacement(0))
+		assert.Equal(t, 8, table.PointsForKills(4))
+	})
+
+	t.Run("when a table has negative points it's invalid", func(t *testing.T) {
+		assert.True(t, PointsTable{PlacementPoints: []int{3, 1}}.Valid())
+		assert.False(t, PointsTable{PlacementPoints: []int{3, -1}}.Valid())
+		assert.False(t, PointsTable{KillPoints: -1}.Valid())
+	})
+}
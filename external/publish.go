@@ -2,18 +2,370 @@ package external
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-func PublishViewModel(vm interface{}) error {
+const (
+	PublisherSinkStdout  = "stdout"
+	PublisherSinkFile    = "file"
+	PublisherSinkWebhook = "webhook"
+	PublisherSinkMemory  = "memory"
+	PublisherSinkRedis   = "redis"
 
-	jsonBytes, err := json.Marshal(vm)
+	PublishedMessageTypeViewModel      = "viewmodel"
+	PublishedMessageTypeViewModelPatch = "viewmodel_patch"
+)
+
+// Publishes viewmodel snapshots and patches to a sink
+type Publisher interface {
+	PublishViewModel(viewModel interface{}) error
+	PublishViewModelPatch(patch interface{}) error
+}
+
+// Implemented by publishers that keep the current state of every fixture
+type FixturePublisher interface {
+	// A nil fixture means the fixture has been removed
+	PublishFixture(version uint64, fixtureId string, fixture interface{}) error
+}
+
+// Implemented by publishers that can return the last published state
+type StateLoader interface {
+	// Returns nil if nothing has been published yet
+	LoadState() (*PublishedState, error)
+}
+
+type PublishedState struct {
+	Version  uint64
+	Fixtures []json.RawMessage
+}
+
+// Message written by the publisher sinks
+type PublishedMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type PublisherConfig struct {
+	Sinks []string
+
+	FilePath       string
+	FileMaxBytes   int64
+	FileMaxBackups int
+
+	WebhookURL            string
+	WebhookTimeout        time.Duration
+	WebhookSecret         string
+	WebhookMaxAttempts    int
+	WebhookBaseDelay      time.Duration
+	WebhookMaxDelay       time.Duration
+	WebhookQueueSize      int
+	WebhookDeadLetterPath string
+
+	RedisAddress   string
+	RedisKeyPrefix string
+	RedisTimeout   time.Duration
+}
+
+// Read the publisher configuration from the environment:
+// PUBLISHER_SINKS (comma separated, defaults to stdout), PUBLISHER_FILE_PATH, PUBLISHER_FILE_MAX_BYTES,
+// PUBLISHER_FILE_MAX_BACKUPS, PUBLISHER_WEBHOOK_URL, PUBLISHER_WEBHOOK_TIMEOUT (e.g. "5s"),
+// PUBLISHER_WEBHOOK_SECRET, PUBLISHER_WEBHOOK_MAX_ATTEMPTS, PUBLISHER_WEBHOOK_DEAD_LETTER_PATH,
+// PUBLISHER_REDIS_ADDRESS and PUBLISHER_REDIS_KEY_PREFIX.
+func PublisherConfigFromEnv() (PublisherConfig, error) {
+	config := PublisherConfig{
+		Sinks:          []string{PublisherSinkStdout},
+		FilePath:       os.Getenv("PUBLISHER_FILE_PATH"),
+		FileMaxBytes:   10 * 1024 * 1024,
+		FileMaxBackups: 5,
+		WebhookURL:     os.Getenv("PUBLISHER_WEBHOOK_URL"),
+		WebhookTimeout: 5 * time.Second,
+		WebhookSecret:  os.Getenv("PUBLISHER_WEBHOOK_SECRET"),
+		// ~1.5 minutes worth of retries
+		WebhookMaxAttempts:    8,
+		WebhookBaseDelay:      500 * time.Millisecond,
+		WebhookMaxDelay:       30 * time.Second,
+		WebhookQueueSize:      1024,
+		WebhookDeadLetterPath: "webhook-dead-letters.jsonl",
+		RedisAddress:          os.Getenv("PUBLISHER_REDIS_ADDRESS"),
+		RedisKeyPrefix:        "livedata",
+		RedisTimeout:          5 * time.Second,
+	}
+
+	if path := os.Getenv("PUBLISHER_WEBHOOK_DEAD_LETTER_PATH"); path != "" {
+		config.WebhookDeadLetterPath = path
+	}
+
+	if prefix := os.Getenv("PUBLISHER_REDIS_KEY_PREFIX"); prefix != "" {
+		config.RedisKeyPrefix = prefix
+	}
+
+	if sinks := os.Getenv("PUBLISHER_SINKS"); sinks != "" {
+		config.Sinks = make([]string, 0)
+		for _, sink := range strings.Split(sinks, ",") {
+			if sink = strings.TrimSpace(sink); sink != "" {
+				config.Sinks = append(config.Sinks, sink)
+			}
+		}
+	}
+
+	if value := os.Getenv("PUBLISHER_FILE_MAX_BYTES"); value != "" {
+		maxBytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return config, fmt.Errorf("invalid PUBLISHER_FILE_MAX_BYTES: %s", err.Error())
+		}
+		config.FileMaxBytes = maxBytes
+	}
+
+	if value := os.Getenv("PUBLISHER_FILE_MAX_BACKUPS"); value != "" {
+		maxBackups, err := strconv.Atoi(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid PUBLISHER_FILE_MAX_BACKUPS: %s", err.Error())
+		}
+		config.FileMaxBackups = maxBackups
+	}
+
+	if value := os.Getenv("PUBLISHER_WEBHOOK_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid PUBLISHER_WEBHOOK_TIMEOUT: %s", err.Error())
+		}
+		config.WebhookTimeout = timeout
+	}
+
+	if value := os.Getenv("PUBLISHER_WEBHOOK_MAX_ATTEMPTS"); value != "" {
+		maxAttempts, err := strconv.Atoi(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid PUBLISHER_WEBHOOK_MAX_ATTEMPTS: %s", err.Error())
+		}
+		config.WebhookMaxAttempts = maxAttempts
+	}
+
+	return config, nil
+}
+
+func (config PublisherConfig) WebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		URL:            config.WebhookURL,
+		Timeout:        config.WebhookTimeout,
+		Secret:         config.WebhookSecret,
+		MaxAttempts:    config.WebhookMaxAttempts,
+		BaseDelay:      config.WebhookBaseDelay,
+		MaxDelay:       config.WebhookMaxDelay,
+		QueueSize:      config.WebhookQueueSize,
+		DeadLetterPath: config.WebhookDeadLetterPath,
+	}
+}
+
+// Build a publisher writing to every configured sink
+func NewPublisher(config PublisherConfig) (Publisher, error) {
+	if len(config.Sinks) == 0 {
+		return nil, errors.New("no publisher sinks configured")
+	}
+
+	publishers := make([]Publisher, 0)
+	for _, sink := range config.Sinks {
+		switch sink {
+		case PublisherSinkStdout:
+			publishers = append(publishers, NewWriterPublisher(os.Stdout))
+		case PublisherSinkFile:
+			if config.FilePath == "" {
+				return nil, errors.New("file publisher requires a file path")
+			}
+			publisher, err := NewRotatingFilePublisher(config.FilePath, config.FileMaxBytes, config.FileMaxBackups)
+			if err != nil {
+				return nil, err
+			}
+			publishers = append(publishers, publisher)
+		case PublisherSinkWebhook:
+			if config.WebhookURL == "" {
+				return nil, errors.New("webhook publisher requires a url")
+			}
+			publishers = append(publishers, NewWebhookPublisher(config.WebhookConfig()))
+		case PublisherSinkMemory:
+			publishers = append(publishers, NewMemoryPublisher())
+		case PublisherSinkRedis:
+			if config.RedisAddress == "" {
+				return nil, errors.New("redis publisher requires an address")
+			}
+			publishers = append(publishers, NewRedisPublisher(config.RedisAddress, config.RedisKeyPrefix, config.RedisTimeout))
+		default:
+			return nil, fmt.Errorf("unknown publisher sink '%s'", sink)
+		}
+	}
+
+	if len(publishers) == 1 {
+		return publishers[0], nil
+	}
+	return NewMultiPublisher(publishers...), nil
+}
+
+func newPublishedMessage(messageType string, data interface{}) (*PublishedMessage, error) {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PublishedMessage{
+		Type: messageType,
+		Data: jsonBytes,
+	}, nil
+}
+
+// Publishes to several sinks, an error in one sink doesn't stop the others
+type multiPublisher struct {
+	publishers []Publisher
+}
+
+func NewMultiPublisher(publishers ...Publisher) Publisher {
+	return &multiPublisher{
+		publishers: publishers,
+	}
+}
+
+func (p *multiPublisher) PublishViewModel(viewModel interface{}) error {
+	var firstErr error
+	for _, publisher := range p.publishers {
+		if err := publisher.PublishViewModel(viewModel); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (p *multiPublisher) PublishViewModelPatch(patch interface{}) error {
+	var firstErr error
+	for _, publisher := range p.publishers {
+		if err := publisher.PublishViewModelPatch(patch); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (p *multiPublisher) PublishFixture(version uint64, fixtureId string, fixture interface{}) error {
+	var firstErr error
+	for _, publisher := range p.publishers {
+		fixturePublisher, ok := publisher.(FixturePublisher)
+		if !ok {
+			continue
+		}
+		if err := fixturePublisher.PublishFixture(version, fixtureId, fixture); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close every publisher that needs it, e.g. to flush queued deliveries
+func (p *multiPublisher) Close() error {
+	var firstErr error
+	for _, publisher := range p.publishers {
+		closer, ok := publisher.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Load the state from the first publisher able to do so
+func (p *multiPublisher) LoadState() (*PublishedState, error) {
+	for _, publisher := range p.publishers {
+		if loader, ok := publisher.(StateLoader); ok {
+			return loader.LoadState()
+		}
+	}
+	return nil, nil
+}
+
+// Writes every message as a JSON line, e.g. to stdout
+type writerPublisher struct {
+	sync.Mutex
+	writer io.Writer
+}
+
+func NewWriterPublisher(writer io.Writer) Publisher {
+	return &writerPublisher{
+		writer: writer,
+	}
+}
+
+func (p *writerPublisher) PublishViewModel(viewModel interface{}) error {
+	return p.write(PublishedMessageTypeViewModel, viewModel)
+}
+
+func (p *writerPublisher) PublishViewModelPatch(patch interface{}) error {
+	return p.write(PublishedMessageTypeViewModelPatch, patch)
+}
+
+func (p *writerPublisher) write(messageType string, data interface{}) error {
+	message, err := newPublishedMessage(messageType, data)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	_, err = p.writer.Write(append(jsonBytes, '\n'))
+	return err
+}
+
+// Keeps the published messages in memory, mostly useful for tests
+type MemoryPublisher struct {
+	sync.Mutex
+	messages []PublishedMessage
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{
+		messages: make([]PublishedMessage, 0),
+	}
+}
+
+func (p *MemoryPublisher) PublishViewModel(viewModel interface{}) error {
+	return p.store(PublishedMessageTypeViewModel, viewModel)
+}
+
+func (p *MemoryPublisher) PublishViewModelPatch(patch interface{}) error {
+	return p.store(PublishedMessageTypeViewModelPatch, patch)
+}
+
+// Returns a copy of the published messages
+func (p *MemoryPublisher) Messages() []PublishedMessage {
+	p.Lock()
+	defer p.Unlock()
+
+	messages := make([]PublishedMessage, len(p.messages))
+	copy(messages, p.messages)
+	return messages
+}
+
+func (p *MemoryPublisher) store(messageType string, data interface{}) error {
+	// Marshal straight away, as the published value may be modified afterwards
+	message, err := newPublishedMessage(messageType, data)
 	if err != nil {
 		return err
 	}
 
-	log.Println(fmt.Sprintf("published view model: %s", string(jsonBytes)))
+	p.Lock()
+	defer p.Unlock()
 
+	p.messages = append(p.messages, *message)
 	return nil
 }
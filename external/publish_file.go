@@ -0,0 +1,120 @@
+package external
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Appends every message as a JSON line to a file.
+// When the file grows past maxBytes it's rotated to <path>.1, <path>.2... keeping up to maxBackups files.
+type rotatingFilePublisher struct {
+	sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func NewRotatingFilePublisher(path string, maxBytes int64, maxBackups int) (Publisher, error) {
+	publisher := &rotatingFilePublisher{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+
+	if err := publisher.open(); err != nil {
+		return nil, err
+	}
+
+	return publisher, nil
+}
+
+func (p *rotatingFilePublisher) PublishViewModel(viewModel interface{}) error {
+	return p.write(PublishedMessageTypeViewModel, viewModel)
+}
+
+func (p *rotatingFilePublisher) PublishViewModelPatch(patch interface{}) error {
+	return p.write(PublishedMessageTypeViewModelPatch, patch)
+}
+
+func (p *rotatingFilePublisher) write(messageType string, data interface{}) error {
+	message, err := newPublishedMessage(messageType, data)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	line := append(jsonBytes, '\n')
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.maxBytes > 0 && p.size > 0 && p.size+int64(len(line)) > p.maxBytes {
+		if err := p.rotate(); err != nil {
+			return err
+		}
+	}
+
+	written, err := p.file.Write(line)
+	p.size += int64(written)
+	return err
+}
+
+func (p *rotatingFilePublisher) open() error {
+	file, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	p.file = file
+	p.size = info.Size()
+	return nil
+}
+
+func (p *rotatingFilePublisher) rotate() error {
+	if err := p.file.Close(); err != nil {
+		return err
+	}
+
+	if p.maxBackups > 0 {
+		// Shift the existing backups, the oldest one is overwritten
+		for i := p.maxBackups - 1; i > 0; i-- {
+			from := backupPath(p.path, i)
+			if _, err := os.Stat(from); err == nil {
+				if err := os.Rename(from, backupPath(p.path, i+1)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := os.Rename(p.path, backupPath(p.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(p.path); err != nil {
+		return err
+	}
+
+	return p.open()
+}
+
+func (p *rotatingFilePublisher) Close() error {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.file.Close()
+}
+
+func backupPath(path string, index int) string {
+	return fmt.Sprintf("%s.%d", path, index)
+}
@@ -0,0 +1,158 @@
+package external
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Stores the viewmodel in redis and notifies consumers through Pub/Sub:
+// - <prefix>:fixtures hash, holding the JSON of every fixture by fixture id
+// - <prefix>:version, the viewmodel version the stored fixtures correspond to
+// - <prefix>:changes channel, where every published message is sent
+type redisPublisher struct {
+	client    *redisClient
+	keyPrefix string
+}
+
+func NewRedisPublisher(address string, keyPrefix string, timeout time.Duration) Publisher {
+	return &redisPublisher{
+		client:    newRedisClient(address, timeout),
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (p *redisPublisher) Close() error {
+	return p.client.Close()
+}
+
+func (p *redisPublisher) fixturesKey() string {
+	return p.keyPrefix + ":fixtures"
+}
+
+func (p *redisPublisher) versionKey() string {
+	return p.keyPrefix + ":version"
+}
+
+func (p *redisPublisher) changesChannel() string {
+	return p.keyPrefix + ":changes"
+}
+
+// Replace the stored fixtures with the ones in the snapshot
+func (p *redisPublisher) PublishViewModel(viewModel interface{}) error {
+	jsonBytes, err := json.Marshal(viewModel)
+	if err != nil {
+		return err
+	}
+
+	// Fixtures are keyed by id
+	var snapshot struct {
+		Version  uint64                     `json:"version"`
+		Fixtures map[string]json.RawMessage `json:"fixtures"`
+	}
+	if err := json.Unmarshal(jsonBytes, &snapshot); err != nil {
+		return err
+	}
+
+	// Replaced in a transaction, so readers (and a rebuild after a crash) never see a partial viewmodel
+	commands := [][]string{{"DEL", p.fixturesKey()}}
+	if len(snapshot.Fixtures) > 0 {
+		fixtureIds := make([]string, 0, len(snapshot.Fixtures))
+		for fixtureId := range snapshot.Fixtures {
+			fixtureIds = append(fixtureIds, fixtureId)
+		}
+		sort.Strings(fixtureIds)
+
+		args := []string{"HSET", p.fixturesKey()}
+		for _, fixtureId := range fixtureIds {
+			args = append(args, fixtureId, string(snapshot.Fixtures[fixtureId]))
+		}
+		commands = append(commands, args)
+	}
+	commands = append(commands, []string{"SET", p.versionKey(), strconv.FormatUint(snapshot.Version, 10)})
+
+	if _, err := p.client.transaction(commands...); err != nil {
+		return err
+	}
+
+	return p.notify(PublishedMessageTypeViewModel, map[string]uint64{"version": snapshot.Version})
+}
+
+// Patches are only sent as notifications, the stored state is kept up to date by PublishFixture
+func (p *redisPublisher) PublishViewModelPatch(patch interface{}) error {
+	return p.notify(PublishedMessageTypeViewModelPatch, patch)
+}
+
+// Store the current state of a single fixture along with the version, a nil fixture removes it
+func (p *redisPublisher) PublishFixture(version uint64, fixtureId string, fixture interface{}) error {
+	command := []string{"HDEL", p.fixturesKey(), fixtureId}
+	if fixture != nil {
+		jsonBytes, err := json.Marshal(fixture)
+		if err != nil {
+			return err
+		}
+		command = []string{"HSET", p.fixturesKey(), fixtureId, string(jsonBytes)}
+	}
+
+	_, err := p.client.transaction(command, []string{"SET", p.versionKey(), strconv.FormatUint(version, 10)})
+	return err
+}
+
+// Load the stored fixtures (sorted by fixture id), returns nil if nothing has been stored yet
+func (p *redisPublisher) LoadState() (*PublishedState, error) {
+	reply, err := p.client.do("HGETALL", p.fixturesKey())
+	if err != nil {
+		return nil, err
+	}
+
+	values, _ := reply.([]interface{})
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	fixtureIds := make([]string, 0, len(values)/2)
+	fixtures := make(map[string]json.RawMessage)
+	for i := 0; i+1 < len(values); i += 2 {
+		fixtureId, _ := values[i].(string)
+		fixture, _ := values[i+1].(string)
+		fixtureIds = append(fixtureIds, fixtureId)
+		fixtures[fixtureId] = json.RawMessage(fixture)
+	}
+	sort.Strings(fixtureIds)
+
+	state := &PublishedState{
+		Fixtures: make([]json.RawMessage, 0, len(fixtureIds)),
+	}
+	for _, fixtureId := range fixtureIds {
+		state.Fixtures = append(state.Fixtures, fixtures[fixtureId])
+	}
+
+	reply, err = p.client.do("GET", p.versionKey())
+	if err != nil {
+		return nil, err
+	}
+	if version, ok := reply.(string); ok {
+		state.Version, err = strconv.ParseUint(version, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+func (p *redisPublisher) notify(messageType string, data interface{}) error {
+	message, err := newPublishedMessage(messageType, data)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.client.do("PUBLISH", p.changesChannel(), string(jsonBytes))
+	return err
+}
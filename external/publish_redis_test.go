@@ -0,0 +1,328 @@
+package external
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// In-process stand-in for a redis server, supporting the commands used by the redis publisher
+type testRedisServer struct {
+	sync.Mutex
+	listener  net.Listener
+	strings   map[string]string
+	hashes    map[string]map[string]string
+	published map[string][]string
+	// Names of the commands of every executed MULTI/EXEC transaction
+	transactions [][]string
+}
+
+func newTestRedisServer(t *testing.T) *testRedisServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	server := &testRedisServer{
+		listener:  listener,
+		strings:   make(map[string]string),
+		hashes:    make(map[string]map[string]string),
+		published: make(map[string][]string),
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *testRedisServer) address() string {
+	return s.listener.Addr().String()
+}
+
+func (s *testRedisServer) close() {
+	s.listener.Close()
+}
+
+func (s *testRedisServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	// Commands queued by MULTI, nil outside of a transaction
+	var queued [][]string
+
+	for {
+		request, err := readRedisReply(reader)
+		if err != nil {
+			return
+		}
+		values, _ := request.([]interface{})
+		args := make([]string, len(values))
+		for i, value := range values {
+			args[i], _ = value.(string)
+		}
+
+		var reply string
+		switch {
+		case len(args) > 0 && strings.ToUpper(args[0]) == "MULTI":
+			queued = make([][]string, 0)
+			reply = "+OK\r\n"
+		case len(args) > 0 && strings.ToUpper(args[0]) == "EXEC":
+			reply = s.executeTransaction(queued)
+			queued = nil
+		case queued != nil:
+			queued = append(queued, args)
+			reply = "+QUEUED\r\n"
+		default:
+			reply = s.execute(args)
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *testRedisServer) execute(args []string) string {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.apply(args)
+}
+
+// Apply the queued commands at once, nothing is applied if EXEC comes without MULTI
+func (s *testRedisServer) executeTransaction(commands [][]string) string {
+	if commands == nil {
+		return "-ERR EXEC without MULTI\r\n"
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	names := make([]string, len(commands))
+	reply := fmt.Sprintf("*%d\r\n", len(commands))
+	for i, args := range commands {
+		names[i] = strings.ToUpper(args[0])
+		reply += s.apply(args)
+	}
+	s.transactions = append(s.transactions, names)
+	return reply
+}
+
+// Must be called holding the server's lock
+func (s *testRedisServer) apply(args []string) string {
+	if len(args) == 0 {
+		return "-ERR empty command\r\n"
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "SET":
+		s.strings[args[1]] = args[2]
+		return "+OK\r\n"
+	case "GET":
+		value, found := s.strings[args[1]]
+		if !found {
+			return "$-1\r\n"
+		}
+		return bulkString(value)
+	case "DEL":
+		delete(s.strings, args[1])
+		delete(s.hashes, args[1])
+		return ":1\r\n"
+	case "HSET":
+		hash, found := s.hashes[args[1]]
+		if !found {
+			hash = make(map[string]string)
+			s.hashes[args[1]] = hash
+		}
+		for i := 2; i+1 < len(args); i += 2 {
+			hash[args[i]] = args[i+1]
+		}
+		return fmt.Sprintf(":%d\r\n", (len(args)-2)/2)
+	case "HDEL":
+		delete(s.hashes[args[1]], args[2])
+		return ":1\r\n"
+	case "HGETALL":
+		hash := s.hashes[args[1]]
+		reply := fmt.Sprintf("*%d\r\n", len(hash)*2)
+		for field, value := range hash {
+			reply += bulkString(field) + bulkString(value)
+		}
+		return reply
+	case "PUBLISH":
+		s.published[args[1]] = append(s.published[args[1]], args[2])
+		return ":0\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func bulkString(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func TestRedisClient(t *testing.T) {
+
+	t.Run("when server replies with error returns redis error", func(t *testing.T) {
+		server := newTestRedisServer(t)
+		defer server.close()
+		client := newRedisClient(server.address(), time.Second)
+		defer client.Close()
+
+		_, err := client.do("FLUSHALL")
+
+		assert.IsType(t, redisError(""), err)
+	})
+
+	t.Run("when key does not exist returns nil", func(t *testing.T) {
+		server := newTestRedisServer(t)
+		defer server.close()
+		client := newRedisClient(server.address(), time.Second)
+		defer client.Close()
+
+		reply, err := client.do("GET", "missing")
+
+		assert.NoError(t, err)
+		assert.Nil(t, reply)
+	})
+
+	t.Run("when transaction is executed applies every command and returns their replies", func(t *testing.T) {
+		server := newTestRedisServer(t)
+		defer server.close()
+		client := newRedisClient(server.address(), time.Second)
+		defer client.Close()
+
+		replies, err := client.transaction([]string{"SET", "key", "value"}, []string{"GET", "key"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"OK", "value"}, replies)
+		assert.Equal(t, [][]string{{"SET", "GET"}}, server.transactions)
+	})
+
+	t.Run("when a command of a transaction fails returns its redis error", func(t *testing.T) {
+		server := newTestRedisServer(t)
+		defer server.close()
+		client := newRedisClient(server.address(), time.Second)
+		defer client.Close()
+
+		_, err := client.transaction([]string{"FLUSHALL"}, []string{"PING"})
+		reply, pingErr := client.do("PING")
+
+		assert.IsType(t, redisError(""), err)
+		assert.NoError(t, pingErr)
+		assert.Equal(t, "PONG", reply)
+	})
+
+	t.Run("when server is down returns error", func(t *testing.T) {
+		server := newTestRedisServer(t)
+		server.close()
+		client := newRedisClient(server.address(), time.Second)
+
+		_, err := client.do("PING")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestRedisPublisher(t *testing.T) {
+
+	setup := func(t *testing.T) (*testRedisServer, *redisPublisher) {
+		server := newTestRedisServer(t)
+		publisher := NewRedisPublisher(server.address(), "test", time.Second).(*redisPublisher)
+		return server, publisher
+	}
+
+	snapshot := map[string]interface{}{
+		"version": 4,
+		"fixtures": map[string]map[string]string{
+			"F2": {"id": "F2", "title": "Title2"},
+			"F1": {"id": "F1", "title": "Title1"},
+		},
+	}
+
+	t.Run("when publishes viewmodel stores every fixture and notifies", func(t *testing.T) {
+		server, publisher := setup(t)
+		defer server.close()
+
+		err := publisher.PublishViewModel(snapshot)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":"F1","title":"Title1"}`, server.hashes["test:fixtures"]["F1"])
+		assert.Equal(t, `{"id":"F2","title":"Title2"}`, server.hashes["test:fixtures"]["F2"])
+		assert.Equal(t, "4", server.strings["test:version"])
+		assert.Equal(t, []string{`{"type":"viewmodel","data":{"version":4}}`}, server.published["test:changes"])
+		assert.Equal(t, [][]string{{"DEL", "HSET", "SET"}}, server.transactions)
+	})
+
+	t.Run("when publishes patch notifies with the patch", func(t *testing.T) {
+		server, publisher := setup(t)
+		defer server.close()
+
+		err := publisher.PublishViewModelPatch(map[string]int{"version": 5})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`{"type":"viewmodel_patch","data":{"version":5}}`}, server.published["test:changes"])
+	})
+
+	t.Run("when publishes fixture updates the stored fixture and version", func(t *testing.T) {
+		server, publisher := setup(t)
+		defer server.close()
+		_ = publisher.PublishViewModel(snapshot)
+
+		err := publisher.PublishFixture(5, "F1", map[string]string{"id": "F1", "title": "NewTitle"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":"F1","title":"NewTitle"}`, server.hashes["test:fixtures"]["F1"])
+		assert.Equal(t, "5", server.strings["test:version"])
+		assert.Equal(t, []string{"HSET", "SET"}, server.transactions[1])
+	})
+
+	t.Run("when publishes nil fixture removes the stored fixture", func(t *testing.T) {
+		server, publisher := setup(t)
+		defer server.close()
+		_ = publisher.PublishViewModel(snapshot)
+
+		err := publisher.PublishFixture(5, "F1", nil)
+
+		assert.NoError(t, err)
+		_, found := server.hashes["test:fixtures"]["F1"]
+		assert.False(t, found)
+	})
+
+	t.Run("when loads state returns fixtures sorted by id with version", func(t *testing.T) {
+		server, publisher := setup(t)
+		defer server.close()
+		_ = publisher.PublishViewModel(snapshot)
+
+		state, err := publisher.LoadState()
+
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(4), state.Version)
+		assert.Equal(t, 2, len(state.Fixtures))
+		var fixture map[string]string
+		_ = json.Unmarshal(state.Fixtures[0], &fixture)
+		assert.Equal(t, "F1", fixture["id"])
+	})
+
+	t.Run("when nothing stored loads nil state", func(t *testing.T) {
+		server, publisher := setup(t)
+		defer server.close()
+
+		state, err := publisher.LoadState()
+
+		assert.NoError(t, err)
+		assert.Nil(t, state)
+	})
+}
@@ -0,0 +1,185 @@
+package external
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPublisher(t *testing.T) {
+
+	t.Run("when single sink configured returns that sink", func(t *testing.T) {
+		publisher, err := NewPublisher(PublisherConfig{Sinks: []string{PublisherSinkMemory}})
+
+		assert.NoError(t, err)
+		assert.IsType(t, &MemoryPublisher{}, publisher)
+	})
+
+	t.Run("when several sinks configured returns multi publisher", func(t *testing.T) {
+		publisher, err := NewPublisher(PublisherConfig{Sinks: []string{PublisherSinkStdout, PublisherSinkMemory}})
+
+		assert.NoError(t, err)
+		assert.IsType(t, &multiPublisher{}, publisher)
+	})
+
+	t.Run("when no sinks configured returns error", func(t *testing.T) {
+		_, err := NewPublisher(PublisherConfig{})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("when unknown sink configured returns error", func(t *testing.T) {
+		_, err := NewPublisher(PublisherConfig{Sinks: []string{"carrier-pigeon"}})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("when file sink has no path returns error", func(t *testing.T) {
+		_, err := NewPublisher(PublisherConfig{Sinks: []string{PublisherSinkFile}})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("when webhook sink has no url returns error", func(t *testing.T) {
+		_, err := NewPublisher(PublisherConfig{Sinks: []string{PublisherSinkWebhook}})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestPublisherConfigFromEnv(t *testing.T) {
+
+	t.Run("when sinks env set returns configured sinks", func(t *testing.T) {
+		os.Setenv("PUBLISHER_SINKS", "stdout, file")
+		os.Setenv("PUBLISHER_FILE_MAX_BYTES", "1024")
+		defer os.Unsetenv("PUBLISHER_SINKS")
+		defer os.Unsetenv("PUBLISHER_FILE_MAX_BYTES")
+
+		config, err := PublisherConfigFromEnv()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{PublisherSinkStdout, PublisherSinkFile}, config.Sinks)
+		assert.Equal(t, int64(1024), config.FileMaxBytes)
+	})
+
+	t.Run("when sinks env not set defaults to stdout", func(t *testing.T) {
+		config, err := PublisherConfigFromEnv()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{PublisherSinkStdout}, config.Sinks)
+	})
+
+	t.Run("when timeout env invalid returns error", func(t *testing.T) {
+		os.Setenv("PUBLISHER_WEBHOOK_TIMEOUT", "soon")
+		defer os.Unsetenv("PUBLISHER_WEBHOOK_TIMEOUT")
+
+		_, err := PublisherConfigFromEnv()
+
+		assert.Error(t, err)
+	})
+}
+
+func TestWriterPublisher(t *testing.T) {
+
+	t.Run("when publishes viewmodel writes json line", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		publisher := NewWriterPublisher(buffer)
+
+		err := publisher.PublishViewModel(map[string]int{"version": 1})
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{"type":"viewmodel","data":{"version":1}}`+"\n", buffer.String())
+	})
+
+	t.Run("when publishes patch writes json line", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		publisher := NewWriterPublisher(buffer)
+
+		err := publisher.PublishViewModelPatch(map[string]int{"version": 2})
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{"type":"viewmodel_patch","data":{"version":2}}`+"\n", buffer.String())
+	})
+}
+
+func TestMemoryPublisher(t *testing.T) {
+
+	t.Run("when published value changes afterwards keeps published state", func(t *testing.T) {
+		publisher := NewMemoryPublisher()
+		value := map[string]int{"score": 1}
+
+		_ = publisher.PublishViewModel(value)
+		value["score"] = 2
+
+		messages := publisher.Messages()
+		assert.Equal(t, 1, len(messages))
+		assert.Equal(t, `{"score":1}`, string(messages[0].Data))
+	})
+}
+
+func TestMultiPublisher(t *testing.T) {
+
+	t.Run("when publishes writes to every publisher", func(t *testing.T) {
+		first := NewMemoryPublisher()
+		second := NewMemoryPublisher()
+		publisher := NewMultiPublisher(first, second)
+
+		_ = publisher.PublishViewModelPatch("patch")
+
+		assert.Equal(t, 1, len(first.Messages()))
+		assert.Equal(t, 1, len(second.Messages()))
+	})
+}
+
+func TestRotatingFilePublisher(t *testing.T) {
+
+	setup := func(t *testing.T) (string, func()) {
+		dir, err := ioutil.TempDir("", "publisher")
+		assert.NoError(t, err)
+		return filepath.Join(dir, "viewmodel.jsonl"), func() {
+			os.RemoveAll(dir)
+		}
+	}
+
+	t.Run("when publishes appends json lines", func(t *testing.T) {
+		path, tearDown := setup(t)
+		defer tearDown()
+		publisher, _ := NewRotatingFilePublisher(path, 1024, 1)
+
+		_ = publisher.PublishViewModel("first")
+		_ = publisher.PublishViewModelPatch("second")
+
+		content, _ := ioutil.ReadFile(path)
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		assert.Equal(t, 2, len(lines))
+		var message PublishedMessage
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &message))
+		assert.Equal(t, PublishedMessageTypeViewModelPatch, message.Type)
+	})
+
+	t.Run("when file exceeds max bytes rotates and keeps max backups", func(t *testing.T) {
+		path, tearDown := setup(t)
+		defer tearDown()
+		publisher, _ := NewRotatingFilePublisher(path, 10, 2)
+
+		_ = publisher.PublishViewModel("first")
+		_ = publisher.PublishViewModel("second")
+		_ = publisher.PublishViewModel("third")
+		_ = publisher.PublishViewModel("fourth")
+
+		current, _ := ioutil.ReadFile(path)
+		firstBackup, _ := ioutil.ReadFile(backupPath(path, 1))
+		secondBackup, _ := ioutil.ReadFile(backupPath(path, 2))
+		_, err := os.Stat(backupPath(path, 3))
+		assert.Contains(t, string(current), "fourth")
+		assert.Contains(t, string(firstBackup), "third")
+		assert.Contains(t, string(secondBackup), "second")
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,320 @@
+package external
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Zedronar/go-dummy-app.git/external/retry"
+)
+
+const webhookSignatureHeader = "X-Signature-256"
+
+type WebhookConfig struct {
+	URL     string
+	Timeout time.Duration
+
+	// Every body is signed with HMAC-SHA256 when set
+	Secret string
+
+	// Retries use exponential backoff with full jitter, capped at MaxDelay
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+
+	// Messages waiting to be delivered, new messages are dead-lettered when it's full
+	QueueSize int
+
+	// JSON lines file where failed deliveries are written
+	DeadLetterPath string
+}
+
+// Failed webhook delivery, as written to the dead-letter file
+type webhookDeadLetter struct {
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	FailedAt time.Time       `json:"failedAt"`
+}
+
+// POSTs every message as JSON to a webhook url.
+// Deliveries happen in the background (so publishing never waits on the partner's endpoint),
+// are retried with backoff and written to a dead-letter file once all attempts have failed.
+type webhookPublisher struct {
+	sync.Mutex
+	config         WebhookConfig
+	client         *http.Client
+	retryPolicy    retry.Policy
+	deliveries     chan []byte
+	closed         bool
+	workerDone     sync.WaitGroup
+	deadLetterLock sync.Mutex
+}
+
+func NewWebhookPublisher(config WebhookConfig) Publisher {
+	p := newWebhookPublisher(config)
+
+	p.workerDone.Add(1)
+	go p.deliverQueued()
+
+	return p
+}
+
+func newWebhookPublisher(config WebhookConfig) *webhookPublisher {
+	if config.QueueSize < 1 {
+		config.QueueSize = 1
+	}
+
+	return &webhookPublisher{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+		},
+		retryPolicy: retry.Policy{
+			MaxAttempts: config.MaxAttempts,
+			BaseDelay:   config.BaseDelay,
+			MaxDelay:    config.MaxDelay,
+		},
+		deliveries: make(chan []byte, config.QueueSize),
+	}
+}
+
+func (p *webhookPublisher) PublishViewModel(viewModel interface{}) error {
+	return p.enqueue(PublishedMessageTypeViewModel, viewModel)
+}
+
+func (p *webhookPublisher) PublishViewModelPatch(patch interface{}) error {
+	return p.enqueue(PublishedMessageTypeViewModelPatch, patch)
+}
+
+// Stop accepting messages and wait for the queued ones to be delivered (or dead-lettered)
+func (p *webhookPublisher) Close() error {
+	p.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.deliveries)
+	}
+	p.Unlock()
+
+	p.workerDone.Wait()
+	return nil
+}
+
+func (p *webhookPublisher) enqueue(messageType string, data interface{}) error {
+	message, err := newPublishedMessage(messageType, data)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.closed {
+		return errors.New("webhook publisher is closed")
+	}
+
+	select {
+	case p.deliveries <- body:
+		return nil
+	default:
+		err := errors.New("webhook delivery queue is full")
+		p.writeDeadLetter(p.config.URL, body, 0, err)
+		return err
+	}
+}
+
+func (p *webhookPublisher) deliverQueued() {
+	defer p.workerDone.Done()
+
+	for body := range p.deliveries {
+		if attempts, err := p.deliver(p.config.URL, body); err != nil {
+			log.Print(fmt.Sprintf("@deliverQueued -> webhook delivery failed: %s", err.Error()))
+			p.writeDeadLetter(p.config.URL, body, attempts, err)
+		}
+	}
+}
+
+// Try to deliver the body, returns the number of attempts made
+func (p *webhookPublisher) deliver(url string, body []byte) (int, error) {
+	attempts := 0
+	err := p.retryPolicy.Do(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return p.send(url, body)
+	})
+	return attempts, err
+}
+
+func (p *webhookPublisher) send(url string, body []byte) error {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if p.config.Secret != "" {
+		request.Header.Set(webhookSignatureHeader, SignWebhookBody(p.config.Secret, body))
+	}
+
+	resp, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+		// Client errors won't go away by retrying, except for timeouts and rate limiting
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			return retry.Permanent(err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (p *webhookPublisher) writeDeadLetter(url string, body []byte, attempts int, deliveryErr error) {
+	if p.config.DeadLetterPath == "" {
+		return
+	}
+
+	p.deadLetterLock.Lock()
+	defer p.deadLetterLock.Unlock()
+
+	err := appendDeadLetters(p.config.DeadLetterPath, []webhookDeadLetter{{
+		URL:      url,
+		Body:     body,
+		Attempts: attempts,
+		Error:    deliveryErr.Error(),
+		FailedAt: time.Now().UTC(),
+	}})
+	if err != nil {
+		log.Print(fmt.Sprintf("@writeDeadLetter -> error writing dead letter: %s", err.Error()))
+	}
+}
+
+// Signature sent in the X-Signature-256 header, so partners can verify the body came from us
+func SignWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Re-deliver the dead-lettered webhooks. The dead-letter file is moved aside first, so letters written
+// by a running publisher during the replay are kept for the next one. The ones failing again are
+// appended back to the dead-letter file.
+// A replay that was interrupted is resumed first, its letters may be delivered twice.
+func ReplayWebhookDeadLetters(config WebhookConfig) (delivered int, failed int, err error) {
+	if config.DeadLetterPath == "" {
+		return 0, 0, errors.New("no dead-letter file configured")
+	}
+
+	replayPath := config.DeadLetterPath + ".replay"
+	if _, err := os.Stat(replayPath); os.IsNotExist(err) {
+		if err := os.Rename(config.DeadLetterPath, replayPath); err != nil {
+			if os.IsNotExist(err) {
+				return 0, 0, nil
+			}
+			return 0, 0, err
+		}
+	} else if err != nil {
+		return 0, 0, err
+	}
+
+	deadLetters, err := readDeadLetters(replayPath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	p := newWebhookPublisher(config)
+	remaining := make([]webhookDeadLetter, 0)
+	for _, deadLetter := range deadLetters {
+		url := deadLetter.URL
+		if url == "" {
+			url = config.URL
+		}
+		attempts, err := p.deliver(url, deadLetter.Body)
+		if err != nil {
+			deadLetter.Attempts += attempts
+			deadLetter.Error = err.Error()
+			deadLetter.FailedAt = time.Now().UTC()
+			remaining = append(remaining, deadLetter)
+			continue
+		}
+		delivered++
+	}
+
+	if err := appendDeadLetters(config.DeadLetterPath, remaining); err != nil {
+		return delivered, len(remaining), err
+	}
+	if err := os.Remove(replayPath); err != nil {
+		return delivered, len(remaining), err
+	}
+
+	return delivered, len(remaining), nil
+}
+
+func appendDeadLetters(path string, deadLetters []webhookDeadLetter) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for _, deadLetter := range deadLetters {
+		jsonBytes, err := json.Marshal(deadLetter)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(append(jsonBytes, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readDeadLetters(path string) ([]webhookDeadLetter, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	deadLetters := make([]webhookDeadLetter, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var deadLetter webhookDeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &deadLetter); err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, scanner.Err()
+}
@@ -0,0 +1,241 @@
+package external
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testWebhookServer struct {
+	sync.Mutex
+	*httptest.Server
+	failures   int
+	requests   int
+	bodies     []string
+	signatures []string
+	// Called on every request, before it's answered
+	onRequest func()
+}
+
+// Webhook endpoint failing the first given number of requests
+func newTestWebhookServer(failures int) *testWebhookServer {
+	server := &testWebhookServer{failures: failures}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.Lock()
+		defer server.Unlock()
+
+		body, _ := ioutil.ReadAll(r.Body)
+		server.requests++
+		if server.onRequest != nil {
+			server.onRequest()
+		}
+		if server.requests <= server.failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		server.bodies = append(server.bodies, string(body))
+		server.signatures = append(server.signatures, r.Header.Get(webhookSignatureHeader))
+	}))
+	return server
+}
+
+func TestWebhookPublisher(t *testing.T) {
+
+	setup := func(t *testing.T, url string) (WebhookConfig, func()) {
+		dir, err := ioutil.TempDir("", "webhook")
+		assert.NoError(t, err)
+		config := WebhookConfig{
+			URL:            url,
+			Timeout:        time.Second,
+			Secret:         "secret",
+			MaxAttempts:    3,
+			BaseDelay:      time.Millisecond,
+			MaxDelay:       5 * time.Millisecond,
+			QueueSize:      10,
+			DeadLetterPath: filepath.Join(dir, "dead-letters.jsonl"),
+		}
+		return config, func() {
+			os.RemoveAll(dir)
+		}
+	}
+
+	t.Run("when publishes posts signed json message", func(t *testing.T) {
+		server := newTestWebhookServer(0)
+		defer server.Close()
+		config, tearDown := setup(t, server.URL)
+		defer tearDown()
+		publisher := NewWebhookPublisher(config).(*webhookPublisher)
+
+		err := publisher.PublishViewModelPatch(map[string]int{"version": 3})
+		publisher.Close()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(server.bodies))
+		assert.Equal(t, `{"type":"viewmodel_patch","data":{"version":3}}`, server.bodies[0])
+		assert.Equal(t, SignWebhookBody("secret", []byte(server.bodies[0])), server.signatures[0])
+	})
+
+	t.Run("when webhook fails transiently retries until delivered", func(t *testing.T) {
+		server := newTestWebhookServer(2)
+		defer server.Close()
+		config, tearDown := setup(t, server.URL)
+		defer tearDown()
+		publisher := NewWebhookPublisher(config).(*webhookPublisher)
+
+		_ = publisher.PublishViewModel("snapshot")
+		publisher.Close()
+
+		assert.Equal(t, 3, server.requests)
+		assert.Equal(t, 1, len(server.bodies))
+		_, err := os.Stat(config.DeadLetterPath)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("when all attempts fail writes dead letter", func(t *testing.T) {
+		server := newTestWebhookServer(100)
+		defer server.Close()
+		config, tearDown := setup(t, server.URL)
+		defer tearDown()
+		publisher := NewWebhookPublisher(config).(*webhookPublisher)
+
+		_ = publisher.PublishViewModel("snapshot")
+		publisher.Close()
+
+		deadLetters, err := readDeadLetters(config.DeadLetterPath)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, server.requests)
+		assert.Equal(t, 1, len(deadLetters))
+		assert.Equal(t, server.URL, deadLetters[0].URL)
+		assert.Equal(t, 3, deadLetters[0].Attempts)
+		assert.Equal(t, `{"type":"viewmodel","data":"snapshot"}`, string(deadLetters[0].Body))
+	})
+
+	t.Run("when publisher closed returns error", func(t *testing.T) {
+		config, tearDown := setup(t, "http://localhost")
+		defer tearDown()
+		publisher := NewWebhookPublisher(config).(*webhookPublisher)
+		publisher.Close()
+
+		err := publisher.PublishViewModel("snapshot")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestReplayWebhookDeadLetters(t *testing.T) {
+
+	setup := func(t *testing.T, deadLetters ...webhookDeadLetter) (WebhookConfig, func()) {
+		dir, err := ioutil.TempDir("", "webhook")
+		assert.NoError(t, err)
+		config := WebhookConfig{
+			Timeout:        time.Second,
+			MaxAttempts:    1,
+			DeadLetterPath: filepath.Join(dir, "dead-letters.jsonl"),
+		}
+		assert.NoError(t, appendDeadLetters(config.DeadLetterPath, deadLetters))
+		return config, func() {
+			os.RemoveAll(dir)
+		}
+	}
+
+	t.Run("when deliveries succeed empties the dead-letter file", func(t *testing.T) {
+		server := newTestWebhookServer(0)
+		defer server.Close()
+		config, tearDown := setup(t,
+			webhookDeadLetter{URL: server.URL, Body: json.RawMessage(`{"n":1}`)},
+			webhookDeadLetter{URL: server.URL, Body: json.RawMessage(`{"n":2}`)},
+		)
+		defer tearDown()
+
+		delivered, failed, err := ReplayWebhookDeadLetters(config)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, delivered)
+		assert.Equal(t, 0, failed)
+		assert.Equal(t, []string{`{"n":1}`, `{"n":2}`}, server.bodies)
+		remaining, _ := readDeadLetters(config.DeadLetterPath)
+		assert.Equal(t, 0, len(remaining))
+	})
+
+	t.Run("when deliveries fail again keeps them in the dead-letter file", func(t *testing.T) {
+		server := newTestWebhookServer(1)
+		defer server.Close()
+		config, tearDown := setup(t,
+			webhookDeadLetter{URL: server.URL, Body: json.RawMessage(`{"n":1}`), Attempts: 3},
+			webhookDeadLetter{URL: server.URL, Body: json.RawMessage(`{"n":2}`), Attempts: 3},
+		)
+		defer tearDown()
+
+		delivered, failed, err := ReplayWebhookDeadLetters(config)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		assert.Equal(t, 1, failed)
+		remaining, _ := readDeadLetters(config.DeadLetterPath)
+		assert.Equal(t, 1, len(remaining))
+		assert.Equal(t, `{"n":1}`, string(remaining[0].Body))
+		assert.Equal(t, 4, remaining[0].Attempts)
+	})
+
+	t.Run("when letters are dead-lettered during the replay keeps them in the dead-letter file", func(t *testing.T) {
+		server := newTestWebhookServer(0)
+		defer server.Close()
+		config, tearDown := setup(t, webhookDeadLetter{URL: server.URL, Body: json.RawMessage(`{"n":1}`)})
+		defer tearDown()
+		server.onRequest = func() {
+			_ = appendDeadLetters(config.DeadLetterPath, []webhookDeadLetter{{URL: server.URL, Body: json.RawMessage(`{"n":2}`)}})
+		}
+
+		delivered, failed, err := ReplayWebhookDeadLetters(config)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		assert.Equal(t, 0, failed)
+		remaining, _ := readDeadLetters(config.DeadLetterPath)
+		assert.Equal(t, 1, len(remaining))
+		assert.Equal(t, `{"n":2}`, string(remaining[0].Body))
+		_, statErr := os.Stat(config.DeadLetterPath + ".replay")
+		assert.True(t, os.IsNotExist(statErr))
+	})
+
+	t.Run("when a replay was interrupted resumes it", func(t *testing.T) {
+		server := newTestWebhookServer(0)
+		defer server.Close()
+		config, tearDown := setup(t, webhookDeadLetter{URL: server.URL, Body: json.RawMessage(`{"n":2}`)})
+		defer tearDown()
+		_ = appendDeadLetters(config.DeadLetterPath+".replay", []webhookDeadLetter{{URL: server.URL, Body: json.RawMessage(`{"n":1}`)}})
+
+		delivered, _, err := ReplayWebhookDeadLetters(config)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		assert.Equal(t, []string{`{"n":1}`}, server.bodies)
+		remaining, _ := readDeadLetters(config.DeadLetterPath)
+		assert.Equal(t, 1, len(remaining))
+	})
+
+	t.Run("when no dead-letter file exists replays nothing", func(t *testing.T) {
+		config, tearDown := setup(t)
+		defer tearDown()
+		_ = os.Remove(config.DeadLetterPath)
+
+		delivered, failed, err := ReplayWebhookDeadLetters(config)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, delivered+failed)
+	})
+
+	t.Run("when no dead-letter file configured returns error", func(t *testing.T) {
+		_, _, err := ReplayWebhookDeadLetters(WebhookConfig{})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,232 @@
+package external
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Error reply returned by the redis server
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// Minimal RESP (redis protocol) client, enough for the commands used by the redis publisher.
+// Commands (or transactions) are sent one at a time over a single connection, which is re-dialled
+// after any network error.
+type redisClient struct {
+	sync.Mutex
+	address string
+	timeout time.Duration
+	conn    net.Conn
+	reader  *bufio.Reader
+}
+
+func newRedisClient(address string, timeout time.Duration) *redisClient {
+	return &redisClient{
+		address: address,
+		timeout: timeout,
+	}
+}
+
+// Send a command and return its reply: string, int64, []interface{}, nil or a redisError
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	reply, err := c.roundTrip(args)
+	if err != nil {
+		if _, isReplyError := err.(redisError); !isReplyError {
+			// The connection state is unknown, start over with a new one
+			c.disconnect()
+		}
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// Send the commands in a MULTI/EXEC transaction, so they're applied all at once or not at all, and
+// return their replies. The commands are pipelined in a single write.
+func (c *redisClient) transaction(commands ...[]string) ([]interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	request := encodeRedisCommand([]string{"MULTI"})
+	for _, command := range commands {
+		request = append(request, encodeRedisCommand(command)...)
+	}
+	request = append(request, encodeRedisCommand([]string{"EXEC"})...)
+
+	replies, err := c.exchangeTransaction(request, len(commands))
+	if err != nil {
+		// Replies may be left unread, and the server discards a transaction that isn't executed when
+		// its connection is closed
+		c.disconnect()
+		return nil, err
+	}
+
+	for _, reply := range replies {
+		if replyErr, isReplyError := reply.(redisError); isReplyError {
+			return replies, replyErr
+		}
+	}
+	return replies, nil
+}
+
+func (c *redisClient) exchangeTransaction(request []byte, commandCount int) ([]interface{}, error) {
+	if _, err := c.conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	// MULTI and every command are acknowledged (+OK, then +QUEUED) before EXEC replies
+	for i := 0; i <= commandCount; i++ {
+		if _, err := readRedisReply(c.reader); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := readRedisReply(c.reader)
+	if err != nil {
+		return nil, err
+	}
+	replies, ok := reply.([]interface{})
+	if !ok {
+		// A nil reply means the transaction was aborted
+		return nil, errors.New("redis transaction aborted")
+	}
+	return replies, nil
+}
+
+// Must be called holding the client's lock
+func (c *redisClient) connect() error {
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.address, c.timeout)
+		if err != nil {
+			return err
+		}
+		c.conn = conn
+		c.reader = bufio.NewReader(conn)
+	}
+
+	if c.timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	return nil
+}
+
+// Must be called holding the client's lock
+func (c *redisClient) disconnect() {
+	c.conn.Close()
+	c.conn = nil
+}
+
+func (c *redisClient) roundTrip(args []string) (interface{}, error) {
+	if _, err := c.conn.Write(encodeRedisCommand(args)); err != nil {
+		return nil, err
+	}
+	return readRedisReply(c.reader)
+}
+
+func (c *redisClient) Close() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Commands are sent as an array of bulk strings
+func encodeRedisCommand(args []string) []byte {
+	command := []byte(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		command = append(command, fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)...)
+	}
+	return command
+}
+
+func readRedisReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := readRedisLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:length]), nil
+	case '*':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, length)
+		for i := range values {
+			value, err := readRedisReply(reader)
+			if err != nil {
+				// Error replies nested in arrays are returned as values
+				if replyErr, isReplyError := err.(redisError); isReplyError {
+					values[i] = replyErr
+					continue
+				}
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unexpected redis reply '%s'", line)
+	}
+}
+
+func readRedisLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("malformed redis line '%s'", line)
+	}
+	return line[:len(line)-2], nil
+}
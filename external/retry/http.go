@@ -0,0 +1,53 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// Returned for non-2xx responses
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("GET %s responded with status %d", e.URL, e.StatusCode)
+}
+
+// GET the url and return the response body, retrying on network errors and 5xx responses
+func Get(ctx context.Context, client *http.Client, policy Policy, url string) ([]byte, error) {
+	var body []byte
+
+	err := policy.Do(ctx, func(ctx context.Context) error {
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return Permanent(err)
+		}
+
+		resp, err := client.Do(request.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			statusErr := &StatusError{URL: url, StatusCode: resp.StatusCode}
+			if resp.StatusCode >= 500 {
+				return statusErr
+			}
+			return Permanent(statusErr)
+		}
+
+		body, err = ioutil.ReadAll(resp.Body)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
@@ -0,0 +1,161 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+
+	policy := Policy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    4 * time.Millisecond,
+	}
+
+	t.Run("when operation succeeds does not retry", func(t *testing.T) {
+		attempts := 0
+
+		err := policy.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("when operation fails transiently retries until it succeeds", func(t *testing.T) {
+		attempts := 0
+
+		err := policy.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("transient")
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("when operation keeps failing returns typed error after max attempts", func(t *testing.T) {
+		attempts := 0
+		operationErr := errors.New("down")
+
+		err := policy.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return operationErr
+		})
+
+		var retryErr *Error
+		assert.True(t, errors.As(err, &retryErr))
+		assert.Equal(t, 3, retryErr.Attempts)
+		assert.True(t, errors.Is(err, operationErr))
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("when operation fails permanently does not retry", func(t *testing.T) {
+		attempts := 0
+		operationErr := errors.New("bad request")
+
+		err := policy.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return Permanent(operationErr)
+		})
+
+		var retryErr *Error
+		assert.True(t, errors.As(err, &retryErr))
+		assert.Equal(t, 1, retryErr.Attempts)
+		assert.Equal(t, operationErr, retryErr.Err)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("when context is cancelled stops retrying", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		attempts := 0
+
+		err := Policy{MaxAttempts: 5, BaseDelay: time.Hour}.Do(ctx, func(ctx context.Context) error {
+			attempts++
+			cancel()
+			return errors.New("transient")
+		})
+
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("when backoff called delay is capped by max delay", func(t *testing.T) {
+		for attempt := 1; attempt < 100; attempt++ {
+			delay := policy.Backoff(attempt)
+
+			assert.True(t, delay >= 0)
+			assert.True(t, delay < policy.MaxDelay)
+		}
+	})
+
+	t.Run("when backoff called without delays returns zero", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), Policy{}.Backoff(3))
+	})
+}
+
+func TestGet(t *testing.T) {
+
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	setup := func(statuses ...int) (*httptest.Server, *int) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status := statuses[len(statuses)-1]
+			if requests < len(statuses) {
+				status = statuses[requests]
+			}
+			requests++
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("body"))
+		}))
+		return server, &requests
+	}
+
+	t.Run("when server responds with 5xx retries", func(t *testing.T) {
+		server, requests := setup(http.StatusBadGateway, http.StatusOK)
+		defer server.Close()
+
+		body, err := Get(context.Background(), http.DefaultClient, policy, server.URL)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "body", string(body))
+		assert.Equal(t, 2, *requests)
+	})
+
+	t.Run("when server responds with 4xx does not retry", func(t *testing.T) {
+		server, requests := setup(http.StatusNotFound)
+		defer server.Close()
+
+		_, err := Get(context.Background(), http.DefaultClient, policy, server.URL)
+
+		var statusErr *StatusError
+		assert.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+		assert.Equal(t, 1, *requests)
+	})
+
+	t.Run("when server keeps failing returns typed error", func(t *testing.T) {
+		server, requests := setup(http.StatusServiceUnavailable)
+		defer server.Close()
+
+		_, err := Get(context.Background(), http.DefaultClient, policy, server.URL)
+
+		var retryErr *Error
+		assert.True(t, errors.As(err, &retryErr))
+		assert.Equal(t, 3, retryErr.Attempts)
+		assert.Equal(t, 3, *requests)
+	})
+}
@@ -23,6 +23,9 @@ type fixture struct {
 	Tournament         fixtureTournament `json:"tournament"`
 	Teams              []fixtureTeam     `json:"teams"`
 	ScheduledStartTime int64             `json:"scheduledStartTimeUnixSeconds"`
+	Rules              *MatchRules       `json:"rules,omitempty"`
+	// Set for free-for-all fixtures, which are ranked by placement and kills instead of playing maps
+	Points *PointsTable `json:"points,omitempty"`
 }
 
 type staticDataServer struct {
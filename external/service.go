@@ -1,17 +1,64 @@
 package external
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 )
 
-func init() {
-	seedTime := time.Now().UTC()
-	fixtures := buildFixtures(seedTime, fixtureConfigs)
+// Configuration of the random live scores
+type SimulationConfig struct {
+	// Seed of the publisher's decisions, a run with the same seed publishes the same updates
+	Seed int64
+	// Start of the simulated clock the fixtures are scheduled from and the updates are emitted at,
+	// so a run replayed from the same start has the same fixtures and emission times
+	StartTime time.Time
+}
+
+// Read the simulation configuration from the environment: SIMULATION_SEED, a new seed (the current
+// time) is used when it's not set, and SIMULATION_START_TIME (Unix seconds), the current time when
+// it's not set.
+func SimulationConfigFromEnv() (SimulationConfig, error) {
+	now := time.Now().UTC()
+	config := SimulationConfig{
+		Seed:      now.UnixNano(),
+		StartTime: now.Truncate(time.Second),
+	}
+
+	if value := os.Getenv("SIMULATION_SEED"); value != "" {
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return config, fmt.Errorf("invalid SIMULATION_SEED: %s", err.Error())
+		}
+		config.Seed = seed
+	}
+
+	if value := os.Getenv("SIMULATION_START_TIME"); value != "" {
+		startTime, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return config, fmt.Errorf("invalid SIMULATION_START_TIME: %s", err.Error())
+		}
+		config.StartTime = time.Unix(startTime, 0).UTC()
+	}
+
+	return config, nil
+}
+
+// Start the static data server, and the random live scores published to the bus
+func StartTestServer(bus *EventBus, simulationConfig SimulationConfig) {
+	startTime := simulationConfig.StartTime
+	fixtures := buildFixtures(startTime, fixtureConfigs)
 	server := newStaticDataServer(fixtures)
-	decisionProvider := newDecisionProvider()
-	publisher := newRandomLiveScorePublisher(fixtures, time.Second*1, decisionProvider, 10)
+	decisionProvider := newDecisionProvider(simulationConfig.Seed)
+	log.Println(fmt.Sprintf("simulating live scores with seed %d from %d, set SIMULATION_SEED and SIMULATION_START_TIME to replay them",
+		decisionProvider.Seed(), startTime.Unix()))
+	// Every seed is a new producer, so a run's sequences aren't mistaken for another run's. A replayed
+	// run is the same producer as the original one.
+	producerId := fmt.Sprintf("random-live-scores-%d", simulationConfig.Seed)
+	publisher := newRandomLiveScorePublisher(fixtures, time.Second*1, decisionProvider, FirstTo(10), bus, producerId, startTime)
 	publisher.StartRandomPublish()
 
 	// 1- TODO: Having all routes declared on the same place
@@ -29,24 +76,33 @@ func init() {
 type fixtureConfiguration struct {
 	offset time.Duration
 	values []string
+	rules  MatchRules
+	points *PointsTable
 }
 
 var fixtureConfigs = []*fixtureConfiguration{
 	{
 		offset: -15 * time.Minute,
 		values: []string{"F1", "Title1", "TO1", "Tournament1", "TE1", "Team1", "TE2", "Team2"},
+		rules:  FirstTo(10),
 	},
 	{
 		offset: -5 * time.Minute,
 		values: []string{"F2", "Title1", "TO1", "Tournament1", "TE3", "Team3", "TE4", "Team4"},
+		// Best-of-3, maps to 13 rounds with overtime at 12-12
+		rules: MatchRules{BestOf: 3, MapScoreLimit: 13, OvertimeWinBy: 2},
 	},
 	{
 		offset: -1 * time.Minute,
 		values: []string{"F3", "Title2", "TO2", "Tournament2", "TE5", "Team5", "TE6", "Team6", "TE7", "Team7", "TE8", "Team8"},
+		// Battle royale, ranked by placement and kills
+		points: &PointsTable{PlacementPoints: []int{10, 6, 3, 1}, KillPoints: 1},
 	},
 	{
 		offset: 85 * time.Minute,
 		values: []string{"F4", "Title1", "TO1", "Tournament1", "TE2", "Team2", "TE3", "Team3"},
+		// Best-of-2 group stage match, maps to 16 rounds, 15-15 maps and 1-1 series are draws
+		rules: MatchRules{BestOf: 2, MapScoreLimit: 16, AllowDraws: true},
 	},
 }
 
@@ -73,6 +129,11 @@ func buildFixture(seedTime time.Time, fixtureConfig *fixtureConfiguration) *fixt
 		})
 	}
 
+	var rules *MatchRules
+	if fixtureConfig.rules.Valid() {
+		rules = &fixtureConfig.rules
+	}
+
 	return &fixture{
 		Id:    vals[0],
 		Title: vals[1],
@@ -82,5 +143,7 @@ func buildFixture(seedTime time.Time, fixtureConfig *fixtureConfiguration) *fixt
 		},
 		Teams:              teams,
 		ScheduledStartTime: seedTime.Add(fixtureConfig.offset).Unix(),
+		Rules:              rules,
+		Points:             fixtureConfig.points,
 	}
 }
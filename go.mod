@@ -1,5 +1,9 @@
-module "github.com/Zedronar/go-dummy-app.git"
+module github.com/Zedronar/go-dummy-app.git
 
 go 1.13
 
-require github.com/stretchr/testify v1.5.1
+require (
+	github.com/gorilla/websocket v1.4.2
+	github.com/stretchr/testify v1.5.1
+	gopkg.in/yaml.v2 v2.2.2
+)
@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Requests changing the live data (fixtures, teams, winners, statuses and brackets) must carry the
+// admin token as "Authorization: Bearer <token>". Without a configured token they're refused,
+// so the mutation API is never open by accident. Reads stay public.
+func (server *LiveDataServer) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		if server.adminToken == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		authorization := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		if !strings.HasPrefix(authorization, "Bearer ") || subtle.ConstantTimeCompare([]byte(token), []byte(server.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAdminToken = "admin-token"
+
+func TestRequireAdmin(t *testing.T) {
+
+	setup := func(adminToken string) *LiveDataServer {
+		viewModel := &ViewModel{fixture{Id: "F1", Tournament: fixtureTournament{Id: "T1"}, Teams: []fixtureTeam{{Id: "TE1"}}}}
+		server := newLiveDataServer(viewModel, external.NewMemoryPublisher())
+		server.adminToken = adminToken
+		return server
+	}
+
+	request := func(server *LiveDataServer, method string, path string, authorization string) int {
+		recorder := httptest.NewRecorder()
+		httpRequest := httptest.NewRequest(method, path, strings.NewReader(`{"id":"F2"}`))
+		if authorization != "" {
+			httpRequest.Header.Set("Authorization", authorization)
+		}
+		server.handler().ServeHTTP(recorder, httpRequest)
+		return recorder.Code
+	}
+
+	t.Run("when a mutation comes without the admin token it should be refused", func(t *testing.T) {
+		// Arrange
+		server := setup(testAdminToken)
+
+		// Act
+		missing := request(server, http.MethodPost, "/livedata/fixtures", "")
+		wrong := request(server, http.MethodDelete, "/livedata/fixtures/F1", "Bearer wrong-token")
+		bare := request(server, http.MethodDelete, "/livedata/fixtures/F1", testAdminToken)
+		bracket := request(server, http.MethodPost, "/tournaments/T1/bracket", "")
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, missing)
+		assert.Equal(t, http.StatusUnauthorized, wrong)
+		assert.Equal(t, http.StatusUnauthorized, bare)
+		assert.Equal(t, http.StatusUnauthorized, bracket)
+		assert.NotNil(t, server.findFixture("F1"))
+		assert.Equal(t, uint64(0), server.currentVersion())
+	})
+
+	t.Run("when a mutation comes with the admin token it should be applied", func(t *testing.T) {
+		// Arrange
+		server := setup(testAdminToken)
+
+		// Act
+		created := request(server, http.MethodPost, "/livedata/fixtures", "Bearer "+testAdminToken)
+
+		// Assert
+		assert.Equal(t, http.StatusCreated, created)
+		assert.NotNil(t, server.findFixture("F2"))
+	})
+
+	t.Run("when no admin token is configured it should refuse every mutation but still serve reads", func(t *testing.T) {
+		// Arrange
+		server := setup("")
+
+		// Act
+		created := request(server, http.MethodPost, "/livedata/fixtures", "Bearer ")
+		standings := request(server, http.MethodGet, "/tournaments/T1/standings", "")
+
+		// Assert
+		assert.Equal(t, http.StatusForbidden, created)
+		assert.Equal(t, http.StatusOK, standings)
+	})
+}
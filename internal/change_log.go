@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"sync/atomic"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+)
+
+const (
+	changeTypeScore          = "score"
+	changeTypeWinner         = "winner"
+	changeTypeStatus         = "status"
+	changeTypePlacement      = "placement"
+	changeTypeFixtureAdded   = "fixture_added"
+	changeTypeFixtureUpdated = "fixture_updated"
+	changeTypeFixtureRemoved = "fixture_removed"
+	changeTypeTeamAdded      = "team_added"
+	changeTypeTeamUpdated    = "team_updated"
+	changeTypeTeamRemoved    = "team_removed"
+
+	changeLogCapacity = 1024
+)
+
+// A single mutation applied to the viewmodel
+type liveDataChange struct {
+	Version   uint64 `json:"version"`
+	Type      string `json:"type"`
+	FixtureId string `json:"fixtureId"`
+	TeamId    string `json:"teamId"`
+	Score     *int   `json:"score,omitempty"`
+
+	// Set when the fixture's status changes (including scores starting and winners finishing it)
+	Status fixtureStatus `json:"status,omitempty"`
+
+	// Set on scores of fixtures with match rules: the map scored on, and once it's decided the
+	// series (and its winner, if the series is over)
+	Map           int            `json:"map,omitempty"`
+	Series        *fixtureSeries `json:"series,omitempty"`
+	WinningTeamId string         `json:"winningTeamId,omitempty"`
+
+	// Set on placements of free-for-all fixtures (the team placed first is also the winner)
+	Placement int `json:"placement,omitempty"`
+
+	// Set when a fixture/team is added or updated
+	Fixture *fixture     `json:"fixture,omitempty"`
+	Team    *fixtureTeam `json:"team,omitempty"`
+
+	// Envelope of the live update the change applies, sent to the websocket clients (stream clients
+	// get the version as event id)
+	envelope external.EventEnvelope
+}
+
+// Bounded log holding the most recent viewmodel changes. Appends (under the server's changeLock)
+// publish an immutable snapshot, so readers never take a lock: the changes are only ever appended
+// to the backing array, which is copied once it's full, and the snapshots don't share its free slots.
+type changeLog struct {
+	capacity int
+	// Every change since the backing array was last copied, the log holds the last capacity ones
+	changes []liveDataChange
+	// Version the log starts after, e.g. the version of a viewmodel rebuilt from a store. The changes
+	// up to it were never logged.
+	startVersion uint64
+	snapshot     atomic.Value
+}
+
+// Changes logged up to a version, safe to read without any lock
+type changeLogSnapshot struct {
+	// Oldest first
+	changes      []liveDataChange
+	startVersion uint64
+	// Version of the last logged change (or the start version)
+	version uint64
+}
+
+func newChangeLog(capacity int) *changeLog {
+	changes := &changeLog{
+		capacity: capacity,
+	}
+	changes.startAfter(0)
+	return changes
+}
+
+// Must be called holding the server's changeLock, like startAfter
+func (changes *changeLog) append(change liveDataChange) {
+	if changes.capacity == 0 {
+		return
+	}
+
+	if len(changes.changes) == cap(changes.changes) {
+		// Backing array is full, copy the changes that are kept to a new one
+		kept := make([]liveDataChange, changes.capacity-1, 2*changes.capacity)
+		copy(kept, changes.changes[len(changes.changes)-changes.capacity+1:])
+		changes.changes = kept
+	}
+	changes.changes = append(changes.changes, change)
+
+	start := 0
+	if len(changes.changes) > changes.capacity {
+		start = len(changes.changes) - changes.capacity
+	}
+	end := len(changes.changes)
+	changes.snapshot.Store(&changeLogSnapshot{
+		changes:      changes.changes[start:end:end],
+		startVersion: changes.startVersion,
+		version:      change.Version,
+	})
+}
+
+// Start logging after the given version, forgetting the logged changes
+func (changes *changeLog) startAfter(version uint64) {
+	changes.changes = make([]liveDataChange, 0, 2*changes.capacity)
+	changes.startVersion = version
+	changes.snapshot.Store(&changeLogSnapshot{startVersion: version, version: version})
+}
+
+// Returns the logged changes, safe to call without holding any lock
+func (changes *changeLog) load() *changeLogSnapshot {
+	return changes.snapshot.Load().(*changeLogSnapshot)
+}
+
+// Returns the changes applied after the given version, see changeLogSnapshot.since
+func (changes *changeLog) since(version uint64) ([]liveDataChange, bool) {
+	return changes.load().since(version)
+}
+
+// Returns the changes applied after the given version.
+// The second return value is false when some of those changes have already been evicted, or
+// were never logged.
+func (snapshot *changeLogSnapshot) since(version uint64) ([]liveDataChange, bool) {
+	if version < snapshot.startVersion {
+		return nil, false
+	}
+	if len(snapshot.changes) == 0 {
+		return nil, true
+	}
+
+	oldest := snapshot.changes[0].Version
+	if version+1 < oldest {
+		return nil, false
+	}
+
+	result := make([]liveDataChange, 0)
+	for _, change := range snapshot.changes {
+		if change.Version > version {
+			result = append(result, change)
+		}
+	}
+
+	return result, true
+}
@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeLog(t *testing.T) {
+
+	setup := func(capacity int, versions ...uint64) *changeLog {
+		changes := newChangeLog(capacity)
+		for _, version := range versions {
+			changes.append(liveDataChange{Version: version})
+		}
+		return changes
+	}
+
+	t.Run("when since is called it should return the changes after the given version", func(t *testing.T) {
+		// Arrange
+		changes := setup(3, 1, 2, 3)
+
+		// Act
+		result, ok := changes.since(1)
+
+		// Assert
+		assert.True(t, ok)
+		assert.Equal(t, 2, len(result))
+		assert.Equal(t, uint64(2), result[0].Version)
+		assert.Equal(t, uint64(3), result[1].Version)
+	})
+
+	t.Run("when since is called with the latest version it should return no changes", func(t *testing.T) {
+		// Arrange
+		changes := setup(3, 1, 2, 3)
+
+		// Act
+		result, ok := changes.since(3)
+
+		// Assert
+		assert.True(t, ok)
+		assert.Equal(t, 0, len(result))
+	})
+
+	t.Run("when the log is full it should evict the oldest changes", func(t *testing.T) {
+		// Arrange
+		changes := setup(2, 1, 2, 3, 4)
+
+		// Act
+		evicted, evictedOk := changes.since(1)
+		result, ok := changes.since(2)
+
+		// Assert
+		assert.False(t, evictedOk)
+		assert.Nil(t, evicted)
+		assert.True(t, ok)
+		assert.Equal(t, 2, len(result))
+		assert.Equal(t, uint64(3), result[0].Version)
+		assert.Equal(t, uint64(4), result[1].Version)
+	})
+
+	t.Run("when the log starts after a restored version it should only return the changes after it", func(t *testing.T) {
+		// Arrange
+		changes := setup(3)
+		changes.startAfter(10)
+
+		// Act
+		older, olderOk := changes.since(9)
+		current, currentOk := changes.since(10)
+		changes.append(liveDataChange{Version: 11})
+		result, ok := changes.since(10)
+
+		// Assert
+		assert.False(t, olderOk)
+		assert.Nil(t, older)
+		assert.True(t, currentOk)
+		assert.Equal(t, 0, len(current))
+		assert.True(t, ok)
+		assert.Equal(t, 1, len(result))
+	})
+
+	t.Run("when changes are appended it should keep the loaded snapshots unchanged", func(t *testing.T) {
+		// Arrange
+		changes := setup(2, 1, 2)
+		loaded := changes.load()
+
+		// Act
+		for version := uint64(3); version <= 10; version++ {
+			changes.append(liveDataChange{Version: version})
+		}
+		result, ok := loaded.since(0)
+
+		// Assert
+		assert.True(t, ok)
+		assert.Equal(t, []liveDataChange{{Version: 1}, {Version: 2}}, result)
+		assert.Equal(t, uint64(2), loaded.version)
+		current, _ := changes.since(8)
+		assert.Equal(t, []liveDataChange{{Version: 9}, {Version: 10}}, current)
+	})
+}
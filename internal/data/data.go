@@ -1,101 +1,163 @@
 package data
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/Zedronar/go-dummy-app.git/external/retry"
+	"gopkg.in/yaml.v2"
 )
 
-type fixtureTournament struct {
-	Id   string `json:"id"`
-	Name string `json:"name"`
-}
+const DefaultFixturesUrl = "http://localhost:8080/fixtures"
 
-type fixtureTeam struct {
-	Id   string `json:"id"`
-	Name string `json:"name"`
+var fixturesRetryPolicy = retry.Policy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
 
-	// Live data
-	Score int `json:"score"`
+type FixtureTournament struct {
+	Id   string `json:"id" yaml:"id"`
+	Name string `json:"name" yaml:"name"`
 }
 
-type fixture struct {
-	Id                 string            `json:"id"`
-	Title              string            `json:"title"`
-	Tournament         fixtureTournament `json:"tournament"`
-	Teams              []fixtureTeam     `json:"teams"`
-	ScheduledStartTime int64             `json:"scheduledStartTimeUnixSeconds"`
+type FixtureTeam struct {
+	Id   string `json:"id" yaml:"id"`
+	Name string `json:"name" yaml:"name"`
 
 	// Live data
-	WinningTeamId string `json:"winningTeamId"`
+	Score int `json:"score" yaml:"score"`
 }
 
-type DataProvider interface {
-	Retrieve() string
+// Rules deciding the fixture's maps and series (best-of-N, score limit, overtime, draws)
+type FixtureRules struct {
+	BestOf        int  `json:"bestOf" yaml:"bestOf"`
+	MapScoreLimit int  `json:"mapScoreLimit" yaml:"mapScoreLimit"`
+	OvertimeWinBy int  `json:"overtimeWinBy" yaml:"overtimeWinBy"`
+	AllowDraws    bool `json:"allowDraws" yaml:"allowDraws"`
 }
 
-type HttpDataProvider struct {
+// Points of free-for-all fixtures, by placement (the first entry is for the team placed first) and per kill
+type FixturePointsTable struct {
+	PlacementPoints []int `json:"placementPoints" yaml:"placementPoints"`
+	KillPoints      int   `json:"killPoints" yaml:"killPoints"`
 }
 
-func NewHttpProvider() DataProvider {
-	return &HttpDataProvider{}
+type Fixture struct {
+	Id                 string            `json:"id" yaml:"id"`
+	Title              string            `json:"title" yaml:"title"`
+	Tournament         FixtureTournament `json:"tournament" yaml:"tournament"`
+	Teams              []FixtureTeam     `json:"teams" yaml:"teams"`
+	ScheduledStartTime int64             `json:"scheduledStartTimeUnixSeconds" yaml:"scheduledStartTimeUnixSeconds"`
+	// Fixtures without rules only have a score per team
+	Rules *FixtureRules `json:"rules" yaml:"rules"`
+	// Set for free-for-all fixtures, ranked by placement and kills
+	Points *FixturePointsTable `json:"points" yaml:"points"`
+
+	// Live data, the status is inferred when empty (finished if there's a winner, scheduled otherwise)
+	Status        string `json:"status" yaml:"status"`
+	WinningTeamId string `json:"winningTeamId" yaml:"winningTeamId"`
 }
 
-// TODO: json instead of string
-func (x *HttpDataProvider) Retrieve() string {
-	// TODO: CAll the DB
-	// mongoClient.
+// Source of the fixtures the live data is built from
+type DataProvider interface {
+	Retrieve(ctx context.Context) ([]Fixture, error)
+}
 
-	resp, err := getStaticFixtures()
+// Retrieves the fixtures from the static data server's /fixtures endpoint
+type HttpDataProvider struct {
+	url         string
+	client      *http.Client
+	retryPolicy retry.Policy
+}
 
-	if err != nil {
-		log.Fatal(err)
+func NewHttpProvider(url string) DataProvider {
+	return &HttpDataProvider{
+		url:         url,
+		client:      http.DefaultClient,
+		retryPolicy: fixturesRetryPolicy,
 	}
-
-	return resp
 }
 
-// TODO:
-// func getStaticFixtures() *ViewModel {
-func getStaticFixtures() (string, error) {
+func (x *HttpDataProvider) Retrieve(ctx context.Context) ([]Fixture, error) {
+	body, err := retry.Get(ctx, x.client, x.retryPolicy, x.url)
+	if err != nil {
+		return nil, err
+	}
 
-	// Retry mechanism
-	resp, err := http.Get("http://localhost:8080/fixtures")
-	callFailureMax := 3
-	failureCount := 0
-	exponentialBackoff := 1
+	fixtures := make([]Fixture, 0)
+	if err := json.Unmarshal(body, &fixtures); err != nil {
+		return nil, err
+	}
 
-	for err != nil && failureCount < callFailureMax {
-		resp, err = http.Get("http://localhost:8080/fixtures")
+	return fixtures, nil
+}
 
-		// TODO: Fix exponential
-		time.Sleep(time.Second * 1)
-		failureCount++
-		exponentialBackoff *= 2
+// Reads the fixtures from a local JSON or YAML file (chosen by file extension)
+type FileDataProvider struct {
+	path string
+}
 
-		// On each failure (might be transient)
-		log.Fatal(err)
+func NewFileProvider(path string) DataProvider {
+	return &FileDataProvider{
+		path: path,
 	}
+}
 
+func (x *FileDataProvider) Retrieve(_ context.Context) ([]Fixture, error) {
+	content, err := ioutil.ReadFile(x.path)
 	if err != nil {
-		// Passed max attempts
-		log.Fatal(err)
-		// panic("Fatal error - called too many times...")
-		return "", err
+		return nil, err
 	}
 
-	defer resp.Body.Close()
+	fixtures := make([]Fixture, 0)
+	switch strings.ToLower(filepath.Ext(x.path)) {
+	case ".json":
+		err = json.Unmarshal(content, &fixtures)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &fixtures)
+	default:
+		return nil, fmt.Errorf("unsupported fixtures file '%s'", x.path)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	// TODO: temp
-	return "response", nil
+	return fixtures, nil
+}
 
-	// body, err := ioutil.ReadAll(resp.Body)
+// Returns a fixed set of fixtures, mostly useful for tests
+type MemoryDataProvider struct {
+	fixtures []Fixture
+}
 
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
+func NewMemoryProvider(fixtures ...Fixture) DataProvider {
+	return &MemoryDataProvider{
+		fixtures: fixtures,
+	}
+}
 
-	// var viewmodel ViewModel
-	// json.Unmarshal(body, &viewmodel)
-	// return &viewmodel
+func (x *MemoryDataProvider) Retrieve(_ context.Context) ([]Fixture, error) {
+	// Return a copy, so callers can't modify the provider's fixtures
+	fixtures := make([]Fixture, len(x.fixtures))
+	for i, fixture := range x.fixtures {
+		fixtures[i] = fixture
+		fixtures[i].Teams = append([]FixtureTeam(nil), fixture.Teams...)
+		if fixture.Rules != nil {
+			rules := *fixture.Rules
+			fixtures[i].Rules = &rules
+		}
+		if fixture.Points != nil {
+			points := *fixture.Points
+			points.PlacementPoints = append([]int(nil), fixture.Points.PlacementPoints...)
+			fixtures[i].Points = &points
+		}
+	}
+	return fixtures, nil
 }
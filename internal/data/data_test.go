@@ -0,0 +1,151 @@
+package data
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Zedronar/go-dummy-app.git/external/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpDataProvider(t *testing.T) {
+
+	t.Run("when endpoint returns fixtures it should return typed fixtures", func(t *testing.T) {
+		// Arrange
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[{"id":"F1","title":"A vs B","tournament":{"id":"TO1","name":"Cup"},"teams":[{"id":"TE1","name":"A"}],"scheduledStartTimeUnixSeconds":100}]`))
+		}))
+		defer server.Close()
+		provider := NewHttpProvider(server.URL)
+
+		// Act
+		fixtures, err := provider.Retrieve(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []Fixture{{
+			Id:                 "F1",
+			Title:              "A vs B",
+			Tournament:         FixtureTournament{Id: "TO1", Name: "Cup"},
+			Teams:              []FixtureTeam{{Id: "TE1", Name: "A"}},
+			ScheduledStartTime: 100,
+		}}, fixtures)
+	})
+
+	t.Run("when endpoint keeps failing it should return an error", func(t *testing.T) {
+		// Arrange
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		provider := &HttpDataProvider{
+			url:         server.URL,
+			client:      http.DefaultClient,
+			retryPolicy: retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+		}
+
+		// Act
+		fixtures, err := provider.Retrieve(context.Background())
+
+		// Assert
+		assert.Error(t, err)
+		assert.Nil(t, fixtures)
+	})
+}
+
+func TestFileDataProvider(t *testing.T) {
+
+	setup := func(t *testing.T, name string, content string) (string, func()) {
+		dir, err := ioutil.TempDir("", "fixtures")
+		assert.NoError(t, err)
+		path := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+		return path, func() {
+			os.RemoveAll(dir)
+		}
+	}
+
+	t.Run("when file is json it should return typed fixtures", func(t *testing.T) {
+		// Arrange
+		path, tearDown := setup(t, "fixtures.json", `[{"id":"F1","teams":[{"id":"TE1","score":2}],"winningTeamId":"TE1"}]`)
+		defer tearDown()
+
+		// Act
+		fixtures, err := NewFileProvider(path).Retrieve(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []Fixture{{Id: "F1", Teams: []FixtureTeam{{Id: "TE1", Score: 2}}, WinningTeamId: "TE1"}}, fixtures)
+	})
+
+	t.Run("when file is yaml it should return typed fixtures", func(t *testing.T) {
+		// Arrange
+		path, tearDown := setup(t, "fixtures.yml", `
+- id: F1
+  title: A vs B
+  tournament:
+    id: TO1
+    name: Cup
+  teams:
+    - id: TE1
+      name: A
+  scheduledStartTimeUnixSeconds: 100
+`)
+		defer tearDown()
+
+		// Act
+		fixtures, err := NewFileProvider(path).Retrieve(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []Fixture{{
+			Id:                 "F1",
+			Title:              "A vs B",
+			Tournament:         FixtureTournament{Id: "TO1", Name: "Cup"},
+			Teams:              []FixtureTeam{{Id: "TE1", Name: "A"}},
+			ScheduledStartTime: 100,
+		}}, fixtures)
+	})
+
+	t.Run("when file extension is unknown it should return an error", func(t *testing.T) {
+		// Arrange
+		path, tearDown := setup(t, "fixtures.txt", `[]`)
+		defer tearDown()
+
+		// Act
+		_, err := NewFileProvider(path).Retrieve(context.Background())
+
+		// Assert
+		assert.Error(t, err)
+	})
+
+	t.Run("when file doesn't exist it should return an error", func(t *testing.T) {
+		// Act
+		_, err := NewFileProvider("/nonexistent/fixtures.json").Retrieve(context.Background())
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestMemoryDataProvider(t *testing.T) {
+
+	t.Run("when returned fixtures are modified it should keep its own fixtures", func(t *testing.T) {
+		// Arrange
+		provider := NewMemoryProvider(Fixture{Id: "F1", Teams: []FixtureTeam{{Id: "TE1"}}})
+		fixtures, _ := provider.Retrieve(context.Background())
+
+		// Act
+		fixtures[0].Teams[0].Score = 5
+
+		// Assert
+		fixtures, _ = provider.Retrieve(context.Background())
+		assert.Equal(t, 0, fixtures[0].Teams[0].Score)
+	})
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/Zedronar/go-dummy-app.git/external/metrics"
+)
+
+const (
+	metricNameLiveUpdateGaps                 = "liveupdates.gaps"
+	metricNameLiveUpdateDuplicates           = "liveupdates.duplicates"
+	metricNameLiveUpdateStale                = "liveupdates.stale"
+	metricNameLiveUpdateRefusedWinnerChanges = "liveupdates.refused_winner_changes"
+)
+
+var (
+	errDuplicateUpdate = errors.New("duplicate update")
+	errStaleUpdate     = errors.New("stale update")
+	errWinnerDecided   = errors.New("fixture already has a different winner")
+)
+
+type eventSequenceStatus int
+
+const (
+	// The producer doesn't sequence its events
+	eventUnsequenced eventSequenceStatus = iota
+	// The next event
+	eventInSequence
+	// Newer than expected, some events were missed
+	eventAfterGap
+	// Same sequence as the last event, a redelivery
+	eventDuplicate
+	// Older than the last event, it arrived late
+	eventStale
+)
+
+// Fields of a fixture live updates are sequenced on. Updates to different fields (e.g. the scores of
+// two teams) don't outdate each other, whatever order they arrive in.
+const (
+	updateFieldWinner = "winner"
+	updateFieldStatus = "status"
+)
+
+// Score of a team, including its map scores for fixtures with match rules
+func scoreUpdateField(teamId string) string {
+	return "score/" + teamId
+}
+
+func placementUpdateField(teamId string) string {
+	return "placement/" + teamId
+}
+
+// Last sequence per producer, e.g. the last sequence applied to a field of a fixture.
+// Updates carry absolute values (scores, winners), so once a newer event has been applied to a
+// field the older ones are outdated.
+type eventSequences map[string]uint64
+
+func (sequences eventSequences) status(envelope external.EventEnvelope) eventSequenceStatus {
+	if envelope.Sequence == 0 {
+		return eventUnsequenced
+	}
+
+	lastSequence := sequences[envelope.ProducerId]
+	switch {
+	case envelope.Sequence == lastSequence:
+		return eventDuplicate
+	case envelope.Sequence < lastSequence:
+		return eventStale
+	case envelope.Sequence == lastSequence+1:
+		return eventInSequence
+	default:
+		return eventAfterGap
+	}
+}
+
+// Live updates applied to a field of a fixture
+type fieldUpdates struct {
+	sequences     eventSequences
+	lastEmittedAt time.Time
+}
+
+// Check the envelope against the last update applied to the same field of the fixture. Nothing is
+// recorded, the update can still be rejected: once it's applied, commitEnvelope records it.
+// Must be called holding the entry's lock.
+func (entry *fixtureEntry) checkEnvelope(envelope external.EventEnvelope, field string) error {
+	updates := entry.fields[field]
+	if updates == nil {
+		return nil
+	}
+
+	switch updates.sequences.status(envelope) {
+	case eventDuplicate:
+		return errDuplicateUpdate
+	case eventStale:
+		return errStaleUpdate
+	}
+
+	// Sequences can't be compared across producers, emission times can
+	if !envelope.EmittedAt.IsZero() && envelope.EmittedAt.Before(updates.lastEmittedAt) && !envelope.Correction {
+		return errStaleUpdate
+	}
+
+	return nil
+}
+
+// Record the envelope of an update applied to a field of the fixture, so older and redelivered
+// events are rejected. Gaps are detected across the fixture's fields, since producers sequence the
+// events of a fixture together. Must be called holding the entry's lock.
+func (entry *fixtureEntry) commitEnvelope(envelope external.EventEnvelope, fixtureId string, field string) {
+	updates := entry.fields[field]
+	if updates == nil {
+		updates = &fieldUpdates{sequences: make(eventSequences)}
+		entry.fields[field] = updates
+	}
+
+	if entry.sequences.status(envelope) == eventAfterGap {
+		log.Print(fmt.Sprintf("@commitEnvelope -> gap before event '%s' of fixture '%s'", envelope.EventId, fixtureId))
+		metrics.Increment(metricNameLiveUpdateGaps)
+	}
+
+	if envelope.Sequence > 0 {
+		updates.sequences[envelope.ProducerId] = envelope.Sequence
+		if envelope.Sequence > entry.sequences[envelope.ProducerId] {
+			entry.sequences[envelope.ProducerId] = envelope.Sequence
+		}
+	}
+	if envelope.EmittedAt.After(updates.lastEmittedAt) {
+		updates.lastEmittedAt = envelope.EmittedAt
+	}
+}
+
+// Count (and log) the live updates rejected as stale, duplicate, changing a decided winner or placement,
+// not allowed in the fixture's status or for a map that isn't being played
+func countRejectedUpdate(err error, envelope external.EventEnvelope, fixtureId string) {
+	switch err {
+	case errDuplicateUpdate:
+		metrics.Increment(metricNameLiveUpdateDuplicates)
+	case errStaleUpdate:
+		metrics.Increment(metricNameLiveUpdateStale)
+	case errWinnerDecided:
+		metrics.Increment(metricNameLiveUpdateRefusedWinnerChanges)
+	case errInvalidStatusTransition:
+		metrics.Increment(metricNameLiveUpdateInvalidStatusTransitions)
+	case errUnknownMap:
+		metrics.Increment(metricNameLiveUpdateUnknownMaps)
+	case errInvalidPlacement, errPlacementDecided:
+		metrics.Increment(metricNameLiveUpdateRefusedPlacements)
+	default:
+		// Applied, or unknown fixture/team (already logged)
+		return
+	}
+	log.Print(fmt.Sprintf("@countRejectedUpdate -> event '%s' of fixture '%s' rejected: %s", envelope.EventId, fixtureId, err.Error()))
+}
@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventSequences(t *testing.T) {
+
+	envelope := func(producerId string, sequence uint64) external.EventEnvelope {
+		return external.NewEventEnvelope(producerId, "F1", sequence, time.Now().UTC())
+	}
+
+	t.Run("when events are compared to the last sequence it should classify them", func(t *testing.T) {
+		// Arrange
+		sequences := eventSequences{"P1": 2}
+
+		// Act & Assert
+		assert.Equal(t, eventInSequence, sequences.status(envelope("P1", 3)))
+		assert.Equal(t, eventAfterGap, sequences.status(envelope("P1", 5)))
+		assert.Equal(t, eventDuplicate, sequences.status(envelope("P1", 2)))
+		assert.Equal(t, eventStale, sequences.status(envelope("P1", 1)))
+		assert.Equal(t, eventInSequence, sequences.status(envelope("P2", 1)))
+		assert.Equal(t, eventUnsequenced, sequences.status(external.EventEnvelope{}))
+	})
+}
+
+func TestLiveUpdateProtection(t *testing.T) {
+
+	setup := func() *LiveDataServer {
+		viewModel := &ViewModel{fixture{Id: "F1", Teams: []fixtureTeam{{Id: "TE1"}, {Id: "TE2"}}}}
+		return newLiveDataServer(viewModel, external.NewMemoryPublisher())
+	}
+
+	start := time.Now().UTC()
+	envelope := func(producerId string, sequence uint64) external.EventEnvelope {
+		return external.NewEventEnvelope(producerId, "F1", sequence, start.Add(time.Duration(sequence)*time.Second))
+	}
+
+	t.Run("when a score update is received twice it should reject the duplicate", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.applyScoreUpdate(envelope("P1", 1), "F1", "TE1", 1)
+
+		// Act
+		err := server.applyScoreUpdate(envelope("P1", 1), "F1", "TE1", 1)
+
+		// Assert
+		assert.Equal(t, errDuplicateUpdate, err)
+		assert.Equal(t, uint64(1), server.currentVersion())
+	})
+
+	t.Run("when a score update arrives late it should reject it", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.applyScoreUpdate(envelope("P1", 1), "F1", "TE1", 1)
+		_ = server.applyScoreUpdate(envelope("P1", 2), "F1", "TE1", 2)
+
+		// Act
+		err := server.applyScoreUpdate(envelope("P1", 1), "F1", "TE1", 1)
+
+		// Assert
+		assert.Equal(t, errStaleUpdate, err)
+		assert.Equal(t, 2, server.findFixtureTeam("F1", "TE1").Score)
+	})
+
+	t.Run("when an update is rejected it should apply a valid redelivery of the same sequence", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.applyScoreUpdate(envelope("P1", 1), "F1", "TE1", 2)
+		correction := envelope("P1", 4)
+		correction.Correction = true
+
+		// Act
+		lowerScoreErr := server.applyScoreUpdate(envelope("P1", 2), "F1", "TE1", 1)
+		redeliveredScoreErr := server.applyScoreUpdate(envelope("P1", 2), "F1", "TE1", 3)
+		_ = server.applyWinnerUpdate(envelope("P1", 3), "F1", "TE1")
+		decidedErr := server.applyWinnerUpdate(envelope("P1", 4), "F1", "TE2")
+		correctedErr := server.applyWinnerUpdate(correction, "F1", "TE2")
+
+		// Assert
+		assert.Equal(t, errStaleUpdate, lowerScoreErr)
+		assert.NoError(t, redeliveredScoreErr)
+		assert.Equal(t, 3, server.findFixtureTeam("F1", "TE1").Score)
+		assert.Equal(t, errWinnerDecided, decidedErr)
+		assert.NoError(t, correctedErr)
+		assert.Equal(t, "TE2", server.findFixture("F1").WinningTeamId)
+	})
+
+	t.Run("when an update was emitted before the last one by another producer it should reject it", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.applyScoreUpdate(envelope("P1", 5), "F1", "TE1", 2)
+
+		// Act
+		err := server.applyScoreUpdate(envelope("P2", 1), "F1", "TE1", 3)
+
+		// Assert
+		assert.Equal(t, errStaleUpdate, err)
+		assert.Equal(t, 2, server.findFixtureTeam("F1", "TE1").Score)
+	})
+
+	t.Run("when the scores of two teams arrive out of order it should apply both", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.applyScoreUpdate(envelope("P1", 4), "F1", "TE1", 1)
+		_ = server.applyScoreUpdate(envelope("P1", 6), "F1", "TE2", 1)
+
+		// Act
+		lateErr := server.applyScoreUpdate(envelope("P1", 5), "F1", "TE1", 2)
+		replayErr := server.applyScoreUpdate(envelope("P1", 5), "F1", "TE1", 2)
+		otherProducerErr := server.applyScoreUpdate(external.NewEventEnvelope("P2", "F1", 1, start.Add(7*time.Second)), "F1", "TE2", 2)
+
+		// Assert
+		assert.NoError(t, lateErr)
+		assert.Equal(t, errDuplicateUpdate, replayErr)
+		assert.NoError(t, otherProducerErr)
+		assert.Equal(t, 2, server.findFixtureTeam("F1", "TE1").Score)
+		assert.Equal(t, 2, server.findFixtureTeam("F1", "TE2").Score)
+	})
+
+	t.Run("when events were missed it should still apply the newer update", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.applyScoreUpdate(envelope("P1", 1), "F1", "TE1", 1)
+
+		// Act
+		err := server.applyScoreUpdate(envelope("P1", 4), "F1", "TE1", 3)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 3, server.findFixtureTeam("F1", "TE1").Score)
+	})
+
+	t.Run("when an unsequenced update would move a score backwards it should reject it unless it's a correction", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.updateScoreAndPublish("F1", "TE1", 3)
+
+		// Act
+		staleErr := server.updateScoreAndPublish("F1", "TE1", 2)
+		correctionErr := server.applyScoreUpdate(external.EventEnvelope{Correction: true}, "F1", "TE1", 2)
+
+		// Assert
+		assert.Equal(t, errStaleUpdate, staleErr)
+		assert.NoError(t, correctionErr)
+		assert.Equal(t, 2, server.findFixtureTeam("F1", "TE1").Score)
+	})
+
+	t.Run("when the fixture already has a winner it should refuse a different one unless it's a correction", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.applyWinnerUpdate(envelope("P1", 1), "F1", "TE1")
+
+		// Act
+		refusedErr := server.applyWinnerUpdate(envelope("P1", 2), "F1", "TE2")
+		sameWinnerErr := server.updateWinnerAndPublish("F1", "TE1")
+		correction := envelope("P1", 3)
+		correction.Correction = true
+		correctionErr := server.applyWinnerUpdate(correction, "F1", "TE2")
+
+		// Assert
+		assert.Equal(t, errWinnerDecided, refusedErr)
+		assert.NoError(t, sameWinnerErr)
+		assert.NoError(t, correctionErr)
+		assert.Equal(t, "TE2", server.findFixture("F1").WinningTeamId)
+		assert.Equal(t, uint64(2), server.currentVersion())
+	})
+
+	t.Run("when the winner isn't a team of the fixture it should reject it", func(t *testing.T) {
+		// Arrange
+		server := setup()
+
+		// Act
+		err := server.updateWinnerAndPublish("F1", "TE3")
+
+		// Assert
+		assert.Equal(t, errTeamNotFound, err)
+		assert.Equal(t, "", server.findFixture("F1").WinningTeamId)
+	})
+
+	t.Run("when the receivers get a replayed update it should be ignored", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		server.scoreUpdateReceiver.Receive(&testScoreUpdate{envelope: envelope("P1", 1), fixtureId: "F1", teamId: "TE1", score: 1})
+		server.scoreUpdateReceiver.Receive(&testScoreUpdate{envelope: envelope("P1", 2), fixtureId: "F1", teamId: "TE1", score: 2})
+		server.winningTeamUpdateReceiver.Receive(&testWinningTeamUpdate{envelope: envelope("P1", 3), fixtureId: "F1", teamId: "TE1"})
+
+		// Act
+		server.scoreUpdateReceiver.Receive(&testScoreUpdate{envelope: envelope("P1", 1), fixtureId: "F1", teamId: "TE1", score: 1})
+		server.winningTeamUpdateReceiver.Receive(&testWinningTeamUpdate{envelope: envelope("P2", 1), fixtureId: "F1", teamId: "TE2"})
+
+		// Assert
+		assert.Equal(t, 2, server.findFixtureTeam("F1", "TE1").Score)
+		assert.Equal(t, "TE1", server.findFixture("F1").WinningTeamId)
+		assert.Equal(t, uint64(3), server.currentVersion())
+	})
+}
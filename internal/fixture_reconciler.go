@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Zedronar/go-dummy-app.git/external/metrics"
+	"github.com/Zedronar/go-dummy-app.git/internal/data"
+)
+
+const (
+	metricNameFixtureSyncFailures = "fixturesync.failures"
+
+	DefaultFixtureSyncInterval = time.Minute
+)
+
+// Periodically re-polls the fixtures source and applies the differences to the viewmodel.
+// Live data (scores and winners) is never overwritten by the source.
+type fixtureReconciler struct {
+	server       *LiveDataServer
+	dataProvider data.DataProvider
+	interval     time.Duration
+
+	// Fixtures returned by the source on the last sync. Only those are retired when the source
+	// stops returning them, so fixtures added through the API are left alone.
+	// Nil until the source was first retrieved, nothing is retired before that.
+	sourceFixtureIds map[string]struct{}
+}
+
+// Outcome of a single sync
+type fixtureReconcileResult struct {
+	Added   int
+	Updated int
+	Retired int
+}
+
+// The source fixtures are the ones the viewmodel was loaded with, nil if it wasn't loaded from the
+// source (e.g. rebuilt from the publisher's store, where the fixtures added through the API can't be
+// told apart): the first sync then learns them without retiring anything.
+func newFixtureReconciler(server *LiveDataServer, dataProvider data.DataProvider, interval time.Duration, sourceFixtures []fixture) *fixtureReconciler {
+	reconciler := &fixtureReconciler{
+		server:       server,
+		dataProvider: dataProvider,
+		interval:     interval,
+	}
+
+	if sourceFixtures != nil {
+		reconciler.sourceFixtureIds = make(map[string]struct{}, len(sourceFixtures))
+		for _, sourceFixture := range sourceFixtures {
+			reconciler.sourceFixtureIds[sourceFixture.Id] = struct{}{}
+		}
+	}
+
+	return reconciler
+}
+
+// Sync on every interval until the context is done
+func (reconciler *fixtureReconciler) run(ctx context.Context) {
+	ticker := time.NewTicker(reconciler.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := reconciler.sync(ctx); err != nil {
+				log.Print(fmt.Sprintf("@run -> error syncing fixtures: %s", err.Error()))
+				metrics.Increment(metricNameFixtureSyncFailures)
+			}
+		}
+	}
+}
+
+func (reconciler *fixtureReconciler) sync(ctx context.Context) error {
+	fixtures, err := reconciler.dataProvider.Retrieve(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := reconciler.reconcile(*newViewModel(fixtures))
+	if result.Added > 0 || result.Updated > 0 || result.Retired > 0 {
+		log.Println(fmt.Sprintf("synced fixtures: %d added, %d updated, %d retired", result.Added, result.Updated, result.Retired))
+	}
+
+	return nil
+}
+
+// Diff the source fixtures against the viewmodel and apply the changes
+func (reconciler *fixtureReconciler) reconcile(sourceFixtures []fixture) fixtureReconcileResult {
+	result := fixtureReconcileResult{}
+	current := reconciler.currentFixtures()
+	sourceFixtureIds := make(map[string]struct{}, len(sourceFixtures))
+
+	for _, sourceFixture := range sourceFixtures {
+		sourceFixtureIds[sourceFixture.Id] = struct{}{}
+
+		currentFixture, found := current[sourceFixture.Id]
+		if !found {
+			if err := reconciler.server.addFixture(sourceFixture); err != nil {
+				log.Print(fmt.Sprintf("@reconcile -> error adding fixture '%s': %s", sourceFixture.Id, err.Error()))
+				continue
+			}
+			result.Added++
+			continue
+		}
+
+		if reconciler.updateFixture(currentFixture, sourceFixture) {
+			result.Updated++
+		}
+	}
+
+	// Retire the fixtures the source no longer returns, unless they're already gone (e.g. removed through the API)
+	for fixtureId := range reconciler.sourceFixtureIds {
+		if _, found := sourceFixtureIds[fixtureId]; found {
+			continue
+		}
+		err := reconciler.server.removeFixture(fixtureId)
+		if err == errFixtureNotFound {
+			continue
+		}
+		if err != nil {
+			log.Print(fmt.Sprintf("@reconcile -> error retiring fixture '%s': %s", fixtureId, err.Error()))
+			continue
+		}
+		result.Retired++
+	}
+
+	reconciler.sourceFixtureIds = sourceFixtureIds
+
+	return result
+}
+
+// Apply the source's details and teams to the fixture, keeping its scores and winner.
+// Returns true if anything changed.
+func (reconciler *fixtureReconciler) updateFixture(currentFixture *fixture, sourceFixture fixture) bool {
+	updated := false
+	fixtureId := currentFixture.Id
+
+	details := fixtureDetails{
+		Title:              sourceFixture.Title,
+		Tournament:         sourceFixture.Tournament,
+		ScheduledStartTime: sourceFixture.ScheduledStartTime,
+	}
+	if currentFixture.Title != details.Title ||
+		currentFixture.Tournament != details.Tournament ||
+		currentFixture.ScheduledStartTime != details.ScheduledStartTime {
+		if err := reconciler.server.updateFixtureDetails(fixtureId, details); err != nil {
+			log.Print(fmt.Sprintf("@updateFixture -> error updating fixture '%s': %s", fixtureId, err.Error()))
+		} else {
+			updated = true
+		}
+	}
+
+	for _, sourceTeam := range sourceFixture.Teams {
+		teamIndex := currentFixture.findTeamIndex(sourceTeam.Id)
+		var err error
+		switch {
+		case teamIndex < 0:
+			// New teams start with the source's score, existing ones keep their live score
+			err = reconciler.server.addTeam(fixtureId, sourceTeam)
+		case currentFixture.Teams[teamIndex].Name != sourceTeam.Name:
+			err = reconciler.server.updateTeamName(fixtureId, sourceTeam.Id, sourceTeam.Name)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Print(fmt.Sprintf("@updateFixture -> error updating team '%s' of fixture '%s': %s", sourceTeam.Id, fixtureId, err.Error()))
+			continue
+		}
+		updated = true
+	}
+
+	return updated
+}
+
+// The viewmodel's current fixtures, by id
+func (reconciler *fixtureReconciler) currentFixtures() map[string]*fixture {
+	fixtures := make(map[string]*fixture, reconciler.server.store.len())
+	reconciler.server.store.each(func(fixture *fixture) {
+		fixtures[fixture.Id] = fixture
+	})
+
+	return fixtures
+}
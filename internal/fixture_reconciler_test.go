@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/Zedronar/go-dummy-app.git/internal/data"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingDataProvider struct{}
+
+func (p failingDataProvider) Retrieve(_ context.Context) ([]data.Fixture, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestFixtureReconciler(t *testing.T) {
+
+	setup := func(sourceFixtures ...data.Fixture) (*fixtureReconciler, *LiveDataServer) {
+		viewModel := &ViewModel{
+			fixture{
+				Id:            "fixture-id-1",
+				Title:         "fixture-title-1",
+				Teams:         []fixtureTeam{{Id: "team-id-1", Name: "team-name-1", Score: 3}, {Id: "team-id-2", Name: "team-name-2", Score: 1}},
+				WinningTeamId: "team-id-1",
+			},
+			fixture{
+				Id:    "fixture-id-2",
+				Title: "fixture-title-2",
+			},
+		}
+		server := newLiveDataServer(viewModel, external.NewMemoryPublisher())
+		return newFixtureReconciler(server, data.NewMemoryProvider(sourceFixtures...), DefaultFixtureSyncInterval, *viewModel), server
+	}
+
+	t.Run("when the source has new fixtures it should add them", func(t *testing.T) {
+		// Arrange
+		reconciler, server := setup(
+			data.Fixture{Id: "fixture-id-1", Title: "fixture-title-1", Teams: []data.FixtureTeam{{Id: "team-id-1", Name: "team-name-1"}, {Id: "team-id-2", Name: "team-name-2"}}},
+			data.Fixture{Id: "fixture-id-2", Title: "fixture-title-2"},
+			data.Fixture{Id: "fixture-id-0", Title: "fixture-title-0"},
+		)
+
+		// Act
+		err := reconciler.sync(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "fixture-id-0", (*server.store.viewModel())[0].Id)
+		assert.Equal(t, 3, server.store.len())
+		assert.Equal(t, uint64(1), server.version)
+	})
+
+	t.Run("when the source changes a fixture it should update it keeping the live data", func(t *testing.T) {
+		// Arrange
+		reconciler, server := setup()
+		sourceFixtures := []fixture{
+			{
+				Id:                 "fixture-id-1",
+				Title:              "new-title",
+				Tournament:         fixtureTournament{Id: "tournament-id", Name: "tournament-name"},
+				ScheduledStartTime: 100,
+				Teams:              []fixtureTeam{{Id: "team-id-3", Name: "team-name-3"}, {Id: "team-id-1", Name: "new-team-name"}, {Id: "team-id-2", Name: "team-name-2"}},
+			},
+			{Id: "fixture-id-2", Title: "fixture-title-2"},
+		}
+
+		// Act
+		result := reconciler.reconcile(sourceFixtures)
+
+		// Assert
+		assert.Equal(t, fixtureReconcileResult{Updated: 1}, result)
+		fixture := server.findFixture("fixture-id-1")
+		assert.Equal(t, "new-title", fixture.Title)
+		assert.Equal(t, "tournament-id", fixture.Tournament.Id)
+		assert.Equal(t, int64(100), fixture.ScheduledStartTime)
+		assert.Equal(t, []fixtureTeam{{Id: "team-id-1", Name: "new-team-name", Score: 3}, {Id: "team-id-2", Name: "team-name-2", Score: 1}, {Id: "team-id-3", Name: "team-name-3"}}, fixture.Teams)
+		assert.Equal(t, "team-id-1", fixture.WinningTeamId)
+	})
+
+	t.Run("when the source is unchanged it should not publish anything", func(t *testing.T) {
+		// Arrange
+		reconciler, server := setup()
+		sourceFixtures := []fixture{
+			{Id: "fixture-id-1", Title: "fixture-title-1", Teams: []fixtureTeam{{Id: "team-id-1", Name: "team-name-1"}, {Id: "team-id-2", Name: "team-name-2"}}},
+			{Id: "fixture-id-2", Title: "fixture-title-2"},
+		}
+
+		// Act
+		result := reconciler.reconcile(sourceFixtures)
+
+		// Assert
+		assert.Equal(t, fixtureReconcileResult{}, result)
+		assert.Equal(t, uint64(0), server.version)
+	})
+
+	t.Run("when the source no longer returns a fixture it should retire it", func(t *testing.T) {
+		// Arrange
+		reconciler, server := setup()
+
+		// Act
+		result := reconciler.reconcile([]fixture{{Id: "fixture-id-2", Title: "fixture-title-2"}})
+
+		// Assert
+		assert.Equal(t, fixtureReconcileResult{Retired: 1}, result)
+		assert.Nil(t, server.findFixture("fixture-id-1"))
+	})
+
+	t.Run("when a fixture was added through the api it should not retire it", func(t *testing.T) {
+		// Arrange
+		reconciler, server := setup()
+		_ = server.addFixture(fixture{Id: "fixture-id-3"})
+
+		// Act
+		result := reconciler.reconcile([]fixture{{Id: "fixture-id-1", Title: "fixture-title-1"}, {Id: "fixture-id-2", Title: "fixture-title-2"}})
+
+		// Assert
+		assert.Equal(t, 0, result.Retired)
+		assert.NotNil(t, server.findFixture("fixture-id-3"))
+	})
+
+	t.Run("when a retired fixture is already gone it should not count it", func(t *testing.T) {
+		// Arrange
+		reconciler, server := setup()
+		_ = server.removeFixture("fixture-id-1")
+
+		// Act
+		result := reconciler.reconcile([]fixture{{Id: "fixture-id-2", Title: "fixture-title-2"}})
+
+		// Assert
+		assert.Equal(t, fixtureReconcileResult{}, result)
+	})
+
+	t.Run("when the viewmodel wasn't loaded from the source it should only retire fixtures after the first sync", func(t *testing.T) {
+		// Arrange
+		_, server := setup()
+		reconciler := newFixtureReconciler(server, data.NewMemoryProvider(), DefaultFixtureSyncInterval, nil)
+		source := []fixture{{Id: "fixture-id-1", Title: "fixture-title-1"}}
+
+		// Act
+		first := reconciler.reconcile(source)
+		second := reconciler.reconcile(source[:0])
+
+		// Assert
+		assert.Equal(t, 0, first.Retired)
+		assert.NotNil(t, server.findFixture("fixture-id-2"))
+		assert.Equal(t, 1, second.Retired)
+		assert.Nil(t, server.findFixture("fixture-id-1"))
+	})
+
+	t.Run("when the source fails it should return an error and keep the viewmodel", func(t *testing.T) {
+		// Arrange
+		reconciler, server := setup()
+		reconciler.dataProvider = failingDataProvider{}
+
+		// Act
+		err := reconciler.sync(context.Background())
+
+		// Assert
+		assert.Error(t, err)
+		assert.Equal(t, 2, server.store.len())
+	})
+}
@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/Zedronar/go-dummy-app.git/internal/data"
+)
+
+const metricNameLiveUpdateUnknownMaps = "liveupdates.unknown_maps"
+
+var errUnknownMap = errors.New("map isn't being played")
+
+// Maps of a fixture played under match rules (best-of-N series, overtime, draws).
+// Fixtures without rules only have their teams' scores.
+type fixtureSeries struct {
+	Rules external.MatchRules `json:"rules"`
+	// Maps won by each team, by team id
+	Score map[string]int `json:"score"`
+	// Maps played so far, the last one is being played until the series is over
+	Maps []fixtureMap `json:"maps"`
+	Draw bool         `json:"draw"`
+}
+
+type fixtureMap struct {
+	Number int `json:"number"`
+	// Score of each team on the map, by team id
+	Scores        map[string]int `json:"scores"`
+	WinningTeamId string         `json:"winningTeamId,omitempty"`
+	Draw          bool           `json:"draw,omitempty"`
+}
+
+func newFixtureSeries(rules external.MatchRules) *fixtureSeries {
+	return &fixtureSeries{
+		Rules: rules,
+		Score: make(map[string]int),
+		Maps:  []fixtureMap{newFixtureMap(1)},
+	}
+}
+
+func newFixtureMap(number int) fixtureMap {
+	return fixtureMap{
+		Number: number,
+		Scores: make(map[string]int),
+	}
+}
+
+// Series of the fixtures the data provider returned rules for, nil if there are none (or they're invalid)
+func newFixtureSeriesFromData(dataFixture data.Fixture) *fixtureSeries {
+	if dataFixture.Rules == nil {
+		return nil
+	}
+
+	rules := external.MatchRules{
+		BestOf:        dataFixture.Rules.BestOf,
+		MapScoreLimit: dataFixture.Rules.MapScoreLimit,
+		OvertimeWinBy: dataFixture.Rules.OvertimeWinBy,
+		AllowDraws:    dataFixture.Rules.AllowDraws,
+	}
+	if !rules.Valid() {
+		log.Println(fmt.Sprintf("@newFixtureSeriesFromData -> fixtureId '%s' has invalid match rules", dataFixture.Id))
+		return nil
+	}
+
+	return newFixtureSeries(rules)
+}
+
+func (series *fixtureSeries) currentMap() *fixtureMap {
+	return &series.Maps[len(series.Maps)-1]
+}
+
+func (fixtureMap *fixtureMap) decided() bool {
+	return fixtureMap.WinningTeamId != "" || fixtureMap.Draw
+}
+
+// Scores are only taken for the map being played, map 0 stands for it
+func (series *fixtureSeries) checkMap(mapNumber int) error {
+	playing := series.currentMap()
+	switch {
+	case mapNumber == 0 || (mapNumber == playing.Number && !playing.decided()):
+		return nil
+	case mapNumber <= playing.Number:
+		// A map that's over, or the last map of a series that's over
+		return errStaleUpdate
+	default:
+		return errUnknownMap
+	}
+}
+
+// Whether the team won one of the maps played so far
+func (series *fixtureSeries) hasWonMap(teamId string) bool {
+	for _, fixtureMap := range series.Maps {
+		if fixtureMap.WinningTeamId == teamId {
+			return true
+		}
+	}
+	return false
+}
+
+// Forget the maps won and the map scores of a team removed from the fixture. Must be called on a copy.
+func (series *fixtureSeries) removeTeam(teamId string) {
+	delete(series.Score, teamId)
+	for _, fixtureMap := range series.Maps {
+		delete(fixtureMap.Scores, teamId)
+	}
+}
+
+// Deep copy, the maps' scores aren't shared either
+func (series *fixtureSeries) clone() *fixtureSeries {
+	clone := *series
+	clone.Score = copyScores(series.Score)
+	clone.Maps = make([]fixtureMap, len(series.Maps))
+	for i, fixtureMap := range series.Maps {
+		clone.Maps[i] = fixtureMap
+		clone.Maps[i].Scores = copyScores(fixtureMap.Scores)
+	}
+	return &clone
+}
+
+func copyScores(scores map[string]int) map[string]int {
+	clone := make(map[string]int, len(scores))
+	for teamId, score := range scores {
+		clone[teamId] = score
+	}
+	return clone
+}
+
+// Series given without maps (e.g. only their rules) start on the first map.
+// Returns false if the series' rules are invalid.
+func (fixture *fixture) initSeries() bool {
+	series := fixture.Series
+	if series == nil {
+		return true
+	}
+	if !series.Rules.Valid() {
+		return false
+	}
+
+	if series.Score == nil {
+		series.Score = make(map[string]int)
+	}
+	if len(series.Maps) == 0 {
+		series.Maps = []fixtureMap{newFixtureMap(1)}
+	}
+	for i := range series.Maps {
+		if series.Maps[i].Scores == nil {
+			series.Maps[i].Scores = make(map[string]int)
+		}
+	}
+	return true
+}
+
+// Record the team's score on the map being played. Once the map is decided, either the series is
+// decided too (setting the fixture's winner, or its draw) or the next map starts with every team on 0.
+// Must be called on a copy of the fixture, returns whether the map was decided.
+func (fixture *fixture) scoreMap(teamIndex int, score int) bool {
+	series := fixture.Series
+	rules := series.Rules
+	playing := series.currentMap()
+	playing.Scores[fixture.Teams[teamIndex].Id] = score
+
+	mapResult := rules.MapResult(fixture.teamScores(playing.Scores))
+	if !mapResult.Decided() {
+		return false
+	}
+
+	playing.Draw = mapResult.Draw
+	if mapResult.Winner >= 0 {
+		playing.WinningTeamId = fixture.Teams[mapResult.Winner].Id
+		series.Score[playing.WinningTeamId]++
+	}
+
+	seriesResult := rules.SeriesResult(fixture.teamScores(series.Score), len(series.Maps))
+	switch {
+	case seriesResult.Winner >= 0:
+		fixture.WinningTeamId = fixture.Teams[seriesResult.Winner].Id
+	case seriesResult.Draw:
+		series.Draw = true
+	default:
+		series.Maps = append(series.Maps, newFixtureMap(playing.Number+1))
+		for i := range fixture.Teams {
+			fixture.Teams[i].Score = 0
+		}
+	}
+
+	return true
+}
+
+// Scores by team id, in the fixture's team order
+func (fixture *fixture) teamScores(scores map[string]int) []int {
+	teamScores := make([]int, len(fixture.Teams))
+	for i, team := range fixture.Teams {
+		teamScores[i] = scores[team.Id]
+	}
+	return teamScores
+}
+
+// Patch operations of a decided map, besides the series itself: the teams' scores going back to 0 for
+// the next map, or the fixture's winner once the series is over
+func mapDecidedPatchOperations(current *fixture, updated *fixture) []patchOperation {
+	operations := make([]patchOperation, 0)
+	if len(updated.Series.Maps) > len(current.Series.Maps) {
+		for i := range updated.Teams {
+			operations = append(operations, patchOperation{
+				Op:    "replace",
+				Path:  patchPath(updated.Id, "teams", strconv.Itoa(i), "score"),
+				Value: 0,
+			})
+		}
+	}
+	if updated.WinningTeamId != current.WinningTeamId {
+		operations = append(operations, patchOperation{
+			Op:    "replace",
+			Path:  patchPath(updated.Id, "winningTeamId"),
+			Value: updated.WinningTeamId,
+		})
+	}
+	return operations
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/Zedronar/go-dummy-app.git/internal/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureSeries(t *testing.T) {
+
+	var publisher *external.MemoryPublisher
+
+	setup := func(rules external.MatchRules) *LiveDataServer {
+		viewModel := &ViewModel{fixture{
+			Id:     "F1",
+			Teams:  []fixtureTeam{{Id: "TE1"}, {Id: "TE2"}},
+			Series: &fixtureSeries{Rules: rules},
+		}}
+		publisher = external.NewMemoryPublisher()
+		return newLiveDataServer(viewModel, publisher)
+	}
+
+	t.Run("when a team wins a map it should count it and start the next map", func(t *testing.T) {
+		// Arrange
+		server := setup(external.MatchRules{BestOf: 3, MapScoreLimit: 2})
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "F1", 1, "TE1", 1)
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "F1", 1, "TE2", 1)
+
+		// Act
+		err := server.applyMapScoreUpdate(external.EventEnvelope{}, "F1", 1, "TE2", 2)
+
+		// Assert
+		assert.NoError(t, err)
+		series := server.findFixture("F1").Series
+		assert.Equal(t, 2, len(series.Maps))
+		assert.Equal(t, "TE2", series.Maps[0].WinningTeamId)
+		assert.Equal(t, map[string]int{"TE1": 1, "TE2": 2}, series.Maps[0].Scores)
+		assert.Equal(t, 1, series.Score["TE2"])
+		assert.Equal(t, 0, server.findFixtureTeam("F1", "TE2").Score)
+		assert.Equal(t, "", server.findFixture("F1").WinningTeamId)
+		assert.Equal(t, fixtureStatusLive, server.findFixture("F1").Status)
+	})
+
+	t.Run("when a score is for a map that isn't being played it should reject it", func(t *testing.T) {
+		// Arrange
+		server := setup(external.MatchRules{BestOf: 3, MapScoreLimit: 1})
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "F1", 1, "TE1", 1)
+
+		// Act
+		previousMapErr := server.applyMapScoreUpdate(external.EventEnvelope{}, "F1", 1, "TE2", 1)
+		futureMapErr := server.applyMapScoreUpdate(external.EventEnvelope{}, "F1", 3, "TE2", 1)
+
+		// Assert
+		assert.Equal(t, errStaleUpdate, previousMapErr)
+		assert.Equal(t, errUnknownMap, futureMapErr)
+		assert.Equal(t, uint64(1), server.currentVersion())
+	})
+
+	t.Run("when a team wins the series it should set the winner and finish the fixture", func(t *testing.T) {
+		// Arrange
+		server := setup(external.MatchRules{BestOf: 3, MapScoreLimit: 1})
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "F1", 1, "TE1", 1)
+
+		// Act
+		err := server.applyScoreUpdate(external.EventEnvelope{}, "F1", "TE1", 1)
+		sameWinnerErr := server.updateWinnerAndPublish("F1", "TE1")
+		server.publications.flush()
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, sameWinnerErr)
+		assert.Equal(t, "TE1", server.findFixture("F1").WinningTeamId)
+		assert.Equal(t, fixtureStatusFinished, server.findFixture("F1").Status)
+		assert.Equal(t, 2, server.findFixture("F1").Series.Score["TE1"])
+		messages := publisher.Messages()
+		assert.Contains(t, string(messages[len(messages)-1].Data), `{"op":"replace","path":"/fixtures/F1/winningTeamId","value":"TE1"}`)
+		assert.Contains(t, string(messages[len(messages)-1].Data), `{"op":"replace","path":"/fixtures/F1/status","value":"finished"}`)
+	})
+
+	t.Run("when the series ends tied and draws are allowed it should finish the fixture as a draw", func(t *testing.T) {
+		// Arrange
+		server := setup(external.MatchRules{BestOf: 2, MapScoreLimit: 1})
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "F1", 1, "TE1", 1)
+
+		// Act
+		noDrawErr := server.applyMapScoreUpdate(external.EventEnvelope{}, "F1", 2, "TE2", 1)
+
+		// Assert
+		assert.NoError(t, noDrawErr)
+		assert.Equal(t, 3, len(server.findFixture("F1").Series.Maps))
+
+		// Arrange
+		server = setup(external.MatchRules{BestOf: 2, MapScoreLimit: 1, AllowDraws: true})
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "F1", 1, "TE1", 1)
+
+		// Act
+		drawErr := server.applyMapScoreUpdate(external.EventEnvelope{}, "F1", 2, "TE2", 1)
+
+		// Assert
+		assert.NoError(t, drawErr)
+		assert.True(t, server.findFixture("F1").Series.Draw)
+		assert.Equal(t, "", server.findFixture("F1").WinningTeamId)
+		assert.Equal(t, fixtureStatusFinished, server.findFixture("F1").Status)
+	})
+
+	t.Run("when the data provider returns rules it should expose the series in livedata", func(t *testing.T) {
+		// Arrange
+		viewModel := newViewModel([]data.Fixture{
+			{Id: "F1", Teams: []data.FixtureTeam{{Id: "TE1"}, {Id: "TE2"}}, Rules: &data.FixtureRules{BestOf: 3, MapScoreLimit: 13, OvertimeWinBy: 2}},
+			{Id: "F2", Rules: &data.FixtureRules{BestOf: 0}},
+			{Id: "F3"},
+		})
+		server := newLiveDataServer(viewModel, external.NewMemoryPublisher())
+		_ = server.updateScoreAndPublish("F1", "TE1", 1)
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/livedata", nil))
+
+		// Assert
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"series":{"rules":{"bestOf":3,"mapScoreLimit":13,"overtimeWinBy":2},"score":{},"maps":[{"number":1,"scores":{"TE1":1}}],"draw":false}`)
+		assert.Nil(t, server.findFixture("F2").Series)
+		assert.Nil(t, server.findFixture("F3").Series)
+	})
+}
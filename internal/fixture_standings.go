@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/Zedronar/go-dummy-app.git/internal/data"
+)
+
+const metricNameLiveUpdateRefusedPlacements = "liveupdates.refused_placements"
+
+var (
+	errInvalidPlacement = errors.New("invalid placement")
+	errPlacementDecided = errors.New("team already has a different placement")
+)
+
+// Ranking of a free-for-all (e.g. battle royale) fixture's teams, by the points of their placement and
+// kills. The teams' scores are their kills.
+type fixtureStandings struct {
+	PointsTable external.PointsTable `json:"pointsTable"`
+	// Ranked by points, then placement (teams still in the game first)
+	Rows []fixtureStanding `json:"rows"`
+}
+
+type fixtureStanding struct {
+	Rank   int    `json:"rank"`
+	TeamId string `json:"teamId"`
+	// 0 while the team is still in the game
+	Placement       int `json:"placement"`
+	Kills           int `json:"kills"`
+	PlacementPoints int `json:"placementPoints"`
+	KillPoints      int `json:"killPoints"`
+	Points          int `json:"points"`
+}
+
+// Standings of the fixtures the data provider returned a points table for, nil if there's none (or it's invalid)
+func newFixtureStandingsFromData(dataFixture data.Fixture) *fixtureStandings {
+	if dataFixture.Points == nil {
+		return nil
+	}
+
+	table := external.PointsTable{
+		PlacementPoints: append([]int(nil), dataFixture.Points.PlacementPoints...),
+		KillPoints:      dataFixture.Points.KillPoints,
+	}
+	if !table.Valid() {
+		log.Println(fmt.Sprintf("@newFixtureStandingsFromData -> fixtureId '%s' has an invalid points table", dataFixture.Id))
+		return nil
+	}
+
+	return &fixtureStandings{PointsTable: table}
+}
+
+// Rank the teams of fixtures with standings.
+// Returns false if the points table is invalid.
+func (fixture *fixture) initStandings() bool {
+	if fixture.Standings == nil {
+		return true
+	}
+	if !fixture.Standings.PointsTable.Valid() {
+		return false
+	}
+
+	fixture.rankStandings()
+	return true
+}
+
+// Rank the teams again after their kills or placements changed. Must be called on a copy of the fixture.
+func (fixture *fixture) rankStandings() {
+	if fixture.Standings == nil {
+		return
+	}
+
+	table := fixture.Standings.PointsTable
+	rows := make([]fixtureStanding, 0, len(fixture.Teams))
+	for _, team := range fixture.Teams {
+		row := fixtureStanding{
+			TeamId:          team.Id,
+			Placement:       team.Placement,
+			Kills:           team.Score,
+			PlacementPoints: table.PointsForPlacement(team.Placement),
+			KillPoints:      table.PointsForKills(team.Score),
+		}
+		row.Points = row.PlacementPoints + row.KillPoints
+		rows = append(rows, row)
+	}
+
+	// Teams are sorted by id, so ties keep that order
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Points != rows[j].Points {
+			return rows[i].Points > rows[j].Points
+		}
+		return rows[i].Placement < rows[j].Placement
+	})
+	for i := range rows {
+		rows[i].Rank = i + 1
+	}
+
+	fixture.Standings.Rows = rows
+}
+
+// Replaces the fixture's standings, nil if it doesn't have any
+func standingsPatchOperation(fixture *fixture) *patchOperation {
+	if fixture.Standings == nil {
+		return nil
+	}
+
+	return &patchOperation{
+		Op:    "replace",
+		Path:  patchPath(fixture.Id, "standings"),
+		Value: fixture.Standings,
+	}
+}
+
+// Set a team's final placement in a free-for-all fixture. The team placed first wins the fixture, which
+// is then finished, and the other teams can still be placed. Once placed, a team's placement only
+// changes through a correction.
+func (server *LiveDataServer) applyPlacementUpdate(envelope external.EventEnvelope, fixtureId string, teamId string, placement int) error {
+	entry, current := server.lockFixture(fixtureId)
+	if entry != nil {
+		defer entry.Unlock()
+	}
+
+	teamIndex := -1
+	if current != nil {
+		teamIndex = current.findTeamIndex(teamId)
+	}
+	if teamIndex < 0 {
+		log.Println(fmt.Sprintf("@applyPlacementUpdate -> fixtureId '%s' or teamId '%s' not found!", fixtureId, teamId))
+		return errTeamNotFound
+	}
+
+	if err := entry.checkEnvelope(envelope, placementUpdateField(teamId)); err != nil {
+		return err
+	}
+	if placement < 1 || placement > len(current.fixture.Teams) {
+		return errInvalidPlacement
+	}
+	currentPlacement := current.fixture.Teams[teamIndex].Placement
+	if placement == currentPlacement {
+		return errDuplicateUpdate
+	}
+	for _, team := range current.fixture.Teams {
+		if team.Placement == placement {
+			return errInvalidPlacement
+		}
+	}
+	if currentPlacement != 0 && !envelope.Correction {
+		return errPlacementDecided
+	}
+
+	wins := placement == 1
+	if wins && current.fixture.WinningTeamId != "" && !envelope.Correction {
+		return errWinnerDecided
+	}
+	// Placing the winner doesn't stop the other teams from being placed, the fixture stays finished
+	nextStatus := fixtureStatusLive
+	if wins || current.fixture.Status == fixtureStatusFinished {
+		nextStatus = fixtureStatusFinished
+	}
+	status, err := liveUpdateStatus(current.fixture.Status, nextStatus, envelope.Correction)
+	if err != nil {
+		return err
+	}
+
+	updated := current.fixture.clone()
+	updated.Teams[teamIndex].Placement = placement
+	operations := []patchOperation{{
+		Op:    "replace",
+		Path:  patchPath(fixtureId, "teams", strconv.Itoa(teamIndex), "placement"),
+		Value: placement,
+	}}
+	change := liveDataChange{
+		Type:      changeTypePlacement,
+		FixtureId: fixtureId,
+		TeamId:    teamId,
+		Placement: placement,
+		envelope:  envelope,
+	}
+
+	// The team placed first is the last one standing
+	if wins && updated.WinningTeamId != teamId {
+		updated.WinningTeamId = teamId
+		change.WinningTeamId = teamId
+		operations = append(operations, patchOperation{
+			Op:    "replace",
+			Path:  patchPath(fixtureId, "winningTeamId"),
+			Value: teamId,
+		})
+	}
+	updated.rankStandings()
+	if operation := standingsPatchOperation(updated); operation != nil {
+		operations = append(operations, *operation)
+	}
+	if status != updated.Status {
+		updated.Status = status
+		change.Status = status
+		operations = append(operations, statusPatchOperation(fixtureId, status))
+	}
+
+	server.recordChange(func() {
+		entry.store(updated)
+	}, change, operations...)
+	entry.commitEnvelope(envelope, fixtureId, placementUpdateField(teamId))
+
+	return nil
+}
+
+type placementUpdateReceiver struct {
+	server *LiveDataServer
+}
+
+func (t *placementUpdateReceiver) Receive(update external.PlacementUpdate) {
+	// Update viewmodel with the team's placement and publish it
+	err := t.server.applyPlacementUpdate(update.Envelope(), update.FixtureId(), update.TeamId(), update.Placement())
+	countRejectedUpdate(err, update.Envelope(), update.FixtureId())
+}
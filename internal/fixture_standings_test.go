@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/Zedronar/go-dummy-app.git/internal/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureStandings(t *testing.T) {
+
+	setup := func() *LiveDataServer {
+		viewModel := &ViewModel{fixture{
+			Id:        "F3",
+			Teams:     []fixtureTeam{{Id: "TE5"}, {Id: "TE6"}, {Id: "TE7"}},
+			Standings: &fixtureStandings{PointsTable: external.PointsTable{PlacementPoints: []int{10, 6, 3}, KillPoints: 1}},
+		}}
+		return newLiveDataServer(viewModel, external.NewMemoryPublisher())
+	}
+
+	teamIds := func(standings *fixtureStandings) []string {
+		ids := make([]string, 0)
+		for _, row := range standings.Rows {
+			ids = append(ids, row.TeamId)
+		}
+		return ids
+	}
+
+	t.Run("when teams score kills it should rank them by points", func(t *testing.T) {
+		// Arrange
+		server := setup()
+
+		// Act
+		_ = server.updateScoreAndPublish("F3", "TE6", 2)
+		_ = server.updateScoreAndPublish("F3", "TE7", 1)
+
+		// Assert
+		standings := server.findFixture("F3").Standings
+		assert.Equal(t, []string{"TE6", "TE7", "TE5"}, teamIds(standings))
+		assert.Equal(t, fixtureStanding{Rank: 1, TeamId: "TE6", Kills: 2, KillPoints: 2, Points: 2}, standings.Rows[0])
+	})
+
+	t.Run("when teams are placed it should add their placement points and the team placed first should win", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.updateScoreAndPublish("F3", "TE5", 5)
+
+		// Act
+		thirdErr := server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE5", 3)
+		liveStatus := server.findFixture("F3").Status
+		secondErr := server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE6", 2)
+		firstErr := server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE7", 1)
+
+		// Assert
+		assert.NoError(t, thirdErr)
+		assert.NoError(t, secondErr)
+		assert.NoError(t, firstErr)
+		assert.Equal(t, fixtureStatusLive, liveStatus)
+		fixture := server.findFixture("F3")
+		assert.Equal(t, "TE7", fixture.WinningTeamId)
+		assert.Equal(t, fixtureStatusFinished, fixture.Status)
+		assert.Equal(t, []string{"TE7", "TE5", "TE6"}, teamIds(fixture.Standings))
+		assert.Equal(t, fixtureStanding{Rank: 2, TeamId: "TE5", Placement: 3, Kills: 5, PlacementPoints: 3, KillPoints: 5, Points: 8}, fixture.Standings.Rows[1])
+	})
+
+	t.Run("when the team placed first is placed before the others it should still place them and keep the fixture finished", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.updateScoreAndPublish("F3", "TE5", 5)
+		_ = server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE7", 1)
+
+		// Act
+		secondErr := server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE6", 2)
+		thirdErr := server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE5", 3)
+
+		// Assert
+		assert.NoError(t, secondErr)
+		assert.NoError(t, thirdErr)
+		fixture := server.findFixture("F3")
+		assert.Equal(t, "TE7", fixture.WinningTeamId)
+		assert.Equal(t, fixtureStatusFinished, fixture.Status)
+		assert.Equal(t, 2, server.findFixtureTeam("F3", "TE6").Placement)
+		assert.Equal(t, 3, server.findFixtureTeam("F3", "TE5").Placement)
+	})
+
+	t.Run("when a placement is invalid, taken or already decided it should reject it unless it's a correction", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE5", 3)
+
+		// Act
+		outOfRangeErr := server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE6", 4)
+		takenErr := server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE6", 3)
+		duplicateErr := server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE5", 3)
+		decidedErr := server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE5", 2)
+		unknownTeamErr := server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE9", 2)
+		correctionErr := server.applyPlacementUpdate(external.EventEnvelope{Correction: true}, "F3", "TE5", 2)
+
+		// Assert
+		assert.Equal(t, errInvalidPlacement, outOfRangeErr)
+		assert.Equal(t, errInvalidPlacement, takenErr)
+		assert.Equal(t, errDuplicateUpdate, duplicateErr)
+		assert.Equal(t, errPlacementDecided, decidedErr)
+		assert.Equal(t, errTeamNotFound, unknownTeamErr)
+		assert.NoError(t, correctionErr)
+		assert.Equal(t, 2, server.findFixtureTeam("F3", "TE5").Placement)
+		assert.Equal(t, uint64(2), server.currentVersion())
+	})
+
+	t.Run("when the receiver gets a placement update it should apply it", func(t *testing.T) {
+		// Arrange
+		server := setup()
+
+		// Act
+		server.placementUpdateReceiver.Receive(external.NewPlacementUpdate(external.EventEnvelope{}, "F3", "TE6", 1))
+
+		// Assert
+		assert.Equal(t, 1, server.findFixtureTeam("F3", "TE6").Placement)
+		assert.Equal(t, "TE6", server.findFixture("F3").WinningTeamId)
+	})
+
+	t.Run("when the data provider returns a points table it should expose the standings in livedata", func(t *testing.T) {
+		// Arrange
+		viewModel := newViewModel([]data.Fixture{
+			{Id: "F3", Teams: []data.FixtureTeam{{Id: "TE5"}, {Id: "TE6"}}, Points: &data.FixturePointsTable{PlacementPoints: []int{10, 6}, KillPoints: 1}},
+			{Id: "F4", Points: &data.FixturePointsTable{KillPoints: -1}},
+		})
+		server := newLiveDataServer(viewModel, external.NewMemoryPublisher())
+		_ = server.applyPlacementUpdate(external.EventEnvelope{}, "F3", "TE6", 2)
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/livedata", nil))
+
+		// Assert
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `{"id":"TE6","name":"","score":0,"placement":2}`)
+		assert.Contains(t, recorder.Body.String(), `"standings":{"pointsTable":{"placementPoints":[10,6],"killPoints":1},"rows":[{"rank":1,"teamId":"TE6","placement":2,"kills":0,"placementPoints":6,"killPoints":0,"points":6},{"rank":2,"teamId":"TE5","placement":0,"kills":0,"placementPoints":0,"killPoints":0,"points":0}]}`)
+		assert.Nil(t, server.findFixture("F4").Standings)
+	})
+}
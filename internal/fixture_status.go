@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+)
+
+const metricNameLiveUpdateInvalidStatusTransitions = "liveupdates.invalid_status_transitions"
+
+var (
+	errInvalidStatus           = errors.New("invalid fixture status")
+	errInvalidStatusTransition = errors.New("invalid fixture status transition")
+)
+
+type fixtureStatus string
+
+const (
+	fixtureStatusScheduled fixtureStatus = external.FixtureStatusScheduled
+	fixtureStatusLive      fixtureStatus = external.FixtureStatusLive
+	fixtureStatusFinished  fixtureStatus = external.FixtureStatusFinished
+	fixtureStatusPostponed fixtureStatus = external.FixtureStatusPostponed
+	fixtureStatusCancelled fixtureStatus = external.FixtureStatusCancelled
+)
+
+// Statuses a fixture can move to from each status, finished and cancelled fixtures are final
+var fixtureStatusTransitions = map[fixtureStatus][]fixtureStatus{
+	fixtureStatusScheduled: {fixtureStatusLive, fixtureStatusPostponed, fixtureStatusCancelled},
+	fixtureStatusLive:      {fixtureStatusFinished, fixtureStatusPostponed, fixtureStatusCancelled},
+	fixtureStatusPostponed: {fixtureStatusScheduled, fixtureStatusCancelled},
+	fixtureStatusFinished:  {},
+	fixtureStatusCancelled: {},
+}
+
+func (status fixtureStatus) valid() bool {
+	_, found := fixtureStatusTransitions[status]
+	return found
+}
+
+func (status fixtureStatus) canTransitionTo(next fixtureStatus) bool {
+	for _, allowed := range fixtureStatusTransitions[status] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Fixtures without a (valid) status are finished if they have a winner, scheduled otherwise
+func (fixture *fixture) initStatus() {
+	if fixture.Status.valid() {
+		return
+	}
+	if fixture.Status != "" {
+		log.Println(fmt.Sprintf("@initStatus -> fixtureId '%s' has an unknown status '%s'", fixture.Id, fixture.Status))
+	}
+
+	fixture.Status = fixtureStatusScheduled
+	if fixture.WinningTeamId != "" {
+		fixture.Status = fixtureStatusFinished
+	}
+}
+
+// Move a fixture to a new status, as long as the transition is allowed.
+// Corrections can move a fixture to any status. The status it already has is a no-op.
+func (server *LiveDataServer) applyStatusUpdate(envelope external.EventEnvelope, fixtureId string, status fixtureStatus) error {
+	if !status.valid() {
+		return errInvalidStatus
+	}
+
+	entry, current := server.lockFixture(fixtureId)
+	if entry != nil {
+		defer entry.Unlock()
+	}
+	if current == nil {
+		log.Println(fmt.Sprintf("@applyStatusUpdate -> fixtureId '%s' not found!", fixtureId))
+		return errFixtureNotFound
+	}
+
+	if err := entry.checkEnvelope(envelope, updateFieldStatus); err != nil {
+		return err
+	}
+	// Producers may send the status an earlier update already moved the fixture to (e.g. finished after
+	// its winner), there's nothing to change
+	if current.fixture.Status == status {
+		entry.commitEnvelope(envelope, fixtureId, updateFieldStatus)
+		return nil
+	}
+	if !current.fixture.Status.canTransitionTo(status) && !envelope.Correction {
+		return errInvalidStatusTransition
+	}
+
+	updated := current.fixture.clone()
+	updated.Status = status
+
+	server.recordChange(func() {
+		entry.store(updated)
+	}, liveDataChange{
+		Type:      changeTypeStatus,
+		FixtureId: fixtureId,
+		Status:    status,
+		envelope:  envelope,
+	}, statusPatchOperation(fixtureId, status))
+	entry.commitEnvelope(envelope, fixtureId, updateFieldStatus)
+
+	return nil
+}
+
+// Status a live update moves the fixture to, scores start a scheduled fixture and winners finish it.
+// Returns an error if the fixture doesn't take live updates in its current status.
+func liveUpdateStatus(current fixtureStatus, next fixtureStatus, correction bool) (fixtureStatus, error) {
+	switch {
+	case current == next || current.canTransitionTo(next):
+		return next, nil
+	case current == fixtureStatusScheduled && next == fixtureStatusFinished:
+		// Started and finished before any score was received
+		return next, nil
+	case correction:
+		return current, nil
+	default:
+		return current, errInvalidStatusTransition
+	}
+}
+
+func statusPatchOperation(fixtureId string, status fixtureStatus) patchOperation {
+	return patchOperation{
+		Op:    "replace",
+		Path:  patchPath(fixtureId, "status"),
+		Value: status,
+	}
+}
+
+type fixtureStatusUpdateReceiver struct {
+	server *LiveDataServer
+}
+
+func (t *fixtureStatusUpdateReceiver) Receive(update external.FixtureStatusUpdate) {
+	// Update viewmodel with the fixture's new status and publish it
+	err := t.server.applyStatusUpdate(update.Envelope(), update.FixtureId(), fixtureStatus(update.Status()))
+	countRejectedUpdate(err, update.Envelope(), update.FixtureId())
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureStatus(t *testing.T) {
+
+	setup := func(status fixtureStatus) *LiveDataServer {
+		viewModel := &ViewModel{fixture{Id: "F1", Status: status, Teams: []fixtureTeam{{Id: "TE1"}, {Id: "TE2"}}}}
+		server := newLiveDataServer(viewModel, external.NewMemoryPublisher())
+		server.adminToken = testAdminToken
+		return server
+	}
+
+	t.Run("when statuses are compared it should only allow the lifecycle transitions", func(t *testing.T) {
+		// Act & Assert
+		assert.True(t, fixtureStatusScheduled.canTransitionTo(fixtureStatusLive))
+		assert.True(t, fixtureStatusLive.canTransitionTo(fixtureStatusFinished))
+		assert.True(t, fixtureStatusScheduled.canTransitionTo(fixtureStatusPostponed))
+		assert.True(t, fixtureStatusPostponed.canTransitionTo(fixtureStatusScheduled))
+		assert.True(t, fixtureStatusLive.canTransitionTo(fixtureStatusCancelled))
+		assert.False(t, fixtureStatusScheduled.canTransitionTo(fixtureStatusFinished))
+		assert.False(t, fixtureStatusFinished.canTransitionTo(fixtureStatusLive))
+		assert.False(t, fixtureStatusCancelled.canTransitionTo(fixtureStatusScheduled))
+		assert.False(t, fixtureStatus("abandoned").valid())
+	})
+
+	t.Run("when the store is created it should infer the missing statuses", func(t *testing.T) {
+		// Arrange
+		viewModel := &ViewModel{
+			fixture{Id: "F1"},
+			fixture{Id: "F2", WinningTeamId: "TE1", Teams: []fixtureTeam{{Id: "TE1"}}},
+			fixture{Id: "F3", Status: fixtureStatusPostponed},
+		}
+
+		// Act
+		server := newLiveDataServer(viewModel, external.NewMemoryPublisher())
+
+		// Assert
+		assert.Equal(t, fixtureStatusScheduled, server.findFixture("F1").Status)
+		assert.Equal(t, fixtureStatusFinished, server.findFixture("F2").Status)
+		assert.Equal(t, fixtureStatusPostponed, server.findFixture("F3").Status)
+	})
+
+	t.Run("when a scheduled fixture gets a score and then a winner it should go live and then finish", func(t *testing.T) {
+		// Arrange
+		server := setup(fixtureStatusScheduled)
+
+		// Act
+		_ = server.updateScoreAndPublish("F1", "TE1", 1)
+		liveStatus := server.findFixture("F1").Status
+		_ = server.updateWinnerAndPublish("F1", "TE1")
+
+		// Assert
+		assert.Equal(t, fixtureStatusLive, liveStatus)
+		assert.Equal(t, fixtureStatusFinished, server.findFixture("F1").Status)
+	})
+
+	t.Run("when a cancelled fixture gets a score it should refuse it unless it's a correction", func(t *testing.T) {
+		// Arrange
+		server := setup(fixtureStatusCancelled)
+
+		// Act
+		refusedErr := server.updateScoreAndPublish("F1", "TE1", 1)
+		correctionErr := server.applyScoreUpdate(external.EventEnvelope{Correction: true}, "F1", "TE1", 1)
+
+		// Assert
+		assert.Equal(t, errInvalidStatusTransition, refusedErr)
+		assert.NoError(t, correctionErr)
+		assert.Equal(t, 1, server.findFixtureTeam("F1", "TE1").Score)
+		assert.Equal(t, fixtureStatusCancelled, server.findFixture("F1").Status)
+	})
+
+	t.Run("when a status update is received it should validate the transition", func(t *testing.T) {
+		// Arrange
+		server := setup(fixtureStatusScheduled)
+		server.statusUpdateReceiver.Receive(external.NewFixtureStatusUpdate(external.EventEnvelope{}, "F1", external.FixtureStatusLive))
+
+		// Act
+		backwardsErr := server.applyStatusUpdate(external.EventEnvelope{}, "F1", fixtureStatusScheduled)
+		sameStatusErr := server.applyStatusUpdate(external.EventEnvelope{}, "F1", fixtureStatusLive)
+		invalidErr := server.applyStatusUpdate(external.EventEnvelope{}, "F1", fixtureStatus("abandoned"))
+		unknownErr := server.applyStatusUpdate(external.EventEnvelope{}, "F9", fixtureStatusLive)
+
+		// Assert
+		assert.Equal(t, errInvalidStatusTransition, backwardsErr)
+		assert.NoError(t, sameStatusErr)
+		assert.Equal(t, errInvalidStatus, invalidErr)
+		assert.Equal(t, errFixtureNotFound, unknownErr)
+		assert.Equal(t, fixtureStatusLive, server.findFixture("F1").Status)
+		assert.Equal(t, uint64(1), server.currentVersion())
+	})
+
+	t.Run("when the status is changed through the api it should return the expected statuses and expose it", func(t *testing.T) {
+		// Arrange
+		server := setup(fixtureStatusScheduled)
+		handler := server.handler()
+		request := func(method string, path string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			httpRequest := httptest.NewRequest(method, path, strings.NewReader(body))
+			httpRequest.Header.Set("Authorization", "Bearer "+testAdminToken)
+			handler.ServeHTTP(recorder, httpRequest)
+			return recorder
+		}
+
+		// Act
+		postponed := request(http.MethodPut, "/livedata/fixtures/F1/status", `{"status":"postponed"}`)
+		unchanged := request(http.MethodPut, "/livedata/fixtures/F1/status", `{"status":"postponed"}`)
+		invalid := request(http.MethodPut, "/livedata/fixtures/F1/status", `{"status":"abandoned"}`)
+		cancelled := request(http.MethodPut, "/livedata/fixtures/F1/status", `{"status":"cancelled"}`)
+		final := request(http.MethodPut, "/livedata/fixtures/F1/status", `{"status":"live"}`)
+		liveData := request(http.MethodGet, "/livedata", "")
+
+		// Assert
+		assert.Equal(t, http.StatusNoContent, postponed.Code)
+		assert.Equal(t, http.StatusNoContent, unchanged.Code)
+		assert.Equal(t, http.StatusBadRequest, invalid.Code)
+		assert.Equal(t, http.StatusNoContent, cancelled.Code)
+		assert.Equal(t, http.StatusConflict, final.Code)
+		assert.Contains(t, liveData.Body.String(), `"status":"cancelled"`)
+	})
+}
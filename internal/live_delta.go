@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// Response for /livedata?since=<version>
+type liveDataDelta struct {
+	Version            uint64    `json:"version"`
+	FullResyncRequired bool      `json:"fullResyncRequired"`
+	Fixtures           []fixture `json:"fixtures"`
+	RemovedFixtureIds  []string  `json:"removedFixtureIds"`
+}
+
+func (server *LiveDataServer) handleLiveDataDeltaRequest(w http.ResponseWriter, sinceParam string) {
+	since, err := strconv.ParseUint(sinceParam, 10, 64)
+	if err != nil {
+		log.Print(fmt.Sprintf("@handleLiveDataDeltaRequest -> invalid version '%s'", sinceParam))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	delta, ok := server.changedFixturesSince(since)
+	if !ok {
+		log.Print(fmt.Sprintf("@handleLiveDataDeltaRequest -> version '%d' is ahead of the viewmodel", since))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(delta)
+	if err != nil {
+		log.Print(fmt.Sprintf("@handleLiveDataDeltaRequest -> error marshalling delta: %s", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if delta.FullResyncRequired {
+		// The requested version has been evicted from the change log
+		w.WriteHeader(http.StatusGone)
+	}
+	_, err = w.Write(jsonBytes)
+	if err != nil {
+		log.Print(fmt.Sprintf("@handleLiveDataDeltaRequest -> error writing bytes: %s", err.Error()))
+		return
+	}
+}
+
+// Returns the current state of the fixtures changed after the given version.
+// The second return value is false when the version is ahead of the viewmodel.
+func (server *LiveDataServer) changedFixturesSince(since uint64) (*liveDataDelta, bool) {
+	// Lock-free: the fixtures are read after the change log, so they're at least as recent as its
+	// version. They can include later changes, which ?since=<version> then returns again.
+	changeLog := server.changes.load()
+	if since > changeLog.version {
+		return nil, false
+	}
+
+	delta := &liveDataDelta{
+		Version:           changeLog.version,
+		Fixtures:          make([]fixture, 0),
+		RemovedFixtureIds: make([]string, 0),
+	}
+
+	changes, ok := changeLog.since(since)
+	if !ok {
+		delta.FullResyncRequired = true
+		return delta, true
+	}
+
+	changedFixtureIds := make(map[string]struct{})
+	for _, change := range changes {
+		changedFixtureIds[change.FixtureId] = struct{}{}
+	}
+
+	// Sort the ids so fixtures are returned in a deterministic order
+	sortedFixtureIds := make([]string, 0, len(changedFixtureIds))
+	for fixtureId := range changedFixtureIds {
+		sortedFixtureIds = append(sortedFixtureIds, fixtureId)
+	}
+	sort.Strings(sortedFixtureIds)
+
+	for _, fixtureId := range sortedFixtureIds {
+		if fixture := server.store.fixture(fixtureId); fixture != nil {
+			delta.Fixtures = append(delta.Fixtures, *fixture)
+		} else {
+			// Removed since
+			delta.RemovedFixtureIds = append(delta.RemovedFixtureIds, fixtureId)
+		}
+	}
+
+	return delta, true
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveDataDelta(t *testing.T) {
+
+	setup := func() *LiveDataServer {
+		viewModel := &ViewModel{
+			fixture{
+				Id:    "fixture-id-1",
+				Teams: []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-2"}},
+			},
+			fixture{
+				Id:    "fixture-id-2",
+				Teams: []fixtureTeam{{Id: "team-id-3"}, {Id: "team-id-4"}},
+			},
+		}
+		return newLiveDataServer(viewModel, external.NewMemoryPublisher())
+	}
+
+	request := func(server *LiveDataServer, since string) (*httptest.ResponseRecorder, liveDataDelta) {
+		recorder := httptest.NewRecorder()
+		server.handleLiveDataRequest(recorder, httptest.NewRequest(http.MethodGet, "/livedata?since="+since, nil))
+
+		var delta liveDataDelta
+		_ = json.Unmarshal(recorder.Body.Bytes(), &delta)
+		return recorder, delta
+	}
+
+	t.Run("when since is the current version it should return no fixtures", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 1)
+
+		// Act
+		recorder, delta := request(server, "1")
+
+		// Assert
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, uint64(1), delta.Version)
+		assert.False(t, delta.FullResyncRequired)
+		assert.Equal(t, 0, len(delta.Fixtures))
+	})
+
+	t.Run("when since is an older version it should return only the changed fixtures", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 1)
+		server.updateScoreAndPublish("fixture-id-2", "team-id-3", 1)
+		server.updateWinnerAndPublish("fixture-id-2", "team-id-3")
+
+		// Act
+		recorder, delta := request(server, "1")
+
+		// Assert
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, uint64(3), delta.Version)
+		assert.Equal(t, 1, len(delta.Fixtures))
+		assert.Equal(t, "fixture-id-2", delta.Fixtures[0].Id)
+		assert.Equal(t, 1, delta.Fixtures[0].Teams[0].Score)
+		assert.Equal(t, "team-id-3", delta.Fixtures[0].WinningTeamId)
+	})
+
+	t.Run("when since has been evicted it should require a full resync", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		server.changes = newChangeLog(1)
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 1)
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 2)
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 3)
+
+		// Act
+		recorder, delta := request(server, "0")
+
+		// Assert
+		assert.Equal(t, http.StatusGone, recorder.Code)
+		assert.True(t, delta.FullResyncRequired)
+		assert.Equal(t, uint64(3), delta.Version)
+	})
+
+	t.Run("when since is ahead of the viewmodel it should return bad request", func(t *testing.T) {
+		// Arrange
+		server := setup()
+
+		// Act
+		recorder, _ := request(server, "5")
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("when since is not a number it should return bad request", func(t *testing.T) {
+		// Arrange
+		server := setup()
+
+		// Act
+		recorder, _ := request(server, "abc")
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("when the viewmodel was rebuilt at a version it should require a resync for older versions", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		server.restoreVersion(42)
+
+		// Act
+		olderRecorder, olderDelta := request(server, "41")
+		recorder, delta := request(server, "42")
+
+		// Assert
+		assert.Equal(t, http.StatusGone, olderRecorder.Code)
+		assert.True(t, olderDelta.FullResyncRequired)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, uint64(42), delta.Version)
+		assert.False(t, delta.FullResyncRequired)
+	})
+}
@@ -0,0 +1,433 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+)
+
+const liveDataFixturesPath = "/livedata/fixtures"
+
+var (
+	errInvalidFixture  = errors.New("invalid fixture")
+	errFixtureNotFound = errors.New("fixture not found")
+	errFixtureExists   = errors.New("fixture already exists")
+	errInvalidTeam     = errors.New("invalid team")
+	errTeamNotFound    = errors.New("team not found")
+	errTeamExists      = errors.New("team already exists")
+	errTeamHasResult   = errors.New("team already has a result in the fixture")
+)
+
+// Fixture fields that can be updated, live data (scores and winner) is left untouched
+type fixtureDetails struct {
+	Title              string            `json:"title"`
+	Tournament         fixtureTournament `json:"tournament"`
+	ScheduledStartTime int64             `json:"scheduledStartTimeUnixSeconds"`
+}
+
+// Add a fixture
+func (server *LiveDataServer) addFixture(newFixture fixture) error {
+	return server.addFixtures([]fixture{newFixture})
+}
+
+// Add fixtures, all or none: nothing is added if one of them is invalid or its id is taken
+func (server *LiveDataServer) addFixtures(newFixtures []fixture) error {
+	fixtures := make([]*fixture, 0, len(newFixtures))
+	for _, newFixture := range newFixtures {
+		prepared, err := prepareFixture(newFixture)
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, prepared)
+	}
+
+	server.store.Lock()
+	defer server.store.Unlock()
+
+	// Every id is checked before anything is added
+	index := server.store.loadIndex()
+	fixtureIds := make(map[string]struct{}, len(fixtures))
+	for _, newFixture := range fixtures {
+		_, found := index.entries[newFixture.Id]
+		_, duplicate := fixtureIds[newFixture.Id]
+		if found || duplicate {
+			return errFixtureExists
+		}
+		fixtureIds[newFixture.Id] = struct{}{}
+	}
+
+	for _, newFixture := range fixtures {
+		index := server.store.loadIndex()
+		entry := newFixtureEntry(newFixture)
+		server.recordChange(func() {
+			server.store.index.Store(index.withFixture(newFixture, entry))
+		}, liveDataChange{
+			Type:      changeTypeFixtureAdded,
+			FixtureId: newFixture.Id,
+			Fixture:   newFixture,
+		}, patchOperation{
+			Op:    "add",
+			Path:  patchPath(newFixture.Id),
+			Value: newFixture,
+		})
+	}
+
+	return nil
+}
+
+// Validate a fixture to add, and initialize its status, series, sorted teams and standings
+func prepareFixture(newFixture fixture) (*fixture, error) {
+	if newFixture.Id == "" || (newFixture.Status != "" && !newFixture.Status.valid()) || !newFixture.initSeries() {
+		return nil, errInvalidFixture
+	}
+	newFixture.initStatus()
+
+	// Insert the teams one by one, so they're sorted and duplicates are caught
+	teams := newFixture.Teams
+	newFixture.Teams = make([]fixtureTeam, 0, len(teams))
+	for _, team := range teams {
+		if team.Id == "" || newFixture.insertTeam(team) < 0 {
+			return nil, errInvalidTeam
+		}
+	}
+	if !newFixture.initStandings() {
+		return nil, errInvalidFixture
+	}
+
+	return &newFixture, nil
+}
+
+// Update a fixture's title, tournament and schedule. Nothing is published if they didn't change.
+func (server *LiveDataServer) updateFixtureDetails(fixtureId string, details fixtureDetails) error {
+	// Changing the tournament changes the store's indexes
+	server.store.Lock()
+	defer server.store.Unlock()
+
+	entry, current := server.lockFixture(fixtureId)
+	if entry != nil {
+		defer entry.Unlock()
+	}
+	if current == nil {
+		return errFixtureNotFound
+	}
+
+	updated := current.fixture.clone()
+	operations := make([]patchOperation, 0)
+	if updated.Title != details.Title {
+		updated.Title = details.Title
+		operations = append(operations, patchOperation{Op: "replace", Path: patchPath(fixtureId, "title"), Value: details.Title})
+	}
+	if updated.Tournament != details.Tournament {
+		updated.Tournament = details.Tournament
+		operations = append(operations, patchOperation{Op: "replace", Path: patchPath(fixtureId, "tournament"), Value: details.Tournament})
+	}
+	if updated.ScheduledStartTime != details.ScheduledStartTime {
+		updated.ScheduledStartTime = details.ScheduledStartTime
+		operations = append(operations, patchOperation{Op: "replace", Path: patchPath(fixtureId, "scheduledStartTimeUnixSeconds"), Value: details.ScheduledStartTime})
+	}
+	if len(operations) == 0 {
+		return nil
+	}
+
+	index := server.store.loadIndex()
+	server.recordChange(func() {
+		entry.store(updated)
+		if updated.Tournament != current.fixture.Tournament {
+			server.store.index.Store(index.withFixtureChanged(current.fixture, updated))
+		}
+	}, liveDataChange{
+		Type:      changeTypeFixtureUpdated,
+		FixtureId: fixtureId,
+		Fixture:   updated,
+	}, operations...)
+
+	return nil
+}
+
+func (server *LiveDataServer) removeFixture(fixtureId string) error {
+	server.store.Lock()
+	defer server.store.Unlock()
+
+	entry, current := server.lockFixture(fixtureId)
+	if entry != nil {
+		defer entry.Unlock()
+	}
+	if current == nil {
+		return errFixtureNotFound
+	}
+
+	index := server.store.loadIndex()
+	server.recordChange(func() {
+		entry.store(nil)
+		server.store.index.Store(index.withoutFixture(current.fixture))
+	}, liveDataChange{
+		Type:      changeTypeFixtureRemoved,
+		FixtureId: fixtureId,
+	}, patchOperation{
+		Op:   "remove",
+		Path: patchPath(fixtureId),
+	})
+
+	return nil
+}
+
+// Add a team to a fixture, keeping its teams sorted
+func (server *LiveDataServer) addTeam(fixtureId string, team fixtureTeam) error {
+	if team.Id == "" {
+		return errInvalidTeam
+	}
+
+	server.store.Lock()
+	defer server.store.Unlock()
+
+	entry, current := server.lockFixture(fixtureId)
+	if entry != nil {
+		defer entry.Unlock()
+	}
+	if current == nil {
+		return errFixtureNotFound
+	}
+
+	updated := current.fixture.clone()
+	teamIndex := updated.insertTeam(team)
+	if teamIndex < 0 {
+		return errTeamExists
+	}
+	operations := []patchOperation{{
+		Op:    "add",
+		Path:  patchPath(fixtureId, "teams", strconv.Itoa(teamIndex)),
+		Value: team,
+	}}
+	updated.rankStandings()
+	if operation := standingsPatchOperation(updated); operation != nil {
+		operations = append(operations, *operation)
+	}
+
+	index := server.store.loadIndex()
+	server.recordChange(func() {
+		entry.store(updated)
+		server.store.index.Store(index.withFixtureChanged(current.fixture, updated))
+	}, liveDataChange{
+		Type:      changeTypeTeamAdded,
+		FixtureId: fixtureId,
+		TeamId:    team.Id,
+		Team:      &team,
+	}, operations...)
+
+	return nil
+}
+
+// Rename a team, its score is only changed by live updates
+func (server *LiveDataServer) updateTeamName(fixtureId string, teamId string, name string) error {
+	entry, current := server.lockFixture(fixtureId)
+	if entry != nil {
+		defer entry.Unlock()
+	}
+	if current == nil {
+		return errFixtureNotFound
+	}
+
+	teamIndex := current.findTeamIndex(teamId)
+	if teamIndex < 0 {
+		return errTeamNotFound
+	}
+	if current.fixture.Teams[teamIndex].Name == name {
+		return nil
+	}
+
+	updated := current.fixture.clone()
+	updated.Teams[teamIndex].Name = name
+	team := updated.Teams[teamIndex]
+
+	server.recordChange(func() {
+		entry.store(updated)
+	}, liveDataChange{
+		Type:      changeTypeTeamUpdated,
+		FixtureId: fixtureId,
+		TeamId:    teamId,
+		Team:      &team,
+	}, patchOperation{
+		Op:    "replace",
+		Path:  patchPath(fixtureId, "teams", strconv.Itoa(teamIndex), "name"),
+		Value: name,
+	})
+
+	return nil
+}
+
+func (server *LiveDataServer) removeTeam(fixtureId string, teamId string) error {
+	server.store.Lock()
+	defer server.store.Unlock()
+
+	entry, current := server.lockFixture(fixtureId)
+	if entry != nil {
+		defer entry.Unlock()
+	}
+	if current == nil {
+		return errFixtureNotFound
+	}
+
+	updated := current.fixture.clone()
+	teamIndex := updated.removeTeam(teamId)
+	if teamIndex < 0 {
+		return errTeamNotFound
+	}
+
+	// Results would be left without their team, the fixture's status or winner must be changed first
+	_, decided := current.fixture.result()
+	if decided || current.fixture.Status == fixtureStatusFinished || current.fixture.Teams[teamIndex].Placement != 0 ||
+		(current.fixture.Series != nil && current.fixture.Series.hasWonMap(teamId)) {
+		return errTeamHasResult
+	}
+
+	operations := []patchOperation{{
+		Op:   "remove",
+		Path: patchPath(fixtureId, "teams", strconv.Itoa(teamIndex)),
+	}}
+
+	if updated.Series != nil {
+		updated.Series.removeTeam(teamId)
+		operations = append(operations, patchOperation{Op: "replace", Path: patchPath(fixtureId, "series"), Value: updated.Series})
+	}
+	updated.rankStandings()
+	if operation := standingsPatchOperation(updated); operation != nil {
+		operations = append(operations, *operation)
+	}
+
+	index := server.store.loadIndex()
+	server.recordChange(func() {
+		entry.store(updated)
+		server.store.index.Store(index.withFixtureChanged(current.fixture, updated))
+	}, liveDataChange{
+		Type:      changeTypeTeamRemoved,
+		FixtureId: fixtureId,
+		TeamId:    teamId,
+	}, operations...)
+
+	return nil
+}
+
+// Routes:
+//
+//	POST   /livedata/fixtures                          add a fixture
+//	PUT    /livedata/fixtures/{fixtureId}              update a fixture's title, tournament and schedule
+//	DELETE /livedata/fixtures/{fixtureId}              remove a fixture
+//	POST   /livedata/fixtures/{fixtureId}/teams        add a team
+//	PUT    /livedata/fixtures/{fixtureId}/teams/{id}   rename a team
+//	DELETE /livedata/fixtures/{fixtureId}/teams/{id}   remove a team
+//	PUT    /livedata/fixtures/{fixtureId}/winner       correct the winner (an empty team id clears it)
+//	PUT    /livedata/fixtures/{fixtureId}/status       change the status (e.g. postpone or cancel the fixture)
+func (server *LiveDataServer) handleFixturesRequest(w http.ResponseWriter, r *http.Request) {
+	segments, err := pathSegments(r.URL, liveDataFixturesPath)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(segments) == 0 && r.Method == http.MethodPost:
+		var newFixture fixture
+		if !decodeFixturesRequest(w, r, &newFixture) {
+			return
+		}
+		writeFixturesResponse(w, http.StatusCreated, server.addFixture(newFixture))
+	case len(segments) == 1 && r.Method == http.MethodPut:
+		var details fixtureDetails
+		if !decodeFixturesRequest(w, r, &details) {
+			return
+		}
+		writeFixturesResponse(w, http.StatusNoContent, server.updateFixtureDetails(segments[0], details))
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		writeFixturesResponse(w, http.StatusNoContent, server.removeFixture(segments[0]))
+	case len(segments) == 2 && segments[1] == "teams" && r.Method == http.MethodPost:
+		var team fixtureTeam
+		if !decodeFixturesRequest(w, r, &team) {
+			return
+		}
+		writeFixturesResponse(w, http.StatusCreated, server.addTeam(segments[0], team))
+	case len(segments) == 3 && segments[1] == "teams" && r.Method == http.MethodPut:
+		var team fixtureTeam
+		if !decodeFixturesRequest(w, r, &team) {
+			return
+		}
+		writeFixturesResponse(w, http.StatusNoContent, server.updateTeamName(segments[0], segments[2], team.Name))
+	case len(segments) == 3 && segments[1] == "teams" && r.Method == http.MethodDelete:
+		writeFixturesResponse(w, http.StatusNoContent, server.removeTeam(segments[0], segments[2]))
+	case len(segments) == 2 && segments[1] == "winner" && r.Method == http.MethodPut:
+		var winner struct {
+			TeamId string `json:"teamId"`
+		}
+		if !decodeFixturesRequest(w, r, &winner) {
+			return
+		}
+		err := server.applyWinnerUpdate(external.EventEnvelope{Correction: true}, segments[0], winner.TeamId)
+		if err == nil {
+			server.advanceBracket(segments[0])
+		}
+		writeFixturesResponse(w, http.StatusNoContent, err)
+	case len(segments) == 2 && segments[1] == "status" && r.Method == http.MethodPut:
+		var status struct {
+			Status fixtureStatus `json:"status"`
+		}
+		if !decodeFixturesRequest(w, r, &status) {
+			return
+		}
+		writeFixturesResponse(w, http.StatusNoContent, server.applyStatusUpdate(external.EventEnvelope{}, segments[0], status.Status))
+	case len(segments) <= 1 || (segments[1] == "teams" && len(segments) <= 3) ||
+		((segments[1] == "winner" || segments[1] == "status") && len(segments) == 2):
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// Split (and unescape) the path following the given prefix, e.g. /livedata/fixtures
+func pathSegments(requestUrl *url.URL, prefix string) ([]string, error) {
+	path := strings.Trim(strings.TrimPrefix(requestUrl.EscapedPath(), prefix), "/")
+	if path == "" {
+		return nil, nil
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil || unescaped == "" {
+			return nil, fmt.Errorf("invalid path segment '%s'", segment)
+		}
+		segments[i] = unescaped
+	}
+
+	return segments, nil
+}
+
+func decodeFixturesRequest(w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(value); err != nil {
+		log.Print(fmt.Sprintf("@decodeFixturesRequest -> error decoding body: %s", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeFixturesResponse(w http.ResponseWriter, successStatus int, err error) {
+	switch err {
+	case nil:
+		w.WriteHeader(successStatus)
+	case errInvalidFixture, errInvalidTeam, errInvalidStatus, errInvalidBracket:
+		w.WriteHeader(http.StatusBadRequest)
+	case errFixtureNotFound, errTeamNotFound, errTournamentNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case errFixtureExists, errTeamExists, errTeamHasResult, errInvalidStatusTransition, errBracketExists:
+		w.WriteHeader(http.StatusConflict)
+	default:
+		log.Print(fmt.Sprintf("@writeFixturesResponse -> %s", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
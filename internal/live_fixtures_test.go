@@ -0,0 +1,293 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveDataFixtures(t *testing.T) {
+
+	setup := func() (*LiveDataServer, *external.MemoryPublisher) {
+		viewModel := &ViewModel{
+			fixture{
+				Id:            "fixture-id-1",
+				Teams:         []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-3"}},
+				WinningTeamId: "team-id-3",
+			},
+			fixture{
+				Id:    "fixture-id-3",
+				Teams: []fixtureTeam{{Id: "team-id-5"}, {Id: "team-id-6"}},
+			},
+		}
+		publisher := external.NewMemoryPublisher()
+		return newLiveDataServer(viewModel, publisher), publisher
+	}
+
+	fixtureIds := func(server *LiveDataServer) []string {
+		ids := make([]string, 0)
+		for _, fixture := range *server.store.viewModel() {
+			ids = append(ids, fixture.Id)
+		}
+		return ids
+	}
+
+	request := func(server *LiveDataServer, method string, path string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.handleFixturesRequest(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return recorder
+	}
+
+	t.Run("when addFixture is called it should insert the fixture in order", func(t *testing.T) {
+		// Arrange
+		server, publisher := setup()
+
+		// Act
+		err := server.addFixture(fixture{Id: "fixture-id-2", Teams: []fixtureTeam{{Id: "team-id-9"}, {Id: "team-id-8"}}})
+		server.publications.flush()
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"fixture-id-1", "fixture-id-2", "fixture-id-3"}, fixtureIds(server))
+		assert.Equal(t, 1, server.findFixture("fixture-id-2").findTeamIndex("team-id-9"))
+		assert.Equal(t, uint64(1), server.version)
+		assert.JSONEq(t, `{"version":1,"operations":[{"op":"add","path":"/fixtures/fixture-id-2","value":{"id":"fixture-id-2","title":"","tournament":{"id":"","name":""},"teams":[{"id":"team-id-8","name":"","score":0},{"id":"team-id-9","name":"","score":0}],"scheduledStartTimeUnixSeconds":0,"status":"scheduled","winningTeamId":""}}]}`, string(publisher.Messages()[0].Data))
+	})
+
+	t.Run("when addFixture is called with an existing fixture id it should return an error", func(t *testing.T) {
+		// Arrange
+		server, _ := setup()
+
+		// Act
+		err := server.addFixture(fixture{Id: "fixture-id-3"})
+
+		// Assert
+		assert.Equal(t, errFixtureExists, err)
+		assert.Equal(t, uint64(0), server.version)
+	})
+
+	t.Run("when addFixtures is called with an existing fixture id partway through the list it should add none of them", func(t *testing.T) {
+		// Arrange
+		server, _ := setup()
+
+		// Act
+		err := server.addFixtures([]fixture{{Id: "fixture-id-0"}, {Id: "fixture-id-3"}, {Id: "fixture-id-4"}})
+
+		// Assert
+		assert.Equal(t, errFixtureExists, err)
+		assert.Equal(t, []string{"fixture-id-1", "fixture-id-3"}, fixtureIds(server))
+		assert.Equal(t, uint64(0), server.currentVersion())
+	})
+
+	t.Run("when addFixture is called with duplicated teams it should return an error", func(t *testing.T) {
+		// Arrange
+		server, _ := setup()
+
+		// Act
+		err := server.addFixture(fixture{Id: "fixture-id-2", Teams: []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-1"}}})
+
+		// Assert
+		assert.Equal(t, errInvalidTeam, err)
+		assert.Nil(t, server.findFixture("fixture-id-2"))
+	})
+
+	t.Run("when updateFixtureDetails is called it should keep the live data", func(t *testing.T) {
+		// Arrange
+		server, publisher := setup()
+		_ = server.applyScoreUpdate(external.EventEnvelope{Correction: true}, "fixture-id-1", "team-id-1", 4)
+
+		// Act
+		err := server.updateFixtureDetails("fixture-id-1", fixtureDetails{Title: "new-title", ScheduledStartTime: 100})
+		server.publications.flush()
+
+		// Assert
+		assert.NoError(t, err)
+		fixture := server.findFixture("fixture-id-1")
+		assert.Equal(t, "new-title", fixture.Title)
+		assert.Equal(t, int64(100), fixture.ScheduledStartTime)
+		assert.Equal(t, 4, fixture.Teams[0].Score)
+		assert.Equal(t, "team-id-3", fixture.WinningTeamId)
+		assert.JSONEq(t, `{"version":2,"operations":[{"op":"replace","path":"/fixtures/fixture-id-1/title","value":"new-title"},{"op":"replace","path":"/fixtures/fixture-id-1/scheduledStartTimeUnixSeconds","value":100}]}`, string(publisher.Messages()[1].Data))
+	})
+
+	t.Run("when updateFixtureDetails doesn't change anything it should not publish", func(t *testing.T) {
+		// Arrange
+		server, publisher := setup()
+
+		// Act
+		err := server.updateFixtureDetails("fixture-id-1", fixtureDetails{})
+		server.publications.flush()
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), server.version)
+		assert.Equal(t, 0, len(publisher.Messages()))
+	})
+
+	t.Run("when removeFixture is called it should remove the fixture and notify subscribers", func(t *testing.T) {
+		// Arrange
+		server, publisher := setup()
+		subscriber := server.stream.subscribe()
+
+		// Act
+		err := server.removeFixture("fixture-id-1")
+		server.publications.flush()
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"fixture-id-3"}, fixtureIds(server))
+		assert.Equal(t, changeTypeFixtureRemoved, (<-subscriber).Type)
+		assert.JSONEq(t, `{"version":1,"operations":[{"op":"remove","path":"/fixtures/fixture-id-1"}]}`, string(publisher.Messages()[0].Data))
+	})
+
+	t.Run("when removeFixture is called with an unknown fixture id it should return an error", func(t *testing.T) {
+		// Arrange
+		server, _ := setup()
+
+		// Act
+		err := server.removeFixture("fixture-id-2")
+
+		// Assert
+		assert.Equal(t, errFixtureNotFound, err)
+	})
+
+	t.Run("when addTeam is called it should insert the team in order", func(t *testing.T) {
+		// Arrange
+		server, publisher := setup()
+
+		// Act
+		err := server.addTeam("fixture-id-1", fixtureTeam{Id: "team-id-2", Name: "team-name-2"})
+		server.publications.flush()
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-2", Name: "team-name-2"}, {Id: "team-id-3"}}, server.findFixture("fixture-id-1").Teams)
+		assert.JSONEq(t, `{"version":1,"operations":[{"op":"add","path":"/fixtures/fixture-id-1/teams/1","value":{"id":"team-id-2","name":"team-name-2","score":0}}]}`, string(publisher.Messages()[0].Data))
+	})
+
+	t.Run("when removeTeam is called for a team of a decided fixture it should refuse it", func(t *testing.T) {
+		// Arrange
+		server, _ := setup()
+
+		// Act
+		err := server.removeTeam("fixture-id-1", "team-id-3")
+		recorder := request(server, http.MethodDelete, "/livedata/fixtures/fixture-id-1/teams/team-id-1", "")
+
+		// Assert
+		assert.Equal(t, errTeamHasResult, err)
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.NotNil(t, server.findFixtureTeam("fixture-id-1", "team-id-3"))
+		assert.Equal(t, "team-id-3", server.findFixture("fixture-id-1").WinningTeamId)
+		assert.Equal(t, uint64(0), server.currentVersion())
+	})
+
+	t.Run("when removeTeam is called for a team of a series being played it should remove its scores from the series", func(t *testing.T) {
+		// Arrange
+		server, publisher := setup()
+		_ = server.addFixture(fixture{
+			Id:     "fixture-id-2",
+			Teams:  []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-2"}, {Id: "team-id-3"}},
+			Series: &fixtureSeries{Rules: external.MatchRules{BestOf: 3, MapScoreLimit: 2}},
+		})
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "fixture-id-2", 1, "team-id-1", 2)
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "fixture-id-2", 2, "team-id-2", 1)
+
+		// Act
+		wonMapErr := server.removeTeam("fixture-id-2", "team-id-1")
+		err := server.removeTeam("fixture-id-2", "team-id-2")
+		server.publications.flush()
+
+		// Assert
+		assert.Equal(t, errTeamHasResult, wonMapErr)
+		assert.NoError(t, err)
+		series := server.findFixture("fixture-id-2").Series
+		assert.Equal(t, map[string]int{"team-id-1": 1}, series.Score)
+		assert.Equal(t, map[string]int{}, series.Maps[1].Scores)
+		messages := publisher.Messages()
+		assert.Contains(t, string(messages[len(messages)-1].Data), `{"op":"replace","path":"/fixtures/fixture-id-2/series","value":`)
+	})
+
+	t.Run("when a fixture is removed the delta should list it as removed", func(t *testing.T) {
+		// Arrange
+		server, _ := setup()
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 1)
+		server.updateScoreAndPublish("fixture-id-3", "team-id-5", 1)
+
+		// Act
+		_ = server.removeFixture("fixture-id-1")
+
+		// Assert
+		delta, _ := server.changedFixturesSince(0)
+		assert.Equal(t, 1, len(delta.Fixtures))
+		assert.Equal(t, "fixture-id-3", delta.Fixtures[0].Id)
+		assert.Equal(t, []string{"fixture-id-1"}, delta.RemovedFixtureIds)
+	})
+
+	t.Run("when fixtures are added and removed through the api it should return the expected statuses", func(t *testing.T) {
+		// Arrange
+		server, _ := setup()
+
+		// Act
+		created := request(server, http.MethodPost, "/livedata/fixtures", `{"id":"fixture-id-2","teams":[{"id":"team-id-1"}]}`)
+		conflict := request(server, http.MethodPost, "/livedata/fixtures", `{"id":"fixture-id-2"}`)
+		updated := request(server, http.MethodPut, "/livedata/fixtures/fixture-id-2", `{"title":"new-title"}`)
+		teamCreated := request(server, http.MethodPost, "/livedata/fixtures/fixture-id-2/teams", `{"id":"team-id-2"}`)
+		teamUpdated := request(server, http.MethodPut, "/livedata/fixtures/fixture-id-2/teams/team-id-2", `{"name":"team-name-2"}`)
+		teamRemoved := request(server, http.MethodDelete, "/livedata/fixtures/fixture-id-2/teams/team-id-1", "")
+		removed := request(server, http.MethodDelete, "/livedata/fixtures/fixture-id-2", "")
+		notFound := request(server, http.MethodDelete, "/livedata/fixtures/fixture-id-2", "")
+
+		// Assert
+		assert.Equal(t, http.StatusCreated, created.Code)
+		assert.Equal(t, http.StatusConflict, conflict.Code)
+		assert.Equal(t, http.StatusNoContent, updated.Code)
+		assert.Equal(t, http.StatusCreated, teamCreated.Code)
+		assert.Equal(t, http.StatusNoContent, teamUpdated.Code)
+		assert.Equal(t, http.StatusNoContent, teamRemoved.Code)
+		assert.Equal(t, http.StatusNoContent, removed.Code)
+		assert.Equal(t, http.StatusNotFound, notFound.Code)
+		assert.Equal(t, uint64(6), server.version)
+	})
+
+	t.Run("when the winner is corrected through the api it should replace the decided winner", func(t *testing.T) {
+		// Arrange
+		server, _ := setup()
+
+		// Act
+		refused := server.updateWinnerAndPublish("fixture-id-1", "team-id-1")
+		corrected := request(server, http.MethodPut, "/livedata/fixtures/fixture-id-1/winner", `{"teamId":"team-id-1"}`)
+		unchanged := request(server, http.MethodPut, "/livedata/fixtures/fixture-id-1/winner", `{"teamId":"team-id-1"}`)
+		unknownTeam := request(server, http.MethodPut, "/livedata/fixtures/fixture-id-1/winner", `{"teamId":"team-id-9"}`)
+		cleared := request(server, http.MethodPut, "/livedata/fixtures/fixture-id-1/winner", `{"teamId":""}`)
+		methodNotAllowed := request(server, http.MethodPost, "/livedata/fixtures/fixture-id-1/winner", `{"teamId":""}`)
+
+		// Assert
+		assert.Equal(t, errWinnerDecided, refused)
+		assert.Equal(t, http.StatusNoContent, corrected.Code)
+		assert.Equal(t, http.StatusNoContent, unchanged.Code)
+		assert.Equal(t, http.StatusNotFound, unknownTeam.Code)
+		assert.Equal(t, http.StatusNoContent, cleared.Code)
+		assert.Equal(t, http.StatusMethodNotAllowed, methodNotAllowed.Code)
+		assert.Equal(t, "", server.findFixture("fixture-id-1").WinningTeamId)
+		assert.Equal(t, uint64(2), server.version)
+	})
+
+	t.Run("when the api is called with an invalid body or method it should return an error status", func(t *testing.T) {
+		// Arrange
+		server, _ := setup()
+
+		// Act
+		badRequest := request(server, http.MethodPost, "/livedata/fixtures", `{`)
+		methodNotAllowed := request(server, http.MethodGet, "/livedata/fixtures/fixture-id-1", "")
+		notFound := request(server, http.MethodGet, "/livedata/fixtures/fixture-id-1/players", "")
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, badRequest.Code)
+		assert.Equal(t, http.StatusMethodNotAllowed, methodNotAllowed.Code)
+		assert.Equal(t, http.StatusNotFound, notFound.Code)
+	})
+}
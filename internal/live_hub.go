@@ -0,0 +1,224 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zedronar/go-dummy-app.git/external/metrics"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	metricNameLiveHubClients     = "livehub.clients"
+	metricNameLiveHubSlowClients = "livehub.slow_clients"
+
+	liveHubSendBufferSize = 256
+	liveHubReadLimit      = 512
+	liveHubWriteTimeout   = 10 * time.Second
+	liveHubPongTimeout    = 60 * time.Second
+	liveHubPingPeriod     = (liveHubPongTimeout * 9) / 10
+)
+
+var liveHubUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// A viewmodel change, along with its fixture's tournament and the envelope of the live update it applies
+type liveHubMessage struct {
+	liveDataChange
+	TournamentId string `json:"tournamentId"`
+
+	// Envelope of the live update, so clients can detect duplicates and missed updates
+	EventId    string     `json:"eventId,omitempty"`
+	Sequence   uint64     `json:"sequence,omitempty"`
+	EmittedAt  *time.Time `json:"emittedAt,omitempty"`
+	ProducerId string     `json:"producerId,omitempty"`
+}
+
+func newLiveHubMessage(change liveDataChange, tournamentId string) liveHubMessage {
+	message := liveHubMessage{
+		liveDataChange: change,
+		TournamentId:   tournamentId,
+		EventId:        change.envelope.EventId,
+		Sequence:       change.envelope.Sequence,
+		ProducerId:     change.envelope.ProducerId,
+	}
+	if !change.envelope.EmittedAt.IsZero() {
+		message.EmittedAt = &change.envelope.EmittedAt
+	}
+	return message
+}
+
+// Fans out the viewmodel changes to the connected websocket clients, in version order: the live
+// updates that were applied, and the operators' corrections and fixture/team changes.
+// Every client has a bounded send buffer, clients that fall behind are disconnected
+// so recording a change never blocks.
+type liveHub struct {
+	sync.RWMutex
+	clients        map[*liveHubClient]struct{}
+	sendBufferSize int
+}
+
+type liveHubClient struct {
+	conn          *websocket.Conn
+	send          chan []byte
+	fixtureIds    map[string]struct{}
+	tournamentIds map[string]struct{}
+}
+
+func newLiveHub() *liveHub {
+	return &liveHub{
+		clients:        make(map[*liveHubClient]struct{}),
+		sendBufferSize: liveHubSendBufferSize,
+	}
+}
+
+func (hub *liveHub) handleLiveHubRequest(w http.ResponseWriter, r *http.Request) {
+	conn, err := liveHubUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already replied with an error status
+		log.Print(fmt.Sprintf("@handleLiveHubRequest -> error upgrading connection: %s", err.Error()))
+		return
+	}
+
+	client := &liveHubClient{
+		conn:          conn,
+		send:          make(chan []byte, hub.sendBufferSize),
+		fixtureIds:    parseIdFilter(r.URL.Query().Get("fixtureId")),
+		tournamentIds: parseIdFilter(r.URL.Query().Get("tournamentId")),
+	}
+	hub.add(client)
+
+	go hub.writePump(client)
+	go hub.readPump(client)
+}
+
+func (hub *liveHub) add(client *liveHubClient) {
+	hub.Lock()
+	defer hub.Unlock()
+
+	hub.clients[client] = struct{}{}
+	metrics.Set(metricNameLiveHubClients, int64(len(hub.clients)))
+}
+
+func (hub *liveHub) remove(client *liveHubClient) {
+	hub.Lock()
+	defer hub.Unlock()
+
+	if _, found := hub.clients[client]; found {
+		delete(hub.clients, client)
+		// Closing the send channel makes the write pump close the connection
+		close(client.send)
+		metrics.Set(metricNameLiveHubClients, int64(len(hub.clients)))
+	}
+}
+
+func (hub *liveHub) clientCount() int {
+	hub.RLock()
+	defer hub.RUnlock()
+
+	return len(hub.clients)
+}
+
+func (hub *liveHub) broadcast(message liveHubMessage) {
+	jsonBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Print(fmt.Sprintf("@broadcast -> error marshalling message: %s", err.Error()))
+		return
+	}
+
+	slowClients := make([]*liveHubClient, 0)
+
+	hub.RLock()
+	for client := range hub.clients {
+		if !client.accepts(message.FixtureId, message.TournamentId) {
+			continue
+		}
+		select {
+		case client.send <- jsonBytes:
+		default:
+			slowClients = append(slowClients, client)
+		}
+	}
+	hub.RUnlock()
+
+	for _, client := range slowClients {
+		log.Println("@broadcast -> disconnecting slow websocket client")
+		metrics.Increment(metricNameLiveHubSlowClients)
+		hub.remove(client)
+	}
+}
+
+// Clients only send control frames, so we just read until the connection is gone
+func (hub *liveHub) readPump(client *liveHubClient) {
+	defer hub.remove(client)
+
+	client.conn.SetReadLimit(liveHubReadLimit)
+	_ = client.conn.SetReadDeadline(time.Now().Add(liveHubPongTimeout))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(liveHubPongTimeout))
+	})
+
+	for {
+		if _, _, err := client.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (hub *liveHub) writePump(client *liveHubClient) {
+	ticker := time.NewTicker(liveHubPingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-client.send:
+			_ = client.conn.SetWriteDeadline(time.Now().Add(liveHubWriteTimeout))
+			if !ok {
+				_ = client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := client.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = client.conn.SetWriteDeadline(time.Now().Add(liveHubWriteTimeout))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Clients without filters receive every update
+func (client *liveHubClient) accepts(fixtureId string, tournamentId string) bool {
+	if len(client.fixtureIds) == 0 && len(client.tournamentIds) == 0 {
+		return true
+	}
+	if _, found := client.fixtureIds[fixtureId]; found {
+		return true
+	}
+	_, found := client.tournamentIds[tournamentId]
+	return found
+}
+
+// Parse a comma separated list of ids
+func parseIdFilter(value string) map[string]struct{} {
+	ids := make(map[string]struct{})
+	for _, id := range strings.Split(value, ",") {
+		id = strings.TrimSpace(id)
+		if id != "" {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids
+}
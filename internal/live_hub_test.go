@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+type testScoreUpdate struct {
+	envelope  external.EventEnvelope
+	fixtureId string
+	mapNumber int
+	teamId    string
+	score     int
+}
+
+func (u *testScoreUpdate) Envelope() external.EventEnvelope { return u.envelope }
+func (u *testScoreUpdate) FixtureId() string                { return u.fixtureId }
+func (u *testScoreUpdate) Map() int                         { return u.mapNumber }
+func (u *testScoreUpdate) TeamId() string                   { return u.teamId }
+func (u *testScoreUpdate) Score() int                       { return u.score }
+
+type testWinningTeamUpdate struct {
+	envelope  external.EventEnvelope
+	fixtureId string
+	teamId    string
+}
+
+func (u *testWinningTeamUpdate) Envelope() external.EventEnvelope { return u.envelope }
+func (u *testWinningTeamUpdate) FixtureId() string                { return u.fixtureId }
+func (u *testWinningTeamUpdate) TeamId() string                   { return u.teamId }
+
+func TestLiveHub(t *testing.T) {
+
+	setup := func() *LiveDataServer {
+		viewModel := &ViewModel{
+			{Id: "fixture-id-1", Tournament: fixtureTournament{Id: "tournament-id-1"}, Teams: []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-2"}}},
+			{Id: "fixture-id-2", Tournament: fixtureTournament{Id: "tournament-id-2"}, Teams: []fixtureTeam{{Id: "team-id-3"}, {Id: "team-id-4"}}},
+		}
+		return newLiveDataServer(viewModel, external.NewMemoryPublisher())
+	}
+
+	connect := func(t *testing.T, hub *liveHub, query string) (*websocket.Conn, func()) {
+		httpServer := httptest.NewServer(http.HandlerFunc(hub.handleLiveHubRequest))
+		url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + query
+
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		assert.NoError(t, err)
+
+		// Wait until the hub has registered the client
+		for i := 0; i < 100 && hub.clientCount() == 0; i++ {
+			time.Sleep(time.Millisecond)
+		}
+
+		return conn, func() {
+			conn.Close()
+			httpServer.Close()
+		}
+	}
+
+	readMessage := func(t *testing.T, conn *websocket.Conn) liveHubMessage {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		var message liveHubMessage
+		assert.NoError(t, conn.ReadJSON(&message))
+		return message
+	}
+
+	t.Run("when a score update is applied it should be sent to the clients with its version and envelope", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		conn, disconnect := connect(t, server.hub, "")
+		defer disconnect()
+		envelope := external.EventEnvelope{EventId: "event-id-1", Sequence: 1, ProducerId: "producer-id-1"}
+
+		// Act
+		_ = server.applyScoreUpdate(envelope, "fixture-id-1", "team-id-1", 4)
+		message := readMessage(t, conn)
+
+		// Assert
+		assert.Equal(t, uint64(1), message.Version)
+		assert.Equal(t, changeTypeScore, message.Type)
+		assert.Equal(t, "fixture-id-1", message.FixtureId)
+		assert.Equal(t, "tournament-id-1", message.TournamentId)
+		assert.Equal(t, "team-id-1", message.TeamId)
+		assert.Equal(t, 4, *message.Score)
+		assert.Equal(t, "event-id-1", message.EventId)
+		assert.Equal(t, uint64(1), message.Sequence)
+		assert.Equal(t, "producer-id-1", message.ProducerId)
+	})
+
+	t.Run("when an update is rejected it should not be sent to the clients", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		conn, disconnect := connect(t, server.hub, "")
+		defer disconnect()
+		envelope := external.EventEnvelope{EventId: "event-id-1", Sequence: 1, ProducerId: "producer-id-1"}
+
+		// Act
+		_ = server.applyScoreUpdate(envelope, "fixture-id-1", "team-id-1", 4)
+		duplicateErr := server.applyScoreUpdate(envelope, "fixture-id-1", "team-id-1", 4)
+		_ = server.updateScoreAndPublish("fixture-id-1", "team-id-2", 2)
+		first := readMessage(t, conn)
+		second := readMessage(t, conn)
+
+		// Assert
+		assert.Equal(t, errDuplicateUpdate, duplicateErr)
+		assert.Equal(t, "team-id-1", first.TeamId)
+		assert.Equal(t, uint64(2), second.Version)
+		assert.Equal(t, "team-id-2", second.TeamId)
+	})
+
+	t.Run("when an operator corrects a winner and removes a fixture it should be sent to the clients of its tournament", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		conn, disconnect := connect(t, server.hub, "?tournamentId=tournament-id-1")
+		defer disconnect()
+		_ = server.updateWinnerAndPublish("fixture-id-1", "team-id-1")
+
+		// Act
+		_ = server.applyWinnerUpdate(external.EventEnvelope{Correction: true}, "fixture-id-1", "team-id-2")
+		_ = server.removeFixture("fixture-id-1")
+		_ = readMessage(t, conn)
+		correction := readMessage(t, conn)
+		removal := readMessage(t, conn)
+
+		// Assert
+		assert.Equal(t, changeTypeWinner, correction.Type)
+		assert.Equal(t, "team-id-2", correction.TeamId)
+		assert.Equal(t, changeTypeFixtureRemoved, removal.Type)
+		assert.Equal(t, "fixture-id-1", removal.FixtureId)
+		assert.Equal(t, "tournament-id-1", removal.TournamentId)
+	})
+
+	t.Run("when a client filters by fixture id it should only receive that fixture's changes", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		conn, disconnect := connect(t, server.hub, "?fixtureId=fixture-id-2")
+		defer disconnect()
+
+		// Act
+		_ = server.updateWinnerAndPublish("fixture-id-1", "team-id-1")
+		_ = server.updateWinnerAndPublish("fixture-id-2", "team-id-3")
+		message := readMessage(t, conn)
+
+		// Assert
+		assert.Equal(t, changeTypeWinner, message.Type)
+		assert.Equal(t, "fixture-id-2", message.FixtureId)
+		assert.Equal(t, "team-id-3", message.TeamId)
+	})
+
+	t.Run("when a client filters by tournament id it should only receive that tournament's changes", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		conn, disconnect := connect(t, server.hub, "?tournamentId=tournament-id-1")
+		defer disconnect()
+
+		// Act
+		_ = server.updateScoreAndPublish("fixture-id-2", "team-id-3", 1)
+		_ = server.updateScoreAndPublish("fixture-id-1", "team-id-1", 2)
+		message := readMessage(t, conn)
+
+		// Assert
+		assert.Equal(t, "fixture-id-1", message.FixtureId)
+		assert.Equal(t, 2, *message.Score)
+	})
+
+	t.Run("when a client's send buffer is full it should be disconnected", func(t *testing.T) {
+		// Arrange
+		hub := newLiveHub()
+		client := &liveHubClient{send: make(chan []byte, 1)}
+		hub.add(client)
+
+		// Act
+		hub.broadcast(newLiveHubMessage(liveDataChange{FixtureId: "fixture-id-1"}, ""))
+		hub.broadcast(newLiveHubMessage(liveDataChange{FixtureId: "fixture-id-1"}, ""))
+
+		// Assert
+		assert.Equal(t, 0, hub.clientCount())
+		_, open := <-client.send
+		assert.True(t, open)
+		_, open = <-client.send
+		assert.False(t, open)
+	})
+
+	t.Run("when parseIdFilter is called it should return the trimmed ids", func(t *testing.T) {
+		// Act
+		ids := parseIdFilter(" F1, ,F2")
+
+		// Assert
+		assert.Equal(t, map[string]struct{}{"F1": {}, "F2": {}}, ids)
+	})
+}
+
+func TestLiveHubMessage(t *testing.T) {
+	t.Run("when a winner message is marshalled it should omit the score and the envelope", func(t *testing.T) {
+		// Act
+		jsonBytes, _ := json.Marshal(newLiveHubMessage(liveDataChange{Version: 3, Type: changeTypeWinner, FixtureId: "F1", TeamId: "TE1"}, "TO1"))
+
+		// Assert
+		assert.Equal(t, `{"version":3,"type":"winner","fixtureId":"F1","teamId":"TE1","tournamentId":"TO1"}`, string(jsonBytes))
+	})
+}
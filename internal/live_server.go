@@ -1,32 +1,87 @@
 package internal
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
 )
 
-var viewModelLock sync.RWMutex
+// Publish a full viewmodel snapshot every N changes, so patch consumers can resync
+const viewModelSnapshotInterval = 100
+
+// Version of the viewmodel returned by /livedata
+const liveDataVersionHeader = "X-Livedata-Version"
 
 type LiveDataServer struct {
-	viewModel                 *ViewModel
+	store                     *ViewModelStore
+	publisher                 external.Publisher
 	winningTeamUpdateReceiver winningTeamUpdateReceiver
 	scoreUpdateReceiver       scoreUpdateReceiver
+	statusUpdateReceiver      fixtureStatusUpdateReceiver
+	placementUpdateReceiver   placementUpdateReceiver
+	hub                       *liveHub
+
+	// Bearer token required by the mutation API, which is disabled when empty
+	adminToken string
+
+	// Live update subscriptions, removed by Close
+	subscriptionsLock sync.Mutex
+	subscriptions     []*external.Subscription
+
+	// Incremented on every viewmodel change, read it with currentVersion()
+	version uint64
+
+	// Serializes version bumps, change log appends, stream broadcasts and queueing the publications,
+	// so changes are recorded and published in version order. Nothing slow (I/O) is done holding it.
+	// Lock order: store, fixture entry, then this one.
+	changeLock   sync.Mutex
+	changes      *changeLog
+	stream       *liveDataStream
+	stats        *teamStatsRecorder
+	publications *publicationQueue
+
+	// Last /livedata response, reused until the version changes
+	responseCacheLock    sync.Mutex
+	responseCacheVersion uint64
+	responseCacheBody    []byte
+	responseCacheETag    string
+
+	// Elimination brackets by tournament id. Advancing teams adds them to fixtures, so this lock is
+	// taken before (and never while holding) the store's locks.
+	bracketsLock sync.Mutex
+	brackets     map[string]*tournamentBracket
 }
 
-func (server *LiveDataServer) handleLiveDataRequest(w http.ResponseWriter, _ *http.Request) {
-	viewModelLock.RLock()
-	defer viewModelLock.RUnlock()
+func (server *LiveDataServer) handleLiveDataRequest(w http.ResponseWriter, r *http.Request) {
+	// Clients holding a previous version can ask for the changed fixtures only
+	if since := r.URL.Query().Get("since"); since != "" {
+		server.handleLiveDataDeltaRequest(w, since)
+		return
+	}
 
-	jsonBytes, err := json.Marshal(server.viewModel)
+	jsonBytes, etag, version, err := server.liveDataResponse()
 	if err != nil {
 		log.Print(fmt.Sprintf("@handleLiveDataRequest -> error marshalling fixtures: %s", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+
+	// Clients pass the version to ?since= (or Last-Event-ID) to get the changes that follow this response
+	w.Header().Set(liveDataVersionHeader, strconv.FormatUint(version, 10))
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	_, err = w.Write(jsonBytes)
 	if err != nil {
 		log.Print(fmt.Sprintf("@handleLiveDataRequest -> error writing bytes: %s", err.Error()))
@@ -35,84 +90,392 @@ func (server *LiveDataServer) handleLiveDataRequest(w http.ResponseWriter, _ *ht
 	}
 }
 
-func newLiveDataServer(
-	viewModel *ViewModel,
-	winningTeamUpdateReceiver winningTeamUpdateReceiver,
-	scoreUpdateReceiver scoreUpdateReceiver) *LiveDataServer {
-	return &LiveDataServer{
-		viewModel:                 viewModel,
-		winningTeamUpdateReceiver: winningTeamUpdateReceiver,
-		scoreUpdateReceiver:       scoreUpdateReceiver,
+// Returns the marshalled viewmodel, its ETag (a digest of its content) and its version
+func (server *LiveDataServer) liveDataResponse() ([]byte, string, uint64, error) {
+	server.responseCacheLock.Lock()
+	defer server.responseCacheLock.Unlock()
+
+	// Read the version before the fixtures, so the cached body is never older than its version.
+	// It can include later changes, which ?since=<version> then returns again.
+	version := server.currentVersion()
+	if server.responseCacheBody != nil && server.responseCacheVersion == version {
+		return server.responseCacheBody, server.responseCacheETag, version, nil
 	}
+
+	jsonBytes, err := json.Marshal(server.store.list())
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	digest := sha256.Sum256(jsonBytes)
+	server.responseCacheVersion = version
+	server.responseCacheBody = jsonBytes
+	server.responseCacheETag = fmt.Sprintf("\"%x\"", digest[:16])
+
+	return server.responseCacheBody, server.responseCacheETag, version, nil
 }
 
-func (server *LiveDataServer) updateScoreAndPublish(fixtureId string, teamId string, newScore int) {
-	viewModelLock.Lock()
-	defer viewModelLock.Unlock()
+// Check an If-None-Match header against the current ETag (weak comparison, as per RFC 7232)
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
 
-	// Find fixture team
-	fixtureTeam := server.findFixtureTeam(fixtureId, teamId)
-	if fixtureTeam == nil {
-		log.Println(fmt.Sprintf("@updateScoreAndPublish -> fixtureId '%s' or teamId '%s' not found!", fixtureId, teamId))
-		return
+func newLiveDataServer(viewModel *ViewModel, publisher external.Publisher) *LiveDataServer {
+	server := &LiveDataServer{
+		store:     newViewModelStore(viewModel),
+		publisher: publisher,
+		changes:   newChangeLog(changeLogCapacity),
+		stream:    newLiveDataStream(),
+		stats:     newTeamStatsRecorder(),
+		brackets:  make(map[string]*tournamentBracket),
 	}
-	
-	// Update fixture team score
-	fixtureTeam.Score = newScore
+	server.publications = newPublicationQueue(publisher, server.store)
+
+	// Results the fixtures were loaded with count in the teams' stats too
+	server.store.each(func(fixture *fixture) {
+		server.stats.recordFixture(fixture, 0)
+	})
 
-	// Publish
-	server.viewModel.PublishViewModel()
+	// Receivers update this server, so several servers can run side by side
+	server.winningTeamUpdateReceiver = winningTeamUpdateReceiver{server: server}
+	server.scoreUpdateReceiver = scoreUpdateReceiver{server: server}
+	server.statusUpdateReceiver = fixtureStatusUpdateReceiver{server: server}
+	server.placementUpdateReceiver = placementUpdateReceiver{server: server}
+	server.hub = newLiveHub()
 
-	return
+	return server
 }
 
-func (server *LiveDataServer) updateWinnerAndPublish(fixtureId string, teamId string) {
-	viewModelLock.Lock()
-	defer viewModelLock.Unlock()
+// Receive the live updates published to the bus. Websocket clients get the changes they make,
+// so updates that are rejected never reach them.
+func (server *LiveDataServer) subscribe(bus *external.EventBus) {
+	server.subscriptionsLock.Lock()
+	defer server.subscriptionsLock.Unlock()
 
-	// Find fixture
-	fixture := server.findFixture(fixtureId)
-	if fixture == nil {
-		log.Println(fmt.Sprintf("@updateWinnerAndPublish -> fixtureId '%s' not found!", fixtureId))
-		return
+	// The viewmodel can't miss an update
+	viewModelOptions := external.SubscriptionOptions{OverflowPolicy: external.OverflowBlock}
+
+	// Statuses, scores, placements and winners share a queue, so they're received in the order they were published
+	server.subscriptions = append(server.subscriptions,
+		bus.SubscribeLiveUpdates(
+			&server.scoreUpdateReceiver,
+			&server.placementUpdateReceiver,
+			&server.winningTeamUpdateReceiver,
+			&server.statusUpdateReceiver,
+			viewModelOptions),
+	)
+}
+
+// Stop receiving live updates, and publish the changes still queued
+func (server *LiveDataServer) Close() {
+	server.subscriptionsLock.Lock()
+	for _, subscription := range server.subscriptions {
+		subscription.Unsubscribe()
 	}
+	server.subscriptions = nil
+	server.subscriptionsLock.Unlock()
 
-	// Update fixture winner
-	fixture.WinningTeamId = teamId
+	server.publications.close()
+}
+
+// Every live data endpoint is declared here
+func (server *LiveDataServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/livedata", server.handleLiveDataRequest)
+	mux.HandleFunc("/livedata/stream", server.handleLiveDataStreamRequest)
+	mux.HandleFunc("/livedata/ws", server.hub.handleLiveHubRequest)
+	mux.HandleFunc(liveDataFixturesPath, server.requireAdmin(server.handleFixturesRequest))
+	mux.HandleFunc(liveDataFixturesPath+"/", server.requireAdmin(server.handleFixturesRequest))
+	mux.HandleFunc(liveDataTeamsPath+"/", server.handleTeamsRequest)
+	mux.HandleFunc(tournamentsPath+"/", server.requireAdmin(server.handleTournamentsRequest))
+	return mux
+}
 
-	// Publish
-	server.viewModel.PublishViewModel()
+// Apply a score update without envelope (it isn't sequenced)
+func (server *LiveDataServer) updateScoreAndPublish(fixtureId string, teamId string, newScore int) error {
+	err := server.applyScoreUpdate(external.EventEnvelope{}, fixtureId, teamId, newScore)
+	if err == nil {
+		server.advanceBracket(fixtureId)
+	}
+	return err
+}
 
-	return
+// Apply a winner update without envelope (it isn't sequenced, and can't overwrite a different winner)
+func (server *LiveDataServer) updateWinnerAndPublish(fixtureId string, teamId string) error {
+	err := server.applyWinnerUpdate(external.EventEnvelope{}, fixtureId, teamId)
+	if err == nil {
+		server.advanceBracket(fixtureId)
+	}
+	return err
 }
 
-// Find fixture by id using binary search
-func (server *LiveDataServer) findFixture(fixtureId string) *fixture {
-	fixtureIndex := sort.Search(len(*server.viewModel), func(i int) bool {
-		return (*server.viewModel)[i].Id >= fixtureId
-	})
+// Update a team's score on the map being played
+func (server *LiveDataServer) applyScoreUpdate(envelope external.EventEnvelope, fixtureId string, teamId string, newScore int) error {
+	return server.applyMapScoreUpdate(envelope, fixtureId, 0, teamId, newScore)
+}
+
+// Update a team's score, unless the update is stale or a duplicate.
+// Scores only go down through corrections, a lower score is taken as a late update.
+// For fixtures with match rules the score is for the given map (0 for the map being played), which
+// is decided by the rules, as is the series once the map is over.
+func (server *LiveDataServer) applyMapScoreUpdate(envelope external.EventEnvelope, fixtureId string, mapNumber int, teamId string, newScore int) error {
+	// Only this fixture is locked, updates to other fixtures go on concurrently
+	entry, current := server.lockFixture(fixtureId)
+	if entry != nil {
+		defer entry.Unlock()
+	}
+
+	// Find fixture team
+	teamIndex := -1
+	if current != nil {
+		teamIndex = current.findTeamIndex(teamId)
+	}
+	if teamIndex < 0 {
+		log.Println(fmt.Sprintf("@applyMapScoreUpdate -> fixtureId '%s' or teamId '%s' not found!", fixtureId, teamId))
+		return errTeamNotFound
+	}
+
+	if err := entry.checkEnvelope(envelope, scoreUpdateField(teamId)); err != nil {
+		return err
+	}
+	if current.fixture.Series != nil {
+		if err := current.fixture.Series.checkMap(mapNumber); err != nil {
+			return err
+		}
+	}
+	currentScore := current.fixture.Teams[teamIndex].Score
+	if newScore == currentScore {
+		return errDuplicateUpdate
+	}
+	if newScore < currentScore && !envelope.Correction {
+		return errStaleUpdate
+	}
+	status, err := liveUpdateStatus(current.fixture.Status, fixtureStatusLive, envelope.Correction)
+	if err != nil {
+		return err
+	}
+
+	// Update fixture team score (on a copy, readers may still hold the current one)
+	updated := current.fixture.clone()
+	updated.Teams[teamIndex].Score = newScore
+	operations := []patchOperation{{
+		Op:    "replace",
+		Path:  patchPath(fixtureId, "teams", strconv.Itoa(teamIndex), "score"),
+		Value: newScore,
+	}}
 
-	if fixtureIndex >= len(*server.viewModel) || (*server.viewModel)[fixtureIndex].Id != fixtureId {
+	change := liveDataChange{
+		Type:      changeTypeScore,
+		FixtureId: fixtureId,
+		TeamId:    teamId,
+		Score:     &newScore,
+		envelope:  envelope,
+	}
+
+	// The rules decide the map, and then either the series or the next map
+	if updated.Series != nil {
+		change.Map = updated.Series.currentMap().Number
+		if updated.scoreMap(teamIndex, newScore) {
+			change.Series = updated.Series
+			operations = append(operations, mapDecidedPatchOperations(current.fixture, updated)...)
+		}
+		operations = append(operations, patchOperation{
+			Op:    "replace",
+			Path:  patchPath(fixtureId, "series"),
+			Value: updated.Series,
+		})
+
+		if updated.WinningTeamId != current.fixture.WinningTeamId {
+			change.WinningTeamId = updated.WinningTeamId
+		}
+		if updated.WinningTeamId != current.fixture.WinningTeamId || updated.Series.Draw {
+			if finished, err := liveUpdateStatus(status, fixtureStatusFinished, envelope.Correction); err == nil {
+				status = finished
+			}
+		}
+	}
+
+	// Scores are kills in free-for-all fixtures, which rank the teams
+	updated.rankStandings()
+	if operation := standingsPatchOperation(updated); operation != nil {
+		operations = append(operations, *operation)
+	}
+
+	// The first score starts the fixture, and the last score of a series with match rules finishes it
+	if status != updated.Status {
+		updated.Status = status
+		change.Status = status
+		operations = append(operations, statusPatchOperation(fixtureId, status))
+	}
+
+	// Notify subscribers and publish
+	server.recordChange(func() {
+		entry.store(updated)
+	}, change, operations...)
+	entry.commitEnvelope(envelope, fixtureId, scoreUpdateField(teamId))
+
+	return nil
+}
+
+// Set a fixture's winner. Once decided, the winner only changes through a correction, which can
+// also clear it (empty team id). The winner it already has is a no-op.
+func (server *LiveDataServer) applyWinnerUpdate(envelope external.EventEnvelope, fixtureId string, teamId string) error {
+	// Find fixture
+	entry, current := server.lockFixture(fixtureId)
+	if entry != nil {
+		defer entry.Unlock()
+	}
+	if current == nil {
+		log.Println(fmt.Sprintf("@applyWinnerUpdate -> fixtureId '%s' not found!", fixtureId))
+		return errFixtureNotFound
+	}
+	if (teamId != "" || !envelope.Correction) && current.findTeamIndex(teamId) < 0 {
+		return errTeamNotFound
+	}
+
+	if err := entry.checkEnvelope(envelope, updateFieldWinner); err != nil {
+		return err
+	}
+	// Producers may send the winner its last score or placement already decided, there's nothing to change
+	currentWinner := current.fixture.WinningTeamId
+	if teamId == currentWinner {
+		entry.commitEnvelope(envelope, fixtureId, updateFieldWinner)
 		return nil
 	}
+	if currentWinner != "" && !envelope.Correction {
+		return errWinnerDecided
+	}
+	status, err := liveUpdateStatus(current.fixture.Status, fixtureStatusFinished, envelope.Correction)
+	if err != nil {
+		return err
+	}
+
+	// Update fixture winner
+	updated := current.fixture.clone()
+	updated.WinningTeamId = teamId
+	operations := []patchOperation{{
+		Op:    "replace",
+		Path:  patchPath(fixtureId, "winningTeamId"),
+		Value: teamId,
+	}}
+
+	// Having a winner finishes the fixture
+	change := liveDataChange{
+		Type:      changeTypeWinner,
+		FixtureId: fixtureId,
+		TeamId:    teamId,
+		envelope:  envelope,
+	}
+	if status != updated.Status {
+		updated.Status = status
+		change.Status = status
+		operations = append(operations, statusPatchOperation(fixtureId, status))
+	}
 
-	return &(*server.viewModel)[fixtureIndex]
+	// Notify subscribers and publish
+	server.recordChange(func() {
+		entry.store(updated)
+	}, change, operations...)
+	entry.commitEnvelope(envelope, fixtureId, updateFieldWinner)
+
+	return nil
 }
 
-// Find fixtureTeam in fixture by id using binary search
-func (server *LiveDataServer) findFixtureTeam(fixtureId string, teamId string) *fixtureTeam {
+// Returns the tournament id of the given fixture, or an empty string if the fixture is unknown
+func (server *LiveDataServer) findTournamentId(fixtureId string) string {
 	fixture := server.findFixture(fixtureId)
 	if fixture == nil {
-		return nil
+		return ""
 	}
 
-	fixtureTeamIndex := sort.Search(len(fixture.Teams), func(i int) bool {
-		return fixture.Teams[i].Id >= teamId
-	})
+	return fixture.Tournament.Id
+}
 
-	if fixtureTeamIndex >= len(fixture.Teams) || fixture.Teams[fixtureTeamIndex].Id != teamId {
-		return nil
+// Continue the versions of a viewmodel rebuilt from a store. The changes before it aren't in the
+// change log, so clients asking for them must resync.
+func (server *LiveDataServer) restoreVersion(version uint64) {
+	server.changeLock.Lock()
+	defer server.changeLock.Unlock()
+
+	atomic.StoreUint64(&server.version, version)
+	server.changes.startAfter(version)
+}
+
+// Returns the viewmodel version, safe to call without holding any lock
+func (server *LiveDataServer) currentVersion() uint64 {
+	return atomic.LoadUint64(&server.version)
+}
+
+// Lock the fixture's entry and return it along with the fixture's current state.
+// The entry is nil if the fixture is unknown, the state is nil if it has been removed.
+func (server *LiveDataServer) lockFixture(fixtureId string) (*fixtureEntry, *fixtureSnapshot) {
+	entry := server.store.entry(fixtureId)
+	if entry == nil {
+		return nil, nil
+	}
+
+	entry.Lock()
+	return entry, entry.load()
+}
+
+// Apply a change (apply stores the new fixture/index snapshots), bump the viewmodel version,
+// notify stream and websocket subscribers about the change and queue it to be published as a patch (or as a full
+// snapshot every viewModelSnapshotInterval versions).
+// The change is applied while holding the changeLock, so it becomes visible along with its version.
+// It's logged before the version is bumped, so the change log is never behind the current version.
+func (server *LiveDataServer) recordChange(apply func(), change liveDataChange, operations ...patchOperation) {
+	server.changeLock.Lock()
+	defer server.changeLock.Unlock()
+
+	// Removed fixtures are still sent to the websocket clients following their tournament
+	tournamentId := server.findTournamentId(change.FixtureId)
+	apply()
+
+	version := server.currentVersion() + 1
+	change.Version = version
+
+	server.changes.append(change)
+	atomic.StoreUint64(&server.version, version)
+	server.stream.broadcast(change)
+	fixture := server.findFixture(change.FixtureId)
+	if fixture != nil {
+		tournamentId = fixture.Tournament.Id
+	}
+	server.hub.broadcast(newLiveHubMessage(change, tournamentId))
+	server.stats.record(change, fixture)
+
+	if version%viewModelSnapshotInterval == 0 {
+		server.publications.enqueue(server.publications.snapshot(version))
+		return
 	}
+	server.publications.enqueue(publication{
+		version:    version,
+		operations: operations,
+		fixtureId:  change.FixtureId,
+		fixture:    fixture,
+	})
+}
+
+// Queue a full snapshot of the viewmodel at its current version, e.g. on startup
+func (server *LiveDataServer) publishSnapshot() {
+	server.changeLock.Lock()
+	defer server.changeLock.Unlock()
 
-	return &fixture.Teams[fixtureTeamIndex]
-}
\ No newline at end of file
+	server.publications.enqueue(server.publications.snapshot(server.currentVersion()))
+}
+
+// Find fixture by id
+func (server *LiveDataServer) findFixture(fixtureId string) *fixture {
+	return server.store.fixture(fixtureId)
+}
+
+// Find fixtureTeam in fixture by id
+func (server *LiveDataServer) findFixtureTeam(fixtureId string, teamId string) *fixtureTeam {
+	return server.store.fixtureTeam(fixtureId, teamId)
+}
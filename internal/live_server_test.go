@@ -1,8 +1,14 @@
 package internal
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Zedronar/go-dummy-app.git/external"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,27 +39,23 @@ func TestLiveDataServer(t *testing.T) {
 	}
 
 	setup := func() *LiveDataServer {
-		winningTeamUpdateReceiver := &winningTeamUpdateReceiver{}
-		scoreUpdateReceiver := &scoreUpdateReceiver{}
-		
-		return newLiveDataServer(defaultViewModel, *winningTeamUpdateReceiver, *scoreUpdateReceiver)
+		return newLiveDataServer(defaultViewModel, external.NewMemoryPublisher())
 	}
 
 	t.Run("when newLiveDataServer is called it should return a valid LiveDataServer", func(t *testing.T) {
 		// Arrange
 		viewModel := &ViewModel{}
-		winningTeamUpdateReceiver := &winningTeamUpdateReceiver{}
-		scoreUpdateReceiver := &scoreUpdateReceiver{}
+		publisher := external.NewMemoryPublisher()
 		
 		// Act
-		server := newLiveDataServer(viewModel, *winningTeamUpdateReceiver, *scoreUpdateReceiver)
+		server := newLiveDataServer(viewModel, publisher)
 
 		// Assert
 		assert.NotNil(t, server)
-		assert.NotNil(t, server.winningTeamUpdateReceiver)
-		assert.Equal(t, winningTeamUpdateReceiver, &server.winningTeamUpdateReceiver)
-		assert.NotNil(t, server.scoreUpdateReceiver)
-		assert.Equal(t, scoreUpdateReceiver, &server.scoreUpdateReceiver)
+		assert.Equal(t, publisher, server.publisher)
+		assert.Equal(t, server, server.winningTeamUpdateReceiver.server)
+		assert.Equal(t, server, server.scoreUpdateReceiver.server)
+		assert.NotNil(t, server.hub)
 	})
 
 	t.Run("when updateScoreAndPublish is called it should update the score", func(t *testing.T) {
@@ -61,7 +63,7 @@ func TestLiveDataServer(t *testing.T) {
 		server := setup()
 		
 		// Check precondition - score should be 0
-		assert.Equal(t, 0, (*server.viewModel)[0].Teams[0].Score)
+		assert.Equal(t, 0, (*server.store.viewModel())[0].Teams[0].Score)
 
 		// Act
 		fixtureId := "fixture-id-1"
@@ -69,7 +71,7 @@ func TestLiveDataServer(t *testing.T) {
 		server.updateScoreAndPublish(fixtureId, teamID, 1)
 		
 		// Assert
-		assert.Equal(t, 1, (*server.viewModel)[0].Teams[0].Score)
+		assert.Equal(t, 1, (*server.store.viewModel())[0].Teams[0].Score)
 	})
 
 	t.Run("when updateWinnerAndPublish is called it should update the winner", func(t *testing.T) {
@@ -77,7 +79,7 @@ func TestLiveDataServer(t *testing.T) {
 		server := setup()
 		
 		// Check precondition - winner should be empty string
-		assert.Equal(t, "", (*server.viewModel)[0].WinningTeamId)
+		assert.Equal(t, "", (*server.store.viewModel())[0].WinningTeamId)
 
 		// Act
 		fixtureId := "fixture-id-1"
@@ -85,7 +87,117 @@ func TestLiveDataServer(t *testing.T) {
 		server.updateWinnerAndPublish(fixtureId, teamID)
 		
 		// Assert
-		assert.Equal(t, "team-id-2", (*server.viewModel)[0].WinningTeamId)
+		assert.Equal(t, "team-id-2", (*server.store.viewModel())[0].WinningTeamId)
+	})
+
+	t.Run("when the viewmodel is updated it should bump the version", func(t *testing.T) {
+		// Arrange
+		server := setup()
+
+		// Act
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 2)
+		server.updateWinnerAndPublish("fixture-id-1", "team-id-1")
+		server.updateScoreAndPublish("invalid-fixture-id", "team-id-1", 3)
+
+		// Assert
+		assert.Equal(t, uint64(2), server.version)
+	})
+
+	t.Run("when the viewmodel is updated it should publish a patch", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		publisher := external.NewMemoryPublisher()
+		server.publications = newPublicationQueue(publisher, server.store)
+
+		// Act
+		server.updateScoreAndPublish("fixture-id-1", "team-id-2", 7)
+		server.publications.flush()
+
+		// Assert
+		messages := publisher.Messages()
+		assert.Equal(t, 1, len(messages))
+		assert.Equal(t, external.PublishedMessageTypeViewModelPatch, messages[0].Type)
+		assert.JSONEq(t, `{"version":1,"operations":[{"op":"replace","path":"/fixtures/fixture-id-1/teams/1/score","value":7},{"op":"replace","path":"/fixtures/fixture-id-1/status","value":"live"}]}`, string(messages[0].Data))
+	})
+
+	t.Run("when the version reaches the snapshot interval it should publish the full viewmodel", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		publisher := external.NewMemoryPublisher()
+		server.publications = newPublicationQueue(publisher, server.store)
+		server.version = viewModelSnapshotInterval - 1
+
+		// Act
+		server.updateWinnerAndPublish("fixture-id-1", "team-id-2")
+		server.publications.flush()
+
+		// Assert
+		messages := publisher.Messages()
+		assert.Equal(t, 1, len(messages))
+		assert.Equal(t, external.PublishedMessageTypeViewModel, messages[0].Type)
+		assert.Contains(t, string(messages[0].Data), `"version":100`)
+	})
+
+	t.Run("when handleLiveDataRequest is called it should return the viewmodel with an ETag", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.handleLiveDataRequest(recorder, httptest.NewRequest(http.MethodGet, "/livedata", nil))
+
+		// Assert
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.NotEmpty(t, recorder.Header().Get("ETag"))
+		assert.Contains(t, recorder.Body.String(), `"id":"fixture-id-1"`)
+	})
+
+	t.Run("when handleLiveDataRequest is called with a matching If-None-Match it should return not modified", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		firstRecorder := httptest.NewRecorder()
+		server.handleLiveDataRequest(firstRecorder, httptest.NewRequest(http.MethodGet, "/livedata", nil))
+		etag := firstRecorder.Header().Get("ETag")
+
+		// Act
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/livedata", nil)
+		request.Header.Set("If-None-Match", etag)
+		server.handleLiveDataRequest(recorder, request)
+
+		// Assert
+		assert.Equal(t, http.StatusNotModified, recorder.Code)
+		assert.Equal(t, etag, recorder.Header().Get("ETag"))
+		assert.Empty(t, recorder.Body.Bytes())
+	})
+
+	t.Run("when the viewmodel changes handleLiveDataRequest should return a new ETag", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		firstRecorder := httptest.NewRecorder()
+		server.handleLiveDataRequest(firstRecorder, httptest.NewRequest(http.MethodGet, "/livedata", nil))
+		etag := firstRecorder.Header().Get("ETag")
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 5)
+
+		// Act
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/livedata", nil)
+		request.Header.Set("If-None-Match", etag)
+		server.handleLiveDataRequest(recorder, request)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.NotEqual(t, etag, recorder.Header().Get("ETag"))
+		assert.Equal(t, "0", firstRecorder.Header().Get("X-Livedata-Version"))
+		assert.Equal(t, "1", recorder.Header().Get("X-Livedata-Version"))
+	})
+
+	t.Run("when etagMatches is called it should handle lists, weak tags and wildcards", func(t *testing.T) {
+		assert.True(t, etagMatches(`"a", "b"`, `"b"`))
+		assert.True(t, etagMatches(`W/"b"`, `"b"`))
+		assert.True(t, etagMatches(`*`, `"b"`))
+		assert.False(t, etagMatches(`"a"`, `"b"`))
+		assert.False(t, etagMatches(``, `"b"`))
 	})
 
 	t.Run("when findFixture is called it should return the fixture", func(t *testing.T) {
@@ -150,4 +262,103 @@ func TestLiveDataServer(t *testing.T) {
 		// Assert
 		assert.Nil(t, fixtureTeam)
 	})
-}
\ No newline at end of file
+}
+// Run with the race detector: go test -race ./internal/
+func TestLiveDataServerConcurrency(t *testing.T) {
+
+	const fixtureCount = 8
+	const updateCount = 200
+
+	setup := func() *LiveDataServer {
+		viewModel := make(ViewModel, 0, fixtureCount)
+		for i := 0; i < fixtureCount; i++ {
+			viewModel = append(viewModel, fixture{
+				Id:         fmt.Sprintf("fixture-id-%d", i),
+				Tournament: fixtureTournament{Id: "tournament-id"},
+				Teams:      []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-2"}},
+			})
+		}
+		return newLiveDataServer(&viewModel, external.NewMemoryPublisher())
+	}
+
+	t.Run("when fixtures are updated and read concurrently it should apply every update", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		done := make(chan struct{})
+		var readers sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			readers.Add(1)
+			go func() {
+				defer readers.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					recorder := httptest.NewRecorder()
+					server.handleLiveDataRequest(recorder, httptest.NewRequest(http.MethodGet, "/livedata", nil))
+					_, _ = server.changedFixturesSince(0)
+					server.findTournamentId("fixture-id-0")
+					server.store.fixturesByTeam("team-id-1")
+				}
+			}()
+		}
+
+		// Act
+		var writers sync.WaitGroup
+		for i := 0; i < fixtureCount; i++ {
+			writers.Add(1)
+			go func(fixtureId string) {
+				defer writers.Done()
+				for score := 1; score <= updateCount; score++ {
+					server.updateScoreAndPublish(fixtureId, "team-id-1", score)
+				}
+				server.updateWinnerAndPublish(fixtureId, "team-id-1")
+			}(fmt.Sprintf("fixture-id-%d", i))
+		}
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for i := 0; i < updateCount; i++ {
+				_ = server.addFixture(fixture{Id: "fixture-id-added", Teams: []fixtureTeam{{Id: "team-id-1"}}})
+				_ = server.removeFixture("fixture-id-added")
+			}
+		}()
+		writers.Wait()
+		close(done)
+		readers.Wait()
+
+		// Assert
+		assert.Equal(t, uint64(fixtureCount*(updateCount+1)+updateCount*2), server.currentVersion())
+		for i := 0; i < fixtureCount; i++ {
+			assert.Equal(t, updateCount, server.findFixtureTeam(fmt.Sprintf("fixture-id-%d", i), "team-id-1").Score)
+		}
+		assert.Nil(t, server.findFixture("fixture-id-added"))
+		assert.Equal(t, fixtureCount, server.store.len())
+	})
+
+	t.Run("when a fixture is being updated it should not block other fixtures or readers", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		entry, _ := server.lockFixture("fixture-id-0")
+		defer entry.Unlock()
+		finished := make(chan struct{})
+
+		// Act
+		go func() {
+			server.updateScoreAndPublish("fixture-id-1", "team-id-1", 1)
+			recorder := httptest.NewRecorder()
+			server.handleLiveDataRequest(recorder, httptest.NewRequest(http.MethodGet, "/livedata", nil))
+			close(finished)
+		}()
+
+		// Assert
+		select {
+		case <-finished:
+		case <-time.After(5 * time.Second):
+			t.Fatal("update blocked by another fixture's lock")
+		}
+		assert.Equal(t, 1, server.findFixtureTeam("fixture-id-1", "team-id-1").Score)
+	})
+}
@@ -1,47 +1,71 @@
 package internal
 
 import (
+	"context"
 	"encoding/json"
-	"io/ioutil"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/Zedronar/go-dummy-app.git/internal/data"
 )
 
-var liveDataServer *LiveDataServer
+// Returned by InitLiveServer when the initial fixtures can't be retrieved
+type FixturesUnavailableError struct {
+	Err error
+}
 
-func InitLiveServer() {
-	// Query for initial fixtures
-	viewModel := getStaticFixtures()
+func (e *FixturesUnavailableError) Error() string {
+	return fmt.Sprintf("fixtures unavailable: %s", e.Err.Error())
+}
 
-	// Sort viewmodel's fixtures and teams, so we can access them using binary search from now on.
-	// TODO: In production, if fixtures are added dynamically, we should sort on every addition (see ADR.md).
-	viewModel.Sort()
+func (e *FixturesUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// Build the live data server and the http.Handler serving its endpoints, callers decide where to serve it.
+// Fixtures are re-polled from the data provider every syncInterval (never if it's 0) until ctx is done.
+// Live updates are received from the bus until the server is closed.
+func InitLiveServer(
+	ctx context.Context,
+	publisher external.Publisher,
+	bus *external.EventBus,
+	dataProvider data.DataProvider,
+	syncInterval time.Duration,
+	adminToken string) (*LiveDataServer, http.Handler, error) {
+	// Rebuild the viewmodel from the publisher's store, or query for initial fixtures if there's none
+	viewModel, version := loadPublishedViewModel(publisher)
+	// Fixtures the viewmodel was loaded with from the source, the reconciler only retires those
+	var sourceFixtures []fixture
+	if viewModel == nil {
+		fixtures, err := dataProvider.Retrieve(ctx)
+		if err != nil {
+			return nil, nil, &FixturesUnavailableError{Err: err}
+		}
+		viewModel = newViewModel(fixtures)
+		sourceFixtures = *viewModel
+	}
 
 	// Initialize live data server
-	winningTeamUpdateReceiver := &winningTeamUpdateReceiver{}
-	scoreUpdateReceiver := &scoreUpdateReceiver{}
-	liveDataServer = newLiveDataServer(viewModel, *winningTeamUpdateReceiver, *scoreUpdateReceiver)
+	liveDataServer := newLiveDataServer(viewModel, publisher)
+	liveDataServer.restoreVersion(version)
+	liveDataServer.adminToken = adminToken
 
-	// Register live score receivers
-	external.RegisterWinningTeamUpdateReceivers(&liveDataServer.winningTeamUpdateReceiver)
-	external.RegisterScoreUpdateReceivers(&liveDataServer.scoreUpdateReceiver)
+	// Receive live scores
+	liveDataServer.subscribe(bus)
 
 	// Initial viewModel publish
-	liveDataServer.viewModel.PublishViewModel()
-
-	// Handle live data requests
-	http.HandleFunc("/livedata", liveDataServer.handleLiveDataRequest)
-}
+	liveDataServer.publishSnapshot()
 
-// 1- Use hash/digest?
-// --> Getting a hash string from the client
-// --> Compare it to the current hash (app level / redis)
-// ^ read heavy scenario
+	// Keep the viewmodel in sync with the fixtures source
+	if syncInterval > 0 {
+		go newFixtureReconciler(liveDataServer, dataProvider, syncInterval, sourceFixtures).run(ctx)
+	}
 
-// 2- Diffs/deltas
+	return liveDataServer, liveDataServer.handler(), nil
+}
 
 // * Cache
 // -> MISS ?
@@ -52,61 +76,62 @@ func InitLiveServer() {
 
 // Massive data? -> LRU on cache (redis)
 
-func getStaticFixtures() *ViewModel {
-
-	// TODO: Implement retry mechanism
-	// * Exponential backoff
-	// * Max 3 retries
-	// try / catch
-	resp, err := http.Get("http://localhost:8080/fixtures")
-	callFailureMax := 3
-	failureCount := 0
-	exponentialBackoff := 1
-
-	for err != nil && failureCount < callFailureMax {
-		resp, err = http.Get("http://localhost:8080/fixtures")
-
-		// sleep
-		// TODO: Fix exponential
-		time.Sleep(time.Second * 1)
-
-		// inc
-		failureCount++
-		exponentialBackoff *= 2
-
-		// On each failure (might be transient)
-		log.Fatal(err)
+// Returns the viewmodel (and its version) last published to a store, or nil if there's none
+func loadPublishedViewModel(publisher external.Publisher) (*ViewModel, uint64) {
+	loader, ok := publisher.(external.StateLoader)
+	if !ok {
+		return nil, 0
 	}
 
+	state, err := loader.LoadState()
 	if err != nil {
-		// Passed max attempts
-		log.Fatal(err)
-		panic("Fatal error - called too many times...")
+		log.Print(fmt.Sprintf("@loadPublishedViewModel -> error loading published state: %s", err.Error()))
+		return nil, 0
+	}
+	if state == nil {
+		return nil, 0
 	}
 
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-
-	if err != nil {
-		log.Fatal(err)
+	viewModel := make(ViewModel, 0, len(state.Fixtures))
+	for _, fixtureJson := range state.Fixtures {
+		var fixture fixture
+		if err := json.Unmarshal(fixtureJson, &fixture); err != nil {
+			log.Print(fmt.Sprintf("@loadPublishedViewModel -> error unmarshalling fixture: %s", err.Error()))
+			return nil, 0
+		}
+		viewModel = append(viewModel, fixture)
 	}
 
-	var viewmodel ViewModel
-	json.Unmarshal(body, &viewmodel)
-	return &viewmodel
+	log.Println(fmt.Sprintf("rebuilt view model from published state at version %d", state.Version))
+
+	return &viewModel, state.Version
 }
 
-type scoreUpdateReceiver struct{}
+type scoreUpdateReceiver struct {
+	server *LiveDataServer
+}
 
 func (t *scoreUpdateReceiver) Receive(update external.ScoreUpdate) {
 	// Update viewmodel with new team score and publish it
-	liveDataServer.updateScoreAndPublish(update.FixtureId(), update.TeamId(), update.Score())
+	err := t.server.applyMapScoreUpdate(update.Envelope(), update.FixtureId(), update.Map(), update.TeamId(), update.Score())
+	countRejectedUpdate(err, update.Envelope(), update.FixtureId())
+
+	// The score can decide a series, whose winner advances in the tournament's bracket
+	if err == nil {
+		t.server.advanceBracket(update.FixtureId())
+	}
 }
 
-type winningTeamUpdateReceiver struct{}
+type winningTeamUpdateReceiver struct {
+	server *LiveDataServer
+}
 
 func (t *winningTeamUpdateReceiver) Receive(update external.WinningTeamUpdate) {
 	// Update viewmodel with new winning team and publish it
-	liveDataServer.updateWinnerAndPublish(update.FixtureId(), update.TeamId())
+	err := t.server.applyWinnerUpdate(update.Envelope(), update.FixtureId(), update.TeamId())
+	countRejectedUpdate(err, update.Envelope(), update.FixtureId())
+
+	if err == nil {
+		t.server.advanceBracket(update.FixtureId())
+	}
 }
@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/Zedronar/go-dummy-app.git/internal/data"
+	"github.com/stretchr/testify/assert"
+)
+
+// Publisher backed by a store, like the redis publisher
+type testStorePublisher struct {
+	*external.MemoryPublisher
+	state    *external.PublishedState
+	loadErr  error
+	fixtures map[string]string
+}
+
+func newTestStorePublisher() *testStorePublisher {
+	return &testStorePublisher{
+		MemoryPublisher: external.NewMemoryPublisher(),
+		fixtures:        make(map[string]string),
+	}
+}
+
+func (p *testStorePublisher) PublishFixture(version uint64, fixtureId string, fixture interface{}) error {
+	jsonBytes, _ := json.Marshal(fixture)
+	p.fixtures[fixtureId] = string(jsonBytes)
+	return nil
+}
+
+func (p *testStorePublisher) LoadState() (*external.PublishedState, error) {
+	return p.state, p.loadErr
+}
+
+func TestLoadPublishedViewModel(t *testing.T) {
+
+	t.Run("when publisher has no store it should return nil", func(t *testing.T) {
+		// Act
+		viewModel, version := loadPublishedViewModel(external.NewMemoryPublisher())
+
+		// Assert
+		assert.Nil(t, viewModel)
+		assert.Equal(t, uint64(0), version)
+	})
+
+	t.Run("when store is empty it should return nil", func(t *testing.T) {
+		// Arrange
+		publisher := newTestStorePublisher()
+
+		// Act
+		viewModel, _ := loadPublishedViewModel(publisher)
+
+		// Assert
+		assert.Nil(t, viewModel)
+	})
+
+	t.Run("when store fails to load it should return nil", func(t *testing.T) {
+		// Arrange
+		publisher := newTestStorePublisher()
+		publisher.loadErr = errors.New("connection refused")
+
+		// Act
+		viewModel, _ := loadPublishedViewModel(publisher)
+
+		// Assert
+		assert.Nil(t, viewModel)
+	})
+
+	t.Run("when store has fixtures it should rebuild the viewmodel", func(t *testing.T) {
+		// Arrange
+		publisher := newTestStorePublisher()
+		publisher.state = &external.PublishedState{
+			Version: 42,
+			Fixtures: []json.RawMessage{
+				json.RawMessage(`{"id":"F1","teams":[{"id":"TE1","score":3}],"winningTeamId":"TE1"}`),
+			},
+		}
+
+		// Act
+		viewModel, version := loadPublishedViewModel(publisher)
+
+		// Assert
+		assert.Equal(t, uint64(42), version)
+		assert.Equal(t, 1, len(*viewModel))
+		assert.Equal(t, "F1", (*viewModel)[0].Id)
+		assert.Equal(t, 3, (*viewModel)[0].Teams[0].Score)
+		assert.Equal(t, "TE1", (*viewModel)[0].WinningTeamId)
+	})
+
+	t.Run("when the viewmodel changes it should publish the fixture to the store", func(t *testing.T) {
+		// Arrange
+		publisher := newTestStorePublisher()
+		viewModel := &ViewModel{fixture{Id: "F1", Teams: []fixtureTeam{{Id: "TE1"}}}}
+		server := newLiveDataServer(viewModel, publisher)
+
+		// Act
+		server.updateScoreAndPublish("F1", "TE1", 2)
+		server.publications.flush()
+
+		// Assert
+		assert.Contains(t, publisher.fixtures["F1"], `"score":2`)
+	})
+}
+
+func TestInitLiveServer(t *testing.T) {
+
+	request := func(handler http.Handler, method string, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		httpRequest := httptest.NewRequest(method, path, nil)
+		httpRequest.Header.Set("Authorization", "Bearer "+testAdminToken)
+		handler.ServeHTTP(recorder, httpRequest)
+		return recorder
+	}
+
+	t.Run("when fixtures are retrieved it should serve them through the returned handler", func(t *testing.T) {
+		// Arrange
+		dataProvider := data.NewMemoryProvider(data.Fixture{Id: "INIT1", Teams: []data.FixtureTeam{{Id: "TE1"}}})
+
+		// Act
+		server, handler, err := InitLiveServer(context.Background(), external.NewMemoryPublisher(), external.NewEventBus(external.DefaultEventBusQueueSize), dataProvider, 0, testAdminToken)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NotNil(t, server.findFixture("INIT1"))
+		recorder := request(handler, http.MethodGet, "/livedata")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"id":"INIT1"`)
+		assert.Equal(t, http.StatusNoContent, request(handler, http.MethodDelete, "/livedata/fixtures/INIT1").Code)
+		assert.Nil(t, server.findFixture("INIT1"))
+	})
+
+	t.Run("when two servers are initialized it should keep them independent", func(t *testing.T) {
+		// Arrange
+		firstProvider := data.NewMemoryProvider(data.Fixture{Id: "INIT1", Teams: []data.FixtureTeam{{Id: "TE1"}}})
+		secondProvider := data.NewMemoryProvider(data.Fixture{Id: "INIT2", Teams: []data.FixtureTeam{{Id: "TE1"}}})
+		firstBus := external.NewEventBus(external.DefaultEventBusQueueSize)
+		first, firstHandler, _ := InitLiveServer(context.Background(), external.NewMemoryPublisher(), firstBus, firstProvider, 0, testAdminToken)
+		second, secondHandler, _ := InitLiveServer(context.Background(), external.NewMemoryPublisher(), external.NewEventBus(external.DefaultEventBusQueueSize), secondProvider, 0, testAdminToken)
+
+		// Act
+		firstBus.PublishScoreUpdate(&testScoreUpdate{fixtureId: "INIT1", teamId: "TE1", score: 3})
+		firstBus.Close()
+
+		// Assert
+		assert.Equal(t, 3, first.findFixtureTeam("INIT1", "TE1").Score)
+		assert.Nil(t, second.findFixture("INIT1"))
+		assert.NotContains(t, request(secondHandler, http.MethodGet, "/livedata").Body.String(), "INIT1")
+		assert.NotContains(t, request(firstHandler, http.MethodGet, "/livedata").Body.String(), "INIT2")
+	})
+
+	t.Run("when the server is closed it should stop receiving live updates", func(t *testing.T) {
+		// Arrange
+		bus := external.NewEventBus(external.DefaultEventBusQueueSize)
+		dataProvider := data.NewMemoryProvider(data.Fixture{Id: "INIT1", Teams: []data.FixtureTeam{{Id: "TE1"}}})
+		server, _, _ := InitLiveServer(context.Background(), external.NewMemoryPublisher(), bus, dataProvider, 0, testAdminToken)
+
+		// Act
+		server.Close()
+		bus.PublishScoreUpdate(&testScoreUpdate{fixtureId: "INIT1", teamId: "TE1", score: 3})
+		bus.Close()
+
+		// Assert
+		assert.Equal(t, 0, server.findFixtureTeam("INIT1", "TE1").Score)
+	})
+
+	t.Run("when fixtures can't be retrieved it should return an error", func(t *testing.T) {
+		// Act
+		server, handler, err := InitLiveServer(context.Background(), external.NewMemoryPublisher(), external.NewEventBus(external.DefaultEventBusQueueSize), failingDataProvider{}, 0, testAdminToken)
+
+		// Assert
+		assert.IsType(t, &FixturesUnavailableError{}, err)
+		assert.Nil(t, server)
+		assert.Nil(t, handler)
+	})
+}
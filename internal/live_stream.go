@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const (
+	liveDataStreamBufferSize = 64
+
+	streamEventSnapshot = "snapshot"
+)
+
+// Fans out viewmodel changes to the connected stream clients.
+// Clients that can't keep up are disconnected instead of blocking the updates.
+type liveDataStream struct {
+	sync.Mutex
+	subscribers map[chan liveDataChange]struct{}
+}
+
+func newLiveDataStream() *liveDataStream {
+	return &liveDataStream{
+		subscribers: make(map[chan liveDataChange]struct{}),
+	}
+}
+
+func (stream *liveDataStream) subscribe() chan liveDataChange {
+	stream.Lock()
+	defer stream.Unlock()
+
+	subscriber := make(chan liveDataChange, liveDataStreamBufferSize)
+	stream.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+func (stream *liveDataStream) unsubscribe(subscriber chan liveDataChange) {
+	stream.Lock()
+	defer stream.Unlock()
+
+	if _, found := stream.subscribers[subscriber]; found {
+		delete(stream.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+func (stream *liveDataStream) broadcast(change liveDataChange) {
+	stream.Lock()
+	defer stream.Unlock()
+
+	for subscriber := range stream.subscribers {
+		select {
+		case subscriber <- change:
+		default:
+			// Slow consumer, drop it so it can reconnect using Last-Event-ID
+			delete(stream.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+func (server *LiveDataServer) handleLiveDataStreamRequest(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Print("@handleLiveDataStreamRequest -> streaming not supported")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before reading the change log, so no change is missed between the snapshot/replay and
+	// the stream (changes are logged before they're broadcast). Changes the snapshot/replay already
+	// covers are skipped, the snapshot can also include later ones, which are then streamed again.
+	subscriber := server.stream.subscribe()
+	changeLog := server.changes.load()
+	replay, resumed := changesSinceEventId(changeLog, r.Header.Get("Last-Event-ID"))
+	snapshotVersion := changeLog.version
+	var fixtures []*fixture
+	if !resumed {
+		fixtures = server.store.list()
+	}
+
+	var snapshot []byte
+	var err error
+	if !resumed {
+		snapshot, err = json.Marshal(fixtures)
+	}
+
+	defer server.stream.unsubscribe(subscriber)
+
+	if err != nil {
+		log.Print(fmt.Sprintf("@handleLiveDataStreamRequest -> error marshalling fixtures: %s", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	if resumed {
+		for _, change := range replay {
+			if err := writeStreamChange(w, change); err != nil {
+				log.Print(fmt.Sprintf("@handleLiveDataStreamRequest -> error writing change: %s", err.Error()))
+				return
+			}
+		}
+	} else if err := writeStreamEvent(w, snapshotVersion, streamEventSnapshot, snapshot); err != nil {
+		log.Print(fmt.Sprintf("@handleLiveDataStreamRequest -> error writing snapshot: %s", err.Error()))
+		return
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case change, ok := <-subscriber:
+			if !ok {
+				// Disconnected for being too slow
+				return
+			}
+			if change.Version <= snapshotVersion {
+				continue
+			}
+			if err := writeStreamChange(w, change); err != nil {
+				log.Print(fmt.Sprintf("@handleLiveDataStreamRequest -> error writing change: %s", err.Error()))
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Returns the changes to replay for a resuming client, or false if a full snapshot is needed instead
+func changesSinceEventId(changeLog *changeLogSnapshot, lastEventId string) ([]liveDataChange, bool) {
+	if lastEventId == "" {
+		return nil, false
+	}
+
+	version, err := strconv.ParseUint(lastEventId, 10, 64)
+	if err != nil || version > changeLog.version {
+		return nil, false
+	}
+
+	return changeLog.since(version)
+}
+
+func writeStreamChange(w io.Writer, change liveDataChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return writeStreamEvent(w, change.Version, change.Type, data)
+}
+
+func writeStreamEvent(w io.Writer, id uint64, event string, data []byte) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+	return err
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/stretchr/testify/assert"
+)
+
+type streamEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+func readStreamEvent(reader *bufio.Reader) (streamEvent, error) {
+	event := streamEvent{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return event, err
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			return event, nil
+		}
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestLiveDataStream(t *testing.T) {
+
+	setup := func() *LiveDataServer {
+		viewModel := &ViewModel{
+			fixture{
+				Id: "fixture-id-1",
+				Teams: []fixtureTeam{
+					{Id: "team-id-1"},
+					{Id: "team-id-2"},
+				},
+			},
+		}
+		return newLiveDataServer(viewModel, external.NewMemoryPublisher())
+	}
+
+	connect := func(t *testing.T, server *LiveDataServer, lastEventId string) (*bufio.Reader, context.CancelFunc) {
+		httpServer := httptest.NewServer(http.HandlerFunc(server.handleLiveDataStreamRequest))
+		ctx, cancel := context.WithCancel(context.Background())
+
+		request, _ := http.NewRequest(http.MethodGet, httpServer.URL, nil)
+		request = request.WithContext(ctx)
+		if lastEventId != "" {
+			request.Header.Set("Last-Event-ID", lastEventId)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+		return bufio.NewReader(response.Body), func() {
+			cancel()
+			response.Body.Close()
+			httpServer.Close()
+		}
+	}
+
+	t.Run("when a client connects it should receive a snapshot", func(t *testing.T) {
+		// Arrange
+		server := setup()
+
+		// Act
+		reader, closeStream := connect(t, server, "")
+		defer closeStream()
+		event, err := readStreamEvent(reader)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "0", event.id)
+		assert.Equal(t, streamEventSnapshot, event.event)
+		assert.Contains(t, event.data, `"id":"fixture-id-1"`)
+	})
+
+	t.Run("when the viewmodel changes it should stream the change", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		reader, closeStream := connect(t, server, "")
+		defer closeStream()
+		_, _ = readStreamEvent(reader)
+
+		// Act
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 3)
+		server.updateWinnerAndPublish("fixture-id-1", "team-id-1")
+		scoreEvent, _ := readStreamEvent(reader)
+		winnerEvent, _ := readStreamEvent(reader)
+
+		// Assert
+		assert.Equal(t, "1", scoreEvent.id)
+		assert.Equal(t, changeTypeScore, scoreEvent.event)
+		assert.Equal(t, `{"version":1,"type":"score","fixtureId":"fixture-id-1","teamId":"team-id-1","score":3,"status":"live"}`, scoreEvent.data)
+		assert.Equal(t, "2", winnerEvent.id)
+		assert.Equal(t, changeTypeWinner, winnerEvent.event)
+		assert.Equal(t, `{"version":2,"type":"winner","fixtureId":"fixture-id-1","teamId":"team-id-1","status":"finished"}`, winnerEvent.data)
+	})
+
+	t.Run("when a client resumes with Last-Event-ID it should replay the missed changes", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 1)
+		server.updateScoreAndPublish("fixture-id-1", "team-id-2", 1)
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 2)
+
+		// Act
+		reader, closeStream := connect(t, server, "1")
+		defer closeStream()
+		firstEvent, _ := readStreamEvent(reader)
+		secondEvent, _ := readStreamEvent(reader)
+
+		// Assert
+		assert.Equal(t, "2", firstEvent.id)
+		assert.Equal(t, changeTypeScore, firstEvent.event)
+		assert.Equal(t, "3", secondEvent.id)
+	})
+
+	t.Run("when a client resumes from an evicted version it should receive a snapshot", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		server.changes = newChangeLog(1)
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 1)
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 2)
+		server.updateScoreAndPublish("fixture-id-1", "team-id-1", 3)
+
+		// Act
+		reader, closeStream := connect(t, server, "1")
+		defer closeStream()
+		event, _ := readStreamEvent(reader)
+
+		// Assert
+		assert.Equal(t, "3", event.id)
+		assert.Equal(t, streamEventSnapshot, event.event)
+	})
+}
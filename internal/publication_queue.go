@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+)
+
+// Changes waiting to be published before the pending ones are replaced by a full snapshot
+const publicationQueueCapacity = 1024
+
+// What is published for a viewmodel change: a patch along with the changed fixture's state (for
+// stores holding every fixture), or a full snapshot
+type publication struct {
+	version    uint64
+	operations []patchOperation
+	fixtureId  string
+	// Nil if the fixture was removed
+	fixture *fixture
+
+	fullSnapshot bool
+	fixtures     []*fixture
+}
+
+// Publishes the viewmodel changes in version order from a background goroutine, so slow sinks
+// (redis, files) never stall the live updates. Fixtures are immutable, so publications hold them
+// as they were when the change was recorded.
+// When the sinks fall publicationQueueCapacity changes behind, the pending publications are replaced
+// by a full snapshot, which patch consumers resync from.
+type publicationQueue struct {
+	sync.Mutex
+	// Signalled when publications are queued, published or the queue is closed
+	changed   *sync.Cond
+	publisher external.Publisher
+	store     *ViewModelStore
+	pending   []publication
+	// Set while a publication is being published, outside of the lock
+	publishing bool
+	closed     bool
+	workerDone sync.WaitGroup
+}
+
+func newPublicationQueue(publisher external.Publisher, store *ViewModelStore) *publicationQueue {
+	queue := &publicationQueue{
+		publisher: publisher,
+		store:     store,
+	}
+	queue.changed = sync.NewCond(&queue.Mutex)
+
+	queue.workerDone.Add(1)
+	go queue.publishQueued()
+
+	return queue
+}
+
+// Queue a publication, never blocks. Must be called holding the server's changeLock, so publications
+// are queued in version order and a full snapshot taken on overflow matches the last version.
+func (queue *publicationQueue) enqueue(publication publication) {
+	queue.Lock()
+	defer queue.Unlock()
+
+	if queue.closed {
+		log.Print(fmt.Sprintf("@enqueue -> publication of version %d dropped, the queue is closed", publication.version))
+		return
+	}
+
+	if len(queue.pending) >= publicationQueueCapacity && !publication.fullSnapshot {
+		log.Print(fmt.Sprintf("@enqueue -> %d publications pending, publishing a snapshot of version %d instead", len(queue.pending), publication.version))
+		publication = queue.snapshot(publication.version)
+	}
+	if publication.fullSnapshot {
+		// A snapshot supersedes every pending publication
+		queue.pending = queue.pending[:0]
+	}
+
+	queue.pending = append(queue.pending, publication)
+	queue.changed.Broadcast()
+}
+
+// Full snapshot of the store's current fixtures, published at the given version
+func (queue *publicationQueue) snapshot(version uint64) publication {
+	return publication{version: version, fullSnapshot: true, fixtures: queue.store.list()}
+}
+
+func (queue *publicationQueue) publishQueued() {
+	defer queue.workerDone.Done()
+
+	for {
+		queue.Lock()
+		for len(queue.pending) == 0 && !queue.closed {
+			queue.changed.Wait()
+		}
+		if len(queue.pending) == 0 {
+			queue.Unlock()
+			return
+		}
+		next := queue.pending[0]
+		queue.pending = queue.pending[1:]
+		queue.publishing = true
+		queue.Unlock()
+
+		queue.publish(next)
+
+		queue.Lock()
+		queue.publishing = false
+		queue.changed.Broadcast()
+		queue.Unlock()
+	}
+}
+
+func (queue *publicationQueue) publish(publication publication) {
+	if publication.fullSnapshot {
+		err := queue.publisher.PublishViewModel(newViewModelSnapshot(publication.version, publication.fixtures))
+		if err != nil {
+			log.Print(fmt.Sprintf("@publish -> error publishing viewmodel: %s", err.Error()))
+		}
+		return
+	}
+
+	patch := &viewModelPatch{
+		Version:    publication.version,
+		Operations: publication.operations,
+	}
+	patch.publish(queue.publisher)
+
+	// Keep stores holding every fixture (e.g. redis) up to date
+	if fixturePublisher, ok := queue.publisher.(external.FixturePublisher); ok {
+		var fixtureState interface{}
+		if publication.fixture != nil {
+			fixtureState = publication.fixture
+		}
+		err := fixturePublisher.PublishFixture(publication.version, publication.fixtureId, fixtureState)
+		if err != nil {
+			log.Print(fmt.Sprintf("@publish -> error publishing fixture: %s", err.Error()))
+		}
+	}
+}
+
+// Wait for the queued publications to be published
+func (queue *publicationQueue) flush() {
+	queue.Lock()
+	defer queue.Unlock()
+
+	for len(queue.pending) > 0 || queue.publishing {
+		queue.changed.Wait()
+	}
+}
+
+// Publish the queued publications and stop, later ones are dropped
+func (queue *publicationQueue) close() {
+	queue.Lock()
+	queue.closed = true
+	queue.changed.Broadcast()
+	queue.Unlock()
+
+	queue.workerDone.Wait()
+}
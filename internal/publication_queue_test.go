@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/stretchr/testify/assert"
+)
+
+// Publisher blocking every publication until it's released
+type blockingPublisher struct {
+	*external.MemoryPublisher
+	release chan struct{}
+}
+
+func newBlockingPublisher() *blockingPublisher {
+	return &blockingPublisher{MemoryPublisher: external.NewMemoryPublisher(), release: make(chan struct{})}
+}
+
+func (p *blockingPublisher) PublishViewModel(viewModel interface{}) error {
+	<-p.release
+	return p.MemoryPublisher.PublishViewModel(viewModel)
+}
+
+func (p *blockingPublisher) PublishViewModelPatch(patch interface{}) error {
+	<-p.release
+	return p.MemoryPublisher.PublishViewModelPatch(patch)
+}
+
+func TestPublicationQueue(t *testing.T) {
+
+	setup := func() (*LiveDataServer, *blockingPublisher) {
+		viewModel := &ViewModel{fixture{Id: "F1", Teams: []fixtureTeam{{Id: "TE1"}, {Id: "TE2"}}}}
+		publisher := newBlockingPublisher()
+		return newLiveDataServer(viewModel, publisher), publisher
+	}
+
+	t.Run("when the publisher is slow it should still record changes and serve them", func(t *testing.T) {
+		// Arrange
+		server, publisher := setup()
+
+		// Act
+		_ = server.updateScoreAndPublish("F1", "TE1", 1)
+		_ = server.updateScoreAndPublish("F1", "TE2", 2)
+		delta, ok := server.changedFixturesSince(0)
+		close(publisher.release)
+		server.publications.flush()
+
+		// Assert
+		assert.True(t, ok)
+		assert.Equal(t, uint64(2), delta.Version)
+		messages := publisher.Messages()
+		assert.Equal(t, 2, len(messages))
+		assert.Contains(t, string(messages[0].Data), `"version":1`)
+		assert.Contains(t, string(messages[1].Data), `"version":2`)
+	})
+
+	t.Run("when too many publications are pending it should replace them by a snapshot", func(t *testing.T) {
+		// Arrange
+		server, publisher := setup()
+
+		// Act
+		for version := uint64(1); version <= publicationQueueCapacity+2; version++ {
+			server.publications.enqueue(publication{version: version, fixtureId: "F1"})
+		}
+		close(publisher.release)
+		server.publications.flush()
+
+		// Assert
+		// The first publication can be taken before the queue is full, then the snapshot is of the last version
+		messages := publisher.Messages()
+		assert.True(t, len(messages) <= 3)
+		snapshots := 0
+		for _, message := range messages {
+			if message.Type == external.PublishedMessageTypeViewModel {
+				snapshots++
+			}
+		}
+		assert.Equal(t, 1, snapshots)
+		assert.Contains(t, string(messages[len(messages)-1].Data), `"version":1026`)
+	})
+
+	t.Run("when the server is closed it should publish the queued changes", func(t *testing.T) {
+		// Arrange
+		server, publisher := setup()
+		_ = server.updateScoreAndPublish("F1", "TE1", 1)
+
+		// Act
+		close(publisher.release)
+		server.Close()
+		_ = server.updateScoreAndPublish("F1", "TE1", 2)
+
+		// Assert
+		assert.Equal(t, 1, len(publisher.Messages()))
+	})
+}
+
+// Minimal RFC 6902 applier (add, replace and remove), applying an operation to a decoded JSON document
+func applyJSONPatchOperation(node interface{}, tokens []string, op string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	token := strings.NewReplacer("~1", "/", "~0", "~").Replace(tokens[0])
+
+	switch container := node.(type) {
+	case map[string]interface{}:
+		child, found := container[token]
+		if !found && (len(tokens) > 1 || op != "add") {
+			return nil, fmt.Errorf("member '%s' not found", token)
+		}
+		switch {
+		case len(tokens) > 1:
+			updated, err := applyJSONPatchOperation(child, tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			container[token] = updated
+		case op == "remove":
+			delete(container, token)
+		default:
+			container[token] = value
+		}
+		return container, nil
+	case []interface{}:
+		index := len(container)
+		if token != "-" {
+			var err error
+			if index, err = strconv.Atoi(token); err != nil {
+				return nil, err
+			}
+		}
+		if index < 0 || index > len(container) || (index == len(container) && (len(tokens) > 1 || op != "add")) {
+			return nil, fmt.Errorf("index '%s' out of bounds", token)
+		}
+		switch {
+		case len(tokens) > 1:
+			updated, err := applyJSONPatchOperation(container[index], tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			container[index] = updated
+		case op == "add":
+			container = append(container[:index], append([]interface{}{value}, container[index:]...)...)
+		case op == "remove":
+			container = append(container[:index], container[index+1:]...)
+		default:
+			container[index] = value
+		}
+		return container, nil
+	default:
+		return nil, fmt.Errorf("'%s' isn't in a container", token)
+	}
+}
+
+func TestViewModelPatches(t *testing.T) {
+	t.Run("when the published patches are applied to the published snapshot it should give the current viewmodel", func(t *testing.T) {
+		// Arrange
+		viewModel := &ViewModel{
+			{Id: "F1", Teams: []fixtureTeam{{Id: "TE1"}, {Id: "TE3"}}},
+			{Id: "F/2", Teams: []fixtureTeam{{Id: "TE4"}}},
+		}
+		publisher := external.NewMemoryPublisher()
+		server := newLiveDataServer(viewModel, publisher)
+		server.publishSnapshot()
+
+		// Act
+		_ = server.updateScoreAndPublish("F1", "TE1", 3)
+		_ = server.addTeam("F1", fixtureTeam{Id: "TE2"})
+		_ = server.updateFixtureDetails("F1", fixtureDetails{Title: "Final", Tournament: fixtureTournament{Id: "TO1"}})
+		_ = server.updateWinnerAndPublish("F1", "TE1")
+		_ = server.removeTeam("F/2", "TE4")
+		_ = server.addFixture(fixture{Id: "F3", Teams: []fixtureTeam{{Id: "TE5"}}})
+		_ = server.removeFixture("F/2")
+		server.publications.flush()
+
+		// Assert
+		messages := publisher.Messages()
+		assert.Equal(t, external.PublishedMessageTypeViewModel, messages[0].Type)
+		var document interface{}
+		assert.NoError(t, json.Unmarshal(messages[0].Data, &document))
+		for _, message := range messages[1:] {
+			assert.Equal(t, external.PublishedMessageTypeViewModelPatch, message.Type)
+			var patch struct {
+				Version    uint64 `json:"version"`
+				Operations []struct {
+					Op    string      `json:"op"`
+					Path  string      `json:"path"`
+					Value interface{} `json:"value"`
+				} `json:"operations"`
+			}
+			assert.NoError(t, json.Unmarshal(message.Data, &patch))
+			for _, operation := range patch.Operations {
+				var err error
+				document, err = applyJSONPatchOperation(document, strings.Split(operation.Path, "/")[1:], operation.Op, operation.Value)
+				assert.NoError(t, err, operation.Path)
+			}
+			document.(map[string]interface{})["version"] = patch.Version
+		}
+		patched, _ := json.Marshal(document)
+		current, _ := json.Marshal(newViewModelSnapshot(server.currentVersion(), server.store.list()))
+		assert.Equal(t, uint64(7), server.currentVersion())
+		assert.JSONEq(t, string(current), string(patched))
+	})
+}
@@ -0,0 +1,326 @@
+package internal
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const (
+	liveDataTeamsPath = "/livedata/teams"
+
+	teamResultWin  = "win"
+	teamResultDraw = "draw"
+	teamResultLoss = "loss"
+)
+
+// Statistics of a team, over every fixture it scored or got a result in
+type teamStats struct {
+	TeamId string `json:"teamId"`
+	// Decided fixtures only
+	Played  int     `json:"played"`
+	Wins    int     `json:"wins"`
+	Draws   int     `json:"draws"`
+	Losses  int     `json:"losses"`
+	WinRate float64 `json:"winRate"`
+	// Every fixture, including the ones being played. Points of every map for fixtures with match rules.
+	PointsFor     int `json:"pointsFor"`
+	PointsAgainst int `json:"pointsAgainst"`
+	// Results in the order the fixtures were decided
+	CurrentStreak    teamStreak `json:"currentStreak"`
+	LongestWinStreak int        `json:"longestWinStreak"`
+	// Against every opponent the team played one-on-one, by opponent id
+	HeadToHead []headToHeadRecord `json:"headToHead"`
+}
+
+type teamStreak struct {
+	Result string `json:"result,omitempty"`
+	Length int    `json:"length"`
+}
+
+type headToHeadRecord struct {
+	TeamId         string   `json:"teamId"`
+	OpponentTeamId string   `json:"opponentTeamId"`
+	FixtureIds     []string `json:"fixtureIds"`
+	Played         int      `json:"played"`
+	Wins           int      `json:"wins"`
+	Draws          int      `json:"draws"`
+	Losses         int      `json:"losses"`
+	PointsFor      int      `json:"pointsFor"`
+	PointsAgainst  int      `json:"pointsAgainst"`
+}
+
+// Keeps the last state of the fixtures teams scored or got a result in, fed with the viewmodel's
+// score, winner, placement, team and fixture changes in version order. Removed fixtures, and
+// fixtures a team was removed from, no longer count in the team's stats.
+type teamStatsRecorder struct {
+	sync.Mutex
+	fixtures map[string]*recordedFixture
+	// Recorded fixture ids, by team id
+	teamFixtureIds map[string]map[string]struct{}
+}
+
+type recordedFixture struct {
+	fixtureId string
+	teamIds   []string
+	points    map[string]int
+	result    fixtureResult
+	decided   bool
+	// Version of the change that decided the fixture, which orders the results of a team's streaks
+	decidedVersion uint64
+}
+
+func newTeamStatsRecorder() *teamStatsRecorder {
+	return &teamStatsRecorder{
+		fixtures:       make(map[string]*recordedFixture),
+		teamFixtureIds: make(map[string]map[string]struct{}),
+	}
+}
+
+// Record the fixture's state after one of its live updates or team changes was applied, or drop it
+// once it's removed (other changes are ignored)
+func (recorder *teamStatsRecorder) record(change liveDataChange, fixture *fixture) {
+	switch change.Type {
+	case changeTypeFixtureRemoved:
+		recorder.removeFixture(change.FixtureId)
+	case changeTypeScore, changeTypeWinner, changeTypePlacement, changeTypeFixtureAdded, changeTypeTeamAdded, changeTypeTeamRemoved:
+		if fixture != nil {
+			recorder.recordFixture(fixture, change.Version)
+		}
+	}
+}
+
+func (recorder *teamStatsRecorder) removeFixture(fixtureId string) {
+	recorder.Lock()
+	defer recorder.Unlock()
+
+	previous := recorder.fixtures[fixtureId]
+	if previous == nil {
+		return
+	}
+	for _, teamId := range previous.teamIds {
+		removeFromIndex(recorder.teamFixtureIds, teamId, fixtureId)
+	}
+	delete(recorder.fixtures, fixtureId)
+}
+
+// Record the fixture's teams, points and result. A result keeps the version it was first decided at,
+// so a corrected winner doesn't move it in the teams' streaks.
+func (recorder *teamStatsRecorder) recordFixture(fixture *fixture, version uint64) {
+	recorder.Lock()
+	defer recorder.Unlock()
+
+	previous := recorder.fixtures[fixture.Id]
+	recorded := &recordedFixture{
+		fixtureId: fixture.Id,
+		teamIds:   make([]string, 0, len(fixture.Teams)),
+		points:    fixture.teamPoints(),
+	}
+	recorded.result, recorded.decided = fixture.result()
+	if recorded.decided {
+		recorded.decidedVersion = version
+		if previous != nil && previous.decided {
+			recorded.decidedVersion = previous.decidedVersion
+		}
+	}
+
+	if previous != nil {
+		for _, teamId := range previous.teamIds {
+			removeFromIndex(recorder.teamFixtureIds, teamId, fixture.Id)
+		}
+	}
+	for _, team := range fixture.Teams {
+		recorded.teamIds = append(recorded.teamIds, team.Id)
+		addToIndex(recorder.teamFixtureIds, team.Id, fixture.Id)
+	}
+	recorder.fixtures[fixture.Id] = recorded
+}
+
+// Points scored by each team, by team id: the points of every map for fixtures with match rules
+// (the team scores only hold the map being played), the placement and kill points of the points table
+// for free-for-all fixtures (the team scores are kills), the team scores otherwise
+func (fixture *fixture) teamPoints() map[string]int {
+	points := make(map[string]int, len(fixture.Teams))
+	if fixture.Standings != nil {
+		for _, row := range fixture.Standings.Rows {
+			points[row.TeamId] = row.Points
+		}
+		return points
+	}
+	for _, team := range fixture.Teams {
+		if fixture.Series == nil {
+			points[team.Id] = team.Score
+			continue
+		}
+		for _, fixtureMap := range fixture.Series.Maps {
+			points[team.Id] += fixtureMap.Scores[team.Id]
+		}
+	}
+	return points
+}
+
+// The team's result in a decided fixture
+func (recorded *recordedFixture) teamResult(teamId string) string {
+	switch {
+	case recorded.result.Draw:
+		return teamResultDraw
+	case recorded.result.WinningTeamId == teamId:
+		return teamResultWin
+	default:
+		return teamResultLoss
+	}
+}
+
+// The team's recorded fixtures, the decided ones in the order they were decided, then the others
+// (fixtures decided at the same version, e.g. the ones loaded at startup, are sorted by id)
+func (recorder *teamStatsRecorder) teamFixtures(teamId string) []*recordedFixture {
+	fixtures := make([]*recordedFixture, 0, len(recorder.teamFixtureIds[teamId]))
+	for fixtureId := range recorder.teamFixtureIds[teamId] {
+		fixtures = append(fixtures, recorder.fixtures[fixtureId])
+	}
+	sort.Slice(fixtures, func(i, j int) bool {
+		switch {
+		case fixtures[i].decided != fixtures[j].decided:
+			return fixtures[i].decided
+		case fixtures[i].decidedVersion != fixtures[j].decidedVersion:
+			return fixtures[i].decidedVersion < fixtures[j].decidedVersion
+		default:
+			return fixtures[i].fixtureId < fixtures[j].fixtureId
+		}
+	})
+	return fixtures
+}
+
+// Returns the team's stats, false if the team never scored nor got a result
+func (recorder *teamStatsRecorder) teamStats(teamId string) (teamStats, bool) {
+	recorder.Lock()
+	defer recorder.Unlock()
+
+	fixtures := recorder.teamFixtures(teamId)
+	if len(fixtures) == 0 {
+		return teamStats{}, false
+	}
+
+	stats := teamStats{TeamId: teamId, HeadToHead: make([]headToHeadRecord, 0)}
+	headToHead := make(map[string]*headToHeadRecord)
+	winStreak := 0
+	for _, recorded := range fixtures {
+		stats.PointsFor += recorded.points[teamId]
+		for _, opponentId := range recorded.teamIds {
+			if opponentId != teamId {
+				stats.PointsAgainst += recorded.points[opponentId]
+			}
+		}
+
+		// Head-to-head records only take one-on-one fixtures
+		var record *headToHeadRecord
+		if len(recorded.teamIds) == 2 {
+			opponentId := recorded.teamIds[0]
+			if opponentId == teamId {
+				opponentId = recorded.teamIds[1]
+			}
+			if record = headToHead[opponentId]; record == nil {
+				record = &headToHeadRecord{TeamId: teamId, OpponentTeamId: opponentId, FixtureIds: make([]string, 0)}
+				headToHead[opponentId] = record
+			}
+			record.FixtureIds = append(record.FixtureIds, recorded.fixtureId)
+			record.PointsFor += recorded.points[teamId]
+			record.PointsAgainst += recorded.points[opponentId]
+		}
+
+		if !recorded.decided {
+			continue
+		}
+		result := recorded.teamResult(teamId)
+		stats.Played++
+		if record != nil {
+			record.Played++
+		}
+		switch result {
+		case teamResultWin:
+			stats.Wins++
+			if record != nil {
+				record.Wins++
+			}
+			winStreak++
+			if winStreak > stats.LongestWinStreak {
+				stats.LongestWinStreak = winStreak
+			}
+		case teamResultDraw:
+			stats.Draws++
+			if record != nil {
+				record.Draws++
+			}
+			winStreak = 0
+		default:
+			stats.Losses++
+			if record != nil {
+				record.Losses++
+			}
+			winStreak = 0
+		}
+		if result == stats.CurrentStreak.Result {
+			stats.CurrentStreak.Length++
+		} else {
+			stats.CurrentStreak = teamStreak{Result: result, Length: 1}
+		}
+	}
+	if stats.Played > 0 {
+		stats.WinRate = float64(stats.Wins) / float64(stats.Played)
+	}
+
+	for _, record := range headToHead {
+		stats.HeadToHead = append(stats.HeadToHead, *record)
+	}
+	sort.Slice(stats.HeadToHead, func(i, j int) bool {
+		return stats.HeadToHead[i].OpponentTeamId < stats.HeadToHead[j].OpponentTeamId
+	})
+
+	return stats, true
+}
+
+// Returns the team's record against the opponent, empty if they never played one-on-one
+func (recorder *teamStatsRecorder) headToHead(teamId string, opponentTeamId string) headToHeadRecord {
+	stats, _ := recorder.teamStats(teamId)
+	for _, record := range stats.HeadToHead {
+		if record.OpponentTeamId == opponentTeamId {
+			return record
+		}
+	}
+	return headToHeadRecord{TeamId: teamId, OpponentTeamId: opponentTeamId, FixtureIds: make([]string, 0)}
+}
+
+// Teams endpoints:
+//
+//	GET /livedata/teams/{teamId}/stats                        win rate, points, streaks and head-to-head records
+//	GET /livedata/teams/{teamId}/head-to-head/{opponentId}    record against a single opponent
+//
+// Teams that are in a fixture but haven't scored yet have empty stats, unknown teams are not found.
+func (server *LiveDataServer) handleTeamsRequest(w http.ResponseWriter, r *http.Request) {
+	segments, err := pathSegments(r.URL, liveDataTeamsPath)
+	if err != nil || len(segments) < 2 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	teamId := segments[0]
+	stats, found := server.stats.teamStats(teamId)
+	if !found && len(server.store.fixturesByTeam(teamId)) == 0 {
+		writeFixturesResponse(w, http.StatusOK, errTeamNotFound)
+		return
+	}
+
+	switch {
+	case len(segments) == 2 && segments[1] == "stats" && r.Method == http.MethodGet:
+		if !found {
+			stats = teamStats{TeamId: teamId, HeadToHead: make([]headToHeadRecord, 0)}
+		}
+		writeJsonResponse(w, stats)
+	case len(segments) == 3 && segments[1] == "head-to-head" && r.Method == http.MethodGet:
+		writeJsonResponse(w, server.stats.headToHead(teamId, segments[2]))
+	case (len(segments) == 2 && segments[1] == "stats") || (len(segments) == 3 && segments[1] == "head-to-head"):
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeamStats(t *testing.T) {
+
+	setup := func() *LiveDataServer {
+		viewModel := &ViewModel{
+			{Id: "F1", Teams: []fixtureTeam{{Id: "TE1"}, {Id: "TE2"}}, WinningTeamId: "TE2"},
+			{Id: "F2", Teams: []fixtureTeam{{Id: "TE1"}, {Id: "TE3"}}},
+			{Id: "F3", Teams: []fixtureTeam{{Id: "TE1"}, {Id: "TE2"}}},
+			{Id: "F4", Teams: []fixtureTeam{{Id: "TE1"}, {Id: "TE3"}},
+				Series: &fixtureSeries{Rules: external.MatchRules{BestOf: 2, MapScoreLimit: 2, AllowDraws: true}}},
+			{Id: "F5", Teams: []fixtureTeam{{Id: "TE4"}}},
+			{Id: "F6", Teams: []fixtureTeam{{Id: "TE5"}, {Id: "TE6"}, {Id: "TE7"}},
+				Standings: &fixtureStandings{PointsTable: external.PointsTable{PlacementPoints: []int{10, 6, 3}, KillPoints: 1}}},
+		}
+		return newLiveDataServer(viewModel, external.NewMemoryPublisher())
+	}
+
+	t.Run("when fixtures are won it should count the results, points and streaks in the order they were decided", func(t *testing.T) {
+		// Arrange
+		server := setup()
+
+		// Act
+		_ = server.updateScoreAndPublish("F3", "TE1", 10)
+		_ = server.updateScoreAndPublish("F3", "TE2", 7)
+		_ = server.updateScoreAndPublish("F2", "TE1", 10)
+		_ = server.updateScoreAndPublish("F2", "TE3", 3)
+		_ = server.updateWinnerAndPublish("F3", "TE1")
+		_ = server.updateWinnerAndPublish("F2", "TE1")
+
+		// Assert
+		stats, found := server.stats.teamStats("TE1")
+		assert.True(t, found)
+		assert.Equal(t, 3, stats.Played)
+		assert.Equal(t, 2, stats.Wins)
+		assert.Equal(t, 1, stats.Losses)
+		assert.InDelta(t, 0.667, stats.WinRate, 0.001)
+		assert.Equal(t, 20, stats.PointsFor)
+		assert.Equal(t, 10, stats.PointsAgainst)
+		assert.Equal(t, teamStreak{Result: teamResultWin, Length: 2}, stats.CurrentStreak)
+		assert.Equal(t, 2, stats.LongestWinStreak)
+		assert.Equal(t, headToHeadRecord{
+			TeamId: "TE1", OpponentTeamId: "TE2", FixtureIds: []string{"F1", "F3"},
+			Played: 2, Wins: 1, Losses: 1, PointsFor: 10, PointsAgainst: 7,
+		}, server.stats.headToHead("TE1", "TE2"))
+	})
+
+	t.Run("when a fixture with match rules is played it should count the points of every map and its draw", func(t *testing.T) {
+		// Arrange
+		server := setup()
+
+		// Act
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "F4", 1, "TE1", 2)
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "F4", 2, "TE1", 1)
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "F4", 2, "TE3", 2)
+
+		// Assert
+		stats, _ := server.stats.teamStats("TE3")
+		assert.Equal(t, 1, stats.Draws)
+		assert.Equal(t, 2, stats.PointsFor)
+		assert.Equal(t, 3, stats.PointsAgainst)
+		assert.Equal(t, teamStreak{Result: teamResultDraw, Length: 1}, stats.CurrentStreak)
+	})
+
+	t.Run("when a winner is corrected it should keep its place in the streaks", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.updateWinnerAndPublish("F2", "TE1")
+		_ = server.updateWinnerAndPublish("F3", "TE2")
+
+		// Act
+		_ = server.applyWinnerUpdate(external.EventEnvelope{Correction: true}, "F2", "TE3")
+
+		// Assert
+		stats, _ := server.stats.teamStats("TE1")
+		assert.Equal(t, teamStreak{Result: teamResultLoss, Length: 3}, stats.CurrentStreak)
+		assert.Equal(t, 0, stats.LongestWinStreak)
+	})
+
+	t.Run("when a free-for-all fixture is played it should count the points of its points table", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.updateScoreAndPublish("F6", "TE5", 4)
+		_ = server.updateScoreAndPublish("F6", "TE6", 1)
+
+		// Act
+		_ = server.applyPlacementUpdate(external.EventEnvelope{}, "F6", "TE7", 3)
+		_ = server.applyPlacementUpdate(external.EventEnvelope{}, "F6", "TE6", 2)
+		_ = server.applyPlacementUpdate(external.EventEnvelope{}, "F6", "TE5", 1)
+
+		// Assert
+		stats, _ := server.stats.teamStats("TE5")
+		assert.Equal(t, 1, stats.Wins)
+		assert.Equal(t, 14, stats.PointsFor)
+		assert.Equal(t, 10, stats.PointsAgainst)
+	})
+
+	t.Run("when a team is removed from a fixture it should drop the fixture from the team's stats", func(t *testing.T) {
+		// Arrange
+		server := setup()
+
+		// Act
+		err := server.removeTeam("F3", "TE2")
+
+		// Assert
+		assert.NoError(t, err)
+		stats, _ := server.stats.teamStats("TE2")
+		assert.Equal(t, 1, stats.Played)
+		assert.Equal(t, []string{"F1"}, server.stats.headToHead("TE1", "TE2").FixtureIds)
+	})
+
+	t.Run("when a fixture is removed it should drop it from its teams' stats", func(t *testing.T) {
+		// Arrange
+		server := setup()
+
+		// Act
+		err := server.removeFixture("F1")
+
+		// Assert
+		assert.NoError(t, err)
+		stats, _ := server.stats.teamStats("TE2")
+		assert.Equal(t, 0, stats.Played)
+		assert.Equal(t, 0, stats.Wins)
+		assert.Equal(t, []string{"F3"}, server.stats.headToHead("TE1", "TE2").FixtureIds)
+	})
+
+	t.Run("when stats are requested it should serve them, or 404 for an unknown team", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.updateWinnerAndPublish("F2", "TE3")
+		get := func(path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			server.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+			return recorder
+		}
+
+		// Act
+		statsRecorder := get("/livedata/teams/TE3/stats")
+		headToHeadRecorder := get("/livedata/teams/TE3/head-to-head/TE1")
+		unknownRecorder := get("/livedata/teams/TE9/stats")
+
+		// Assert
+		assert.Equal(t, http.StatusOK, statsRecorder.Code)
+		assert.JSONEq(t, `{"teamId":"TE3","played":1,"wins":1,"draws":0,"losses":0,"winRate":1,"pointsFor":0,"pointsAgainst":0,
+			"currentStreak":{"result":"win","length":1},"longestWinStreak":1,"headToHead":[
+			{"teamId":"TE3","opponentTeamId":"TE1","fixtureIds":["F2","F4"],"played":1,"wins":1,"draws":0,"losses":0,"pointsFor":0,"pointsAgainst":0}]}`,
+			statsRecorder.Body.String())
+		assert.Equal(t, http.StatusOK, headToHeadRecorder.Code)
+		assert.Contains(t, headToHeadRecorder.Body.String(), `"fixtureIds":["F2","F4"],"played":1,"wins":1`)
+		assert.Equal(t, http.StatusNotFound, unknownRecorder.Code)
+	})
+}
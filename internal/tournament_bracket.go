@@ -0,0 +1,298 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+type bracketFormat string
+
+const (
+	bracketFormatSingleElimination bracketFormat = "single_elimination"
+	bracketFormatDoubleElimination bracketFormat = "double_elimination"
+)
+
+const (
+	bracketWinners    = "winners"
+	bracketLosers     = "losers"
+	bracketGrandFinal = "grand_final"
+
+	bracketGrandFinalMatchId = "GF"
+)
+
+// Elimination bracket of a tournament. Its first-round fixtures are given, the fixtures of the next
+// rounds are created along with the bracket and get their teams as the previous rounds are decided.
+type tournamentBracket struct {
+	TournamentId string        `json:"tournamentId"`
+	Format       bracketFormat `json:"format"`
+	// In round order: the winners bracket, then the losers bracket and the grand final (double elimination)
+	Matches        []bracketMatch `json:"matches"`
+	ChampionTeamId string         `json:"championTeamId,omitempty"`
+}
+
+type bracketMatch struct {
+	// e.g. "W2-1" (winners round 2, match 1), "L1-2" (losers round 1, match 2) or "GF"
+	Id        string `json:"id"`
+	Bracket   string `json:"bracket"`
+	Round     int    `json:"round"`
+	FixtureId string `json:"fixtureId"`
+	// Teams of the match's fixture, filled in when the bracket is read
+	TeamIds       []string `json:"teamIds"`
+	WinningTeamId string   `json:"winningTeamId,omitempty"`
+	// Matches the winner and the loser advance to. The winner of the last match is the champion,
+	// losers without a next match are eliminated.
+	WinnerTo string `json:"winnerTo,omitempty"`
+	LoserTo  string `json:"loserTo,omitempty"`
+
+	// Position of the match in its round
+	number int
+}
+
+// Body of POST /tournaments/{tournamentId}/bracket
+type bracketRequest struct {
+	Format bracketFormat `json:"format"`
+	// Fixtures of the first round, the winners of fixtures 1 and 2 meet in the second round, and so on
+	FixtureIds []string `json:"fixtureIds"`
+}
+
+func bracketMatchId(bracket string, round int, match int) string {
+	if bracket == bracketGrandFinal {
+		return bracketGrandFinalMatchId
+	}
+	return fmt.Sprintf("%s%d-%d", strings.ToUpper(bracket[:1]), round, match)
+}
+
+// Build the bracket's matches. The first round must have a power of 2 fixtures, at least 2 for
+// double elimination, where the losers bracket alternates between rounds of its own winners and
+// rounds where they meet the losers of the next winners round.
+func newTournamentBracket(tournamentId string, format bracketFormat, firstRound []string) (*tournamentBracket, error) {
+	size := len(firstRound)
+	if size == 0 || size&(size-1) != 0 {
+		return nil, errInvalidBracket
+	}
+	if format != bracketFormatSingleElimination && (format != bracketFormatDoubleElimination || size < 2) {
+		return nil, errInvalidBracket
+	}
+	fixtureIds := make(map[string]struct{}, size)
+	for _, fixtureId := range firstRound {
+		if _, found := fixtureIds[fixtureId]; found || fixtureId == "" {
+			return nil, errInvalidBracket
+		}
+		fixtureIds[fixtureId] = struct{}{}
+	}
+
+	bracket := &tournamentBracket{TournamentId: tournamentId, Format: format}
+	newMatch := func(bracketName string, round int, match int) bracketMatch {
+		id := bracketMatchId(bracketName, round, match)
+		return bracketMatch{Id: id, Bracket: bracketName, Round: round, FixtureId: tournamentId + "-" + id, number: match}
+	}
+
+	rounds := 1
+	for matches := size; matches > 1; matches /= 2 {
+		rounds++
+	}
+	doubleElimination := format == bracketFormatDoubleElimination
+
+	for round := 1; round <= rounds; round++ {
+		for i := 1; i <= size>>(round-1); i++ {
+			match := newMatch(bracketWinners, round, i)
+			switch {
+			case round < rounds:
+				match.WinnerTo = bracketMatchId(bracketWinners, round+1, (i+1)/2)
+			case doubleElimination:
+				match.WinnerTo = bracketGrandFinalMatchId
+			}
+			if round == 1 {
+				match.FixtureId = firstRound[i-1]
+			}
+			if doubleElimination {
+				// The first round's losers play each other, the next rounds' losers meet the losers bracket's winners
+				if round == 1 {
+					match.LoserTo = bracketMatchId(bracketLosers, 1, (i+1)/2)
+				} else {
+					match.LoserTo = bracketMatchId(bracketLosers, 2*round-2, i)
+				}
+			}
+			bracket.Matches = append(bracket.Matches, match)
+		}
+	}
+	if !doubleElimination {
+		return bracket, nil
+	}
+
+	losersRounds := 2 * (rounds - 1)
+	for round := 1; round <= losersRounds; round++ {
+		// Odd rounds halve the losers bracket, even rounds take in the losers of the winners bracket
+		matches := size >> ((round + 1) / 2)
+		for i := 1; i <= matches; i++ {
+			match := newMatch(bracketLosers, round, i)
+			switch {
+			case round == losersRounds:
+				match.WinnerTo = bracketGrandFinalMatchId
+			case round%2 == 1:
+				match.WinnerTo = bracketMatchId(bracketLosers, round+1, i)
+			default:
+				match.WinnerTo = bracketMatchId(bracketLosers, round+1, (i+1)/2)
+			}
+			bracket.Matches = append(bracket.Matches, match)
+		}
+	}
+	bracket.Matches = append(bracket.Matches, newMatch(bracketGrandFinal, 1, 1))
+
+	return bracket, nil
+}
+
+func (bracket *tournamentBracket) match(matchId string) *bracketMatch {
+	for i := range bracket.Matches {
+		if bracket.Matches[i].Id == matchId {
+			return &bracket.Matches[i]
+		}
+	}
+	return nil
+}
+
+func (match *bracketMatch) title(tournamentName string) string {
+	switch match.Bracket {
+	case bracketGrandFinal:
+		return fmt.Sprintf("%s - Grand final", tournamentName)
+	case bracketLosers:
+		return fmt.Sprintf("%s - Losers round %d, match %d", tournamentName, match.Round, match.number)
+	default:
+		return fmt.Sprintf("%s - Round %d, match %d", tournamentName, match.Round, match.number)
+	}
+}
+
+// Create the tournament's bracket and the fixtures of its next rounds (scheduled, without teams),
+// then advance the winners of the first-round fixtures that are already decided.
+// First-round fixtures must belong to the tournament, have at most two teams and not end in a draw.
+func (server *LiveDataServer) createBracket(tournamentId string, request bracketRequest) error {
+	server.bracketsLock.Lock()
+	defer server.bracketsLock.Unlock()
+
+	if _, found := server.brackets[tournamentId]; found {
+		return errBracketExists
+	}
+	if len(server.store.fixturesByTournament(tournamentId)) == 0 {
+		return errTournamentNotFound
+	}
+
+	bracket, err := newTournamentBracket(tournamentId, request.Format, request.FixtureIds)
+	if err != nil {
+		return err
+	}
+	for _, fixtureId := range request.FixtureIds {
+		fixture := server.findFixture(fixtureId)
+		if fixture == nil || fixture.Tournament.Id != tournamentId || len(fixture.Teams) > 2 || fixture.Standings != nil ||
+			(fixture.Series != nil && fixture.Series.Rules.AllowDraws) {
+			return errInvalidBracket
+		}
+	}
+
+	// Next rounds are played under the rules of the first round. Their fixtures can already exist
+	// (e.g. the bracket is created again after a restart), but only in this tournament.
+	firstFixture := server.findFixture(request.FixtureIds[0])
+	nextRounds := make([]fixture, 0, len(bracket.Matches))
+	for _, match := range bracket.Matches[len(request.FixtureIds):] {
+		if existing := server.findFixture(match.FixtureId); existing != nil {
+			if existing.Tournament.Id != tournamentId {
+				return errFixtureExists
+			}
+			continue
+		}
+
+		nextRound := fixture{
+			Id:         match.FixtureId,
+			Title:      match.title(firstFixture.Tournament.Name),
+			Tournament: firstFixture.Tournament,
+		}
+		if firstFixture.Series != nil {
+			nextRound.Series = newFixtureSeries(firstFixture.Series.Rules)
+		}
+		nextRounds = append(nextRounds, nextRound)
+	}
+	// Added all at once, so a fixture added meanwhile with one of their ids doesn't leave the others behind
+	if err := server.addFixtures(nextRounds); err != nil {
+		return err
+	}
+
+	server.brackets[tournamentId] = bracket
+	server.advanceTeams(bracket)
+
+	log.Println(fmt.Sprintf("@createBracket -> tournamentId '%s' has a %s bracket of %d matches", tournamentId, request.Format, len(bracket.Matches)))
+
+	return nil
+}
+
+// Returns a copy of the tournament's bracket with the teams of its matches, or nil if it has none
+func (server *LiveDataServer) findBracket(tournamentId string) *tournamentBracket {
+	server.bracketsLock.Lock()
+	defer server.bracketsLock.Unlock()
+
+	bracket := server.brackets[tournamentId]
+	if bracket == nil {
+		return nil
+	}
+
+	clone := *bracket
+	clone.Matches = append([]bracketMatch(nil), bracket.Matches...)
+	for i := range clone.Matches {
+		clone.Matches[i].TeamIds = make([]string, 0, 2)
+		if fixture := server.findFixture(clone.Matches[i].FixtureId); fixture != nil {
+			for _, team := range fixture.Teams {
+				clone.Matches[i].TeamIds = append(clone.Matches[i].TeamIds, team.Id)
+			}
+		}
+	}
+	return &clone
+}
+
+// Advance the teams of the bracket the fixture is in, if any, once the fixture has a winner.
+// Advancing adds teams to fixtures, so it must be called without holding any of the store's locks.
+func (server *LiveDataServer) advanceBracket(fixtureId string) {
+	tournamentId := server.findTournamentId(fixtureId)
+
+	server.bracketsLock.Lock()
+	defer server.bracketsLock.Unlock()
+
+	if bracket := server.brackets[tournamentId]; bracket != nil {
+		server.advanceTeams(bracket)
+	}
+}
+
+// Send the winners (and losers) of the bracket's newly decided matches to their next matches.
+// Matches are in round order, so a single pass also advances the teams of the matches it decides.
+// A match is decided once, later corrections of its winner aren't carried over to the next rounds.
+// Must be called holding the bracketsLock.
+func (server *LiveDataServer) advanceTeams(bracket *tournamentBracket) {
+	for i := range bracket.Matches {
+		match := &bracket.Matches[i]
+		if match.WinningTeamId != "" {
+			continue
+		}
+		fixture := server.findFixture(match.FixtureId)
+		if fixture == nil || fixture.WinningTeamId == "" {
+			continue
+		}
+
+		match.WinningTeamId = fixture.WinningTeamId
+		for _, team := range fixture.Teams {
+			nextMatchId := match.LoserTo
+			if team.Id == match.WinningTeamId {
+				nextMatchId = match.WinnerTo
+				if nextMatchId == "" {
+					bracket.ChampionTeamId = team.Id
+				}
+			}
+			if nextMatchId == "" {
+				continue
+			}
+
+			nextMatch := bracket.match(nextMatchId)
+			err := server.addTeam(nextMatch.FixtureId, fixtureTeam{Id: team.Id, Name: team.Name})
+			if err != nil && err != errTeamExists {
+				log.Println(fmt.Sprintf("@advanceTeams -> error advancing teamId '%s' to fixtureId '%s': %s", team.Id, nextMatch.FixtureId, err.Error()))
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTournamentBracket(t *testing.T) {
+
+	setup := func() *LiveDataServer {
+		tournament := fixtureTournament{Id: "T1", Name: "Cup"}
+		viewModel := &ViewModel{
+			{Id: "F1", Tournament: tournament, Teams: []fixtureTeam{{Id: "TE1"}, {Id: "TE2"}}},
+			{Id: "F2", Tournament: tournament, Teams: []fixtureTeam{{Id: "TE3"}, {Id: "TE4"}}},
+			{Id: "F3", Tournament: tournament, Teams: []fixtureTeam{{Id: "TE5"}, {Id: "TE6"}}},
+			{Id: "F4", Tournament: tournament, Teams: []fixtureTeam{{Id: "TE7"}, {Id: "TE8"}}},
+			{Id: "F5", Tournament: fixtureTournament{Id: "T2"}, Teams: []fixtureTeam{{Id: "TE1"}, {Id: "TE2"}}},
+		}
+		server := newLiveDataServer(viewModel, external.NewMemoryPublisher())
+		server.adminToken = testAdminToken
+		return server
+	}
+
+	matchIds := func(bracket *tournamentBracket) []string {
+		ids := make([]string, 0)
+		for _, match := range bracket.Matches {
+			ids = append(ids, match.Id)
+		}
+		return ids
+	}
+
+	teamIds := func(fixture *fixture) []string {
+		ids := make([]string, 0)
+		for _, team := range fixture.Teams {
+			ids = append(ids, team.Id)
+		}
+		return ids
+	}
+
+	t.Run("when a double elimination bracket is built it should link the losers bracket to the winners bracket", func(t *testing.T) {
+		// Act
+		bracket, err := newTournamentBracket("T1", bracketFormatDoubleElimination, []string{"F1", "F2", "F3", "F4"})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"W1-1", "W1-2", "W1-3", "W1-4", "W2-1", "W2-2", "W3-1", "L1-1", "L1-2", "L2-1", "L2-2", "L3-1", "L4-1", "GF"}, matchIds(bracket))
+		assert.Equal(t, "L1-2", bracket.match("W1-4").LoserTo)
+		assert.Equal(t, "L2-2", bracket.match("W2-2").LoserTo)
+		assert.Equal(t, "L4-1", bracket.match("W3-1").LoserTo)
+		assert.Equal(t, "GF", bracket.match("W3-1").WinnerTo)
+		assert.Equal(t, "L2-2", bracket.match("L1-2").WinnerTo)
+		assert.Equal(t, "L3-1", bracket.match("L2-2").WinnerTo)
+		assert.Equal(t, "GF", bracket.match("L4-1").WinnerTo)
+		assert.Equal(t, "T1-L3-1", bracket.match("L3-1").FixtureId)
+	})
+
+	t.Run("when the first round isn't a power of 2 or the format is unknown it should reject the bracket", func(t *testing.T) {
+		_, threeErr := newTournamentBracket("T1", bracketFormatSingleElimination, []string{"F1", "F2", "F3"})
+		_, duplicateErr := newTournamentBracket("T1", bracketFormatSingleElimination, []string{"F1", "F1"})
+		_, singleFixtureErr := newTournamentBracket("T1", bracketFormatDoubleElimination, []string{"F1"})
+		_, formatErr := newTournamentBracket("T1", "round_robin", []string{"F1", "F2"})
+
+		assert.Equal(t, errInvalidBracket, threeErr)
+		assert.Equal(t, errInvalidBracket, duplicateErr)
+		assert.Equal(t, errInvalidBracket, singleFixtureErr)
+		assert.Equal(t, errInvalidBracket, formatErr)
+	})
+
+	t.Run("when fixtures of a single elimination bracket are won it should advance the winners up to the champion", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.updateWinnerAndPublish("F1", "TE2")
+
+		// Act
+		err := server.createBracket("T1", bracketRequest{Format: bracketFormatSingleElimination, FixtureIds: []string{"F1", "F2", "F3", "F4"}})
+		_ = server.updateWinnerAndPublish("F2", "TE3")
+		_ = server.updateWinnerAndPublish("F3", "TE5")
+		_ = server.updateWinnerAndPublish("F4", "TE8")
+		_ = server.updateWinnerAndPublish("T1-W2-1", "TE3")
+
+		// Assert
+		assert.NoError(t, err)
+		semiFinal := server.findFixture("T1-W2-2")
+		assert.Equal(t, "Cup - Round 2, match 2", semiFinal.Title)
+		assert.Equal(t, "T1", semiFinal.Tournament.Id)
+		assert.Equal(t, []string{"TE5", "TE8"}, teamIds(semiFinal))
+		assert.Equal(t, fixtureStatusScheduled, semiFinal.Status)
+		assert.Equal(t, []string{"TE3"}, teamIds(server.findFixture("T1-W3-1")))
+
+		// Act
+		_ = server.updateWinnerAndPublish("T1-W2-2", "TE8")
+		_ = server.updateWinnerAndPublish("T1-W3-1", "TE8")
+
+		// Assert
+		bracket := server.findBracket("T1")
+		assert.Equal(t, "TE8", bracket.ChampionTeamId)
+		assert.Equal(t, []string{"TE3", "TE8"}, bracket.Matches[6].TeamIds)
+		assert.Equal(t, "TE8", bracket.Matches[6].WinningTeamId)
+	})
+
+	t.Run("when a fixture of a double elimination bracket is won it should drop the loser to the losers bracket", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.createBracket("T1", bracketRequest{Format: bracketFormatDoubleElimination, FixtureIds: []string{"F1", "F2"}})
+
+		// Act
+		_ = server.updateWinnerAndPublish("F1", "TE1")
+		_ = server.updateWinnerAndPublish("F2", "TE4")
+		_ = server.updateWinnerAndPublish("T1-L1-1", "TE3")
+		_ = server.updateWinnerAndPublish("T1-W2-1", "TE1")
+
+		// Assert
+		assert.Equal(t, []string{"TE1", "TE4"}, teamIds(server.findFixture("T1-W2-1")))
+		assert.Equal(t, []string{"TE3", "TE4"}, teamIds(server.findFixture("T1-L2-1")))
+		assert.Equal(t, []string{"TE1"}, teamIds(server.findFixture("T1-GF")))
+		assert.Equal(t, "Cup - Grand final", server.findFixture("T1-GF").Title)
+
+		// Act
+		_ = server.updateWinnerAndPublish("T1-L2-1", "TE4")
+		_ = server.updateWinnerAndPublish("T1-GF", "TE4")
+
+		// Assert
+		assert.Equal(t, []string{"TE1", "TE4"}, teamIds(server.findFixture("T1-GF")))
+		assert.Equal(t, "TE4", server.findBracket("T1").ChampionTeamId)
+	})
+
+	t.Run("when the winner is received from the bus it should advance it", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.createBracket("T1", bracketRequest{Format: bracketFormatSingleElimination, FixtureIds: []string{"F1", "F2"}})
+
+		// Act
+		server.winningTeamUpdateReceiver.Receive(external.NewWinningTeamUpdate(external.EventEnvelope{}, "F2", "TE4"))
+
+		// Assert
+		assert.Equal(t, []string{"TE4"}, teamIds(server.findFixture("T1-W2-1")))
+	})
+
+	t.Run("when a next-round fixture id is taken partway through the list it should add none of them", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.addFixture(fixture{Id: "T1-W2-2", Tournament: fixtureTournament{Id: "T2"}})
+		version := server.currentVersion()
+
+		// Act
+		err := server.createBracket("T1", bracketRequest{Format: bracketFormatSingleElimination, FixtureIds: []string{"F1", "F2", "F3", "F4"}})
+
+		// Assert
+		assert.Equal(t, errFixtureExists, err)
+		assert.Nil(t, server.findFixture("T1-W2-1"))
+		assert.Nil(t, server.findFixture("T1-W3-1"))
+		assert.Nil(t, server.findBracket("T1"))
+		assert.Equal(t, version, server.currentVersion())
+	})
+
+	t.Run("when a bracket is created through the API it should validate it and serve it", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		post := func(tournamentId string, body string) int {
+			recorder := httptest.NewRecorder()
+			httpRequest := httptest.NewRequest(http.MethodPost, "/tournaments/"+tournamentId+"/bracket", strings.NewReader(body))
+			httpRequest.Header.Set("Authorization", "Bearer "+testAdminToken)
+			server.handler().ServeHTTP(recorder, httpRequest)
+			return recorder.Code
+		}
+		recorder := httptest.NewRecorder()
+		missingRecorder := httptest.NewRecorder()
+
+		// Act
+		server.handler().ServeHTTP(missingRecorder, httptest.NewRequest(http.MethodGet, "/tournaments/T1/bracket", nil))
+		otherTournamentCode := post("T1", `{"format":"single_elimination","fixtureIds":["F1","F5"]}`)
+		unknownTournamentCode := post("T9", `{"format":"single_elimination","fixtureIds":["F1","F2"]}`)
+		createdCode := post("T1", `{"format":"single_elimination","fixtureIds":["F1","F2"]}`)
+		existsCode := post("T1", `{"format":"single_elimination","fixtureIds":["F3","F4"]}`)
+		server.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/tournaments/T1/bracket", nil))
+
+		// Assert
+		assert.Equal(t, http.StatusNotFound, missingRecorder.Code)
+		assert.Equal(t, http.StatusBadRequest, otherTournamentCode)
+		assert.Equal(t, http.StatusNotFound, unknownTournamentCode)
+		assert.Equal(t, http.StatusCreated, createdCode)
+		assert.Equal(t, http.StatusConflict, existsCode)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"tournamentId":"T1","format":"single_elimination","matches":[
+			{"id":"W1-1","bracket":"winners","round":1,"fixtureId":"F1","teamIds":["TE1","TE2"],"winnerTo":"W2-1"},
+			{"id":"W1-2","bracket":"winners","round":1,"fixtureId":"F2","teamIds":["TE3","TE4"],"winnerTo":"W2-1"},
+			{"id":"W2-1","bracket":"winners","round":2,"fixtureId":"T1-W2-1","teamIds":[]}]}`,
+			recorder.Body.String())
+	})
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"sort"
+)
+
+// Table of a tournament's teams, aggregated from the results of its fixtures
+type tournamentStandings struct {
+	TournamentId string `json:"tournamentId"`
+	// Ranked by wins, then score differential, then score
+	Rows []tournamentStanding `json:"rows"`
+}
+
+type tournamentStanding struct {
+	Rank     int    `json:"rank"`
+	TeamId   string `json:"teamId"`
+	TeamName string `json:"teamName"`
+	Played   int    `json:"played"`
+	Wins     int    `json:"wins"`
+	Draws    int    `json:"draws"`
+	Losses   int    `json:"losses"`
+	// Maps won for fixtures with match rules, points (or kills) otherwise
+	ScoreFor          int `json:"scoreFor"`
+	ScoreAgainst      int `json:"scoreAgainst"`
+	ScoreDifferential int `json:"scoreDifferential"`
+}
+
+// Outcome of a decided fixture
+type fixtureResult struct {
+	WinningTeamId string
+	Draw          bool
+	// Score of each team, by team id: maps won for fixtures with match rules, points (or kills) otherwise
+	Scores map[string]int
+}
+
+// Returns the fixture's result, false while it's undecided (no winner and no draw)
+func (fixture *fixture) result() (fixtureResult, bool) {
+	draw := fixture.Series != nil && fixture.Series.Draw
+	if fixture.WinningTeamId == "" && !draw {
+		return fixtureResult{}, false
+	}
+
+	result := fixtureResult{
+		WinningTeamId: fixture.WinningTeamId,
+		Draw:          draw,
+		Scores:        make(map[string]int, len(fixture.Teams)),
+	}
+	for _, team := range fixture.Teams {
+		if fixture.Series != nil {
+			result.Scores[team.Id] = fixture.Series.Score[team.Id]
+		} else {
+			result.Scores[team.Id] = team.Score
+		}
+	}
+	return result, true
+}
+
+// Score of every team but the given one
+func (result fixtureResult) scoreAgainst(teamId string) int {
+	against := 0
+	for opponentId, score := range result.Scores {
+		if opponentId != teamId {
+			against += score
+		}
+	}
+	return against
+}
+
+// Aggregate the results of the tournament's decided fixtures. Teams that haven't played a decided
+// fixture yet are listed with no results. Returns nil if the tournament has no fixtures.
+func (server *LiveDataServer) tournamentStandings(tournamentId string) *tournamentStandings {
+	fixtures := server.store.fixturesByTournament(tournamentId)
+	if len(fixtures) == 0 {
+		return nil
+	}
+
+	rowsByTeamId := make(map[string]*tournamentStanding)
+	for _, fixture := range fixtures {
+		result, decided := fixture.result()
+		for _, team := range fixture.Teams {
+			row, found := rowsByTeamId[team.Id]
+			if !found {
+				row = &tournamentStanding{TeamId: team.Id}
+				rowsByTeamId[team.Id] = row
+			}
+			if team.Name != "" {
+				row.TeamName = team.Name
+			}
+			if !decided {
+				continue
+			}
+
+			row.Played++
+			switch {
+			case result.Draw:
+				row.Draws++
+			case result.WinningTeamId == team.Id:
+				row.Wins++
+			default:
+				row.Losses++
+			}
+			row.ScoreFor += result.Scores[team.Id]
+			row.ScoreAgainst += result.scoreAgainst(team.Id)
+		}
+	}
+
+	rows := make([]tournamentStanding, 0, len(rowsByTeamId))
+	for _, row := range rowsByTeamId {
+		row.ScoreDifferential = row.ScoreFor - row.ScoreAgainst
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		switch {
+		case rows[i].Wins != rows[j].Wins:
+			return rows[i].Wins > rows[j].Wins
+		case rows[i].ScoreDifferential != rows[j].ScoreDifferential:
+			return rows[i].ScoreDifferential > rows[j].ScoreDifferential
+		case rows[i].ScoreFor != rows[j].ScoreFor:
+			return rows[i].ScoreFor > rows[j].ScoreFor
+		default:
+			return rows[i].TeamId < rows[j].TeamId
+		}
+	})
+	for i := range rows {
+		rows[i].Rank = i + 1
+	}
+
+	return &tournamentStandings{TournamentId: tournamentId, Rows: rows}
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTournamentStandings(t *testing.T) {
+
+	setup := func() *LiveDataServer {
+		tournament := fixtureTournament{Id: "T1", Name: "Tournament 1"}
+		viewModel := &ViewModel{
+			{Id: "F1", Tournament: tournament, Teams: []fixtureTeam{{Id: "TE1", Name: "Team 1"}, {Id: "TE2", Name: "Team 2"}}},
+			{Id: "F2", Tournament: tournament, Teams: []fixtureTeam{{Id: "TE2", Name: "Team 2"}, {Id: "TE3", Name: "Team 3"}}},
+			{Id: "F3", Tournament: tournament, Teams: []fixtureTeam{{Id: "TE1", Name: "Team 1"}, {Id: "TE3", Name: "Team 3"}},
+				Series: &fixtureSeries{Rules: external.MatchRules{BestOf: 2, MapScoreLimit: 1, AllowDraws: true}}},
+			{Id: "F4", Tournament: fixtureTournament{Id: "T2"}, Teams: []fixtureTeam{{Id: "TE1"}, {Id: "TE4"}}},
+		}
+		return newLiveDataServer(viewModel, external.NewMemoryPublisher())
+	}
+
+	t.Run("when fixtures are decided it should rank the teams by wins, then score differential", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.updateScoreAndPublish("F1", "TE1", 10)
+		_ = server.updateScoreAndPublish("F1", "TE2", 4)
+		_ = server.updateWinnerAndPublish("F1", "TE1")
+		_ = server.updateScoreAndPublish("F2", "TE2", 10)
+		_ = server.updateScoreAndPublish("F2", "TE3", 9)
+		_ = server.updateWinnerAndPublish("F2", "TE2")
+		_ = server.updateScoreAndPublish("F4", "TE4", 10)
+		_ = server.updateWinnerAndPublish("F4", "TE4")
+
+		// Act
+		standings := server.tournamentStandings("T1")
+
+		// Assert
+		assert.Equal(t, "T1", standings.TournamentId)
+		assert.Equal(t, []tournamentStanding{
+			{Rank: 1, TeamId: "TE1", TeamName: "Team 1", Played: 1, Wins: 1, ScoreFor: 10, ScoreAgainst: 4, ScoreDifferential: 6},
+			{Rank: 2, TeamId: "TE2", TeamName: "Team 2", Played: 2, Wins: 1, Losses: 1, ScoreFor: 14, ScoreAgainst: 19, ScoreDifferential: -5},
+			{Rank: 3, TeamId: "TE3", TeamName: "Team 3", Played: 1, Losses: 1, ScoreFor: 9, ScoreAgainst: 10, ScoreDifferential: -1},
+		}, standings.Rows)
+	})
+
+	t.Run("when a series ends in a draw it should count it with the maps won as score", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "F3", 1, "TE1", 1)
+		_ = server.applyMapScoreUpdate(external.EventEnvelope{}, "F3", 2, "TE3", 1)
+
+		// Act
+		standings := server.tournamentStandings("T1")
+
+		// Assert
+		assert.Equal(t, tournamentStanding{Rank: 1, TeamId: "TE1", TeamName: "Team 1", Played: 1, Draws: 1, ScoreFor: 1, ScoreAgainst: 1}, standings.Rows[0])
+		assert.Equal(t, tournamentStanding{Rank: 2, TeamId: "TE3", TeamName: "Team 3", Played: 1, Draws: 1, ScoreFor: 1, ScoreAgainst: 1}, standings.Rows[1])
+	})
+
+	t.Run("when standings are requested it should serve them, or 404 for an unknown tournament", func(t *testing.T) {
+		// Arrange
+		server := setup()
+		_ = server.updateWinnerAndPublish("F4", "TE1")
+		recorder := httptest.NewRecorder()
+		unknownRecorder := httptest.NewRecorder()
+
+		// Act
+		server.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/tournaments/T2/standings", nil))
+		server.handler().ServeHTTP(unknownRecorder, httptest.NewRequest(http.MethodGet, "/tournaments/T9/standings", nil))
+
+		// Assert
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"tournamentId":"T2","rows":[
+			{"rank":1,"teamId":"TE1","teamName":"","played":1,"wins":1,"draws":0,"losses":0,"scoreFor":0,"scoreAgainst":0,"scoreDifferential":0},
+			{"rank":2,"teamId":"TE4","teamName":"","played":1,"wins":0,"draws":0,"losses":1,"scoreFor":0,"scoreAgainst":0,"scoreDifferential":0}]}`,
+			recorder.Body.String())
+		assert.Equal(t, http.StatusNotFound, unknownRecorder.Code)
+	})
+}
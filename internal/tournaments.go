@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+const tournamentsPath = "/tournaments"
+
+var (
+	errTournamentNotFound = errors.New("tournament not found")
+	errInvalidBracket     = errors.New("invalid bracket")
+	errBracketExists      = errors.New("bracket already exists")
+)
+
+// Tournaments endpoints:
+//
+//	GET  /tournaments/{tournamentId}/standings   teams ranked by the results of the tournament's fixtures
+//	GET  /tournaments/{tournamentId}/bracket     elimination bracket, with the teams advanced so far
+//	POST /tournaments/{tournamentId}/bracket     create the bracket from its first-round fixtures
+func (server *LiveDataServer) handleTournamentsRequest(w http.ResponseWriter, r *http.Request) {
+	segments, err := pathSegments(r.URL, tournamentsPath)
+	if err != nil || len(segments) != 2 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case segments[1] == "standings" && r.Method == http.MethodGet:
+		standings := server.tournamentStandings(segments[0])
+		if standings == nil {
+			writeFixturesResponse(w, http.StatusOK, errTournamentNotFound)
+			return
+		}
+		writeJsonResponse(w, standings)
+	case segments[1] == "bracket" && r.Method == http.MethodGet:
+		bracket := server.findBracket(segments[0])
+		if bracket == nil {
+			writeFixturesResponse(w, http.StatusOK, errTournamentNotFound)
+			return
+		}
+		writeJsonResponse(w, bracket)
+	case segments[1] == "bracket" && r.Method == http.MethodPost:
+		var request bracketRequest
+		if !decodeFixturesRequest(w, r, &request) {
+			return
+		}
+		writeFixturesResponse(w, http.StatusCreated, server.createBracket(segments[0], request))
+	case segments[1] == "standings" || segments[1] == "bracket":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writeJsonResponse(w http.ResponseWriter, value interface{}) {
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		log.Print(fmt.Sprintf("@writeJsonResponse -> error marshalling response: %s", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	_, err = w.Write(jsonBytes)
+	if err != nil {
+		log.Print(fmt.Sprintf("@writeJsonResponse -> error writing bytes: %s", err.Error()))
+	}
+}
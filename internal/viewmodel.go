@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"strings"
 
 	"github.com/Zedronar/go-dummy-app.git/external"
+	"github.com/Zedronar/go-dummy-app.git/internal/data"
 )
 
 type fixtureTournament struct {
@@ -17,8 +19,10 @@ type fixtureTeam struct {
 	Id   string `json:"id"`
 	Name string `json:"name"`
 
-	// Live data
-	Score int `json:"score"`
+	// Live data, the score on the map being played for fixtures with match rules, and the kills in
+	// free-for-all fixtures, where teams are also placed
+	Score     int `json:"score"`
+	Placement int `json:"placement,omitempty"`
 }
 
 type fixture struct {
@@ -29,20 +33,101 @@ type fixture struct {
 	ScheduledStartTime int64             `json:"scheduledStartTimeUnixSeconds"`
 
 	// Live data
-	WinningTeamId string `json:"winningTeamId"`
+	Status        fixtureStatus     `json:"status"`
+	WinningTeamId string            `json:"winningTeamId"`
+	Series        *fixtureSeries    `json:"series,omitempty"`
+	Standings     *fixtureStandings `json:"standings,omitempty"`
 }
 
 type ViewModel []fixture
 
-func (viewModel *ViewModel) PublishViewModel() {
-	err := external.PublishViewModel(viewModel)
+// Build a viewmodel from the fixtures returned by a data provider
+func newViewModel(fixtures []data.Fixture) *ViewModel {
+	viewModel := make(ViewModel, 0, len(fixtures))
+	for _, dataFixture := range fixtures {
+		teams := make([]fixtureTeam, 0, len(dataFixture.Teams))
+		for _, team := range dataFixture.Teams {
+			teams = append(teams, fixtureTeam{
+				Id:    team.Id,
+				Name:  team.Name,
+				Score: team.Score,
+			})
+		}
+		viewModel = append(viewModel, fixture{
+			Id:    dataFixture.Id,
+			Title: dataFixture.Title,
+			Tournament: fixtureTournament{
+				Id:   dataFixture.Tournament.Id,
+				Name: dataFixture.Tournament.Name,
+			},
+			Teams:              teams,
+			ScheduledStartTime: dataFixture.ScheduledStartTime,
+			Status:             fixtureStatus(dataFixture.Status),
+			WinningTeamId:      dataFixture.WinningTeamId,
+			Series:             newFixtureSeriesFromData(dataFixture),
+			Standings:          newFixtureStandingsFromData(dataFixture),
+		})
+	}
+	return &viewModel
+}
+
+// Full viewmodel, published periodically so consumers of the patches can resync. Fixtures are keyed
+// by id, so the patches apply to it.
+type viewModelSnapshot struct {
+	Version  uint64              `json:"version"`
+	Fixtures map[string]*fixture `json:"fixtures"`
+}
+
+func newViewModelSnapshot(version uint64, fixtures []*fixture) *viewModelSnapshot {
+	snapshot := &viewModelSnapshot{
+		Version:  version,
+		Fixtures: make(map[string]*fixture, len(fixtures)),
+	}
+	for _, fixture := range fixtures {
+		snapshot.Fixtures[fixture.Id] = fixture
+	}
+	return snapshot
+}
+
+// RFC 6902 (JSON Patch) operation on the snapshot document, fixtures are addressed by id
+// (e.g. "/fixtures/F1/teams/0/score").
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Changes applied to the viewmodel by a single mutation
+type viewModelPatch struct {
+	Version    uint64           `json:"version"`
+	Operations []patchOperation `json:"operations"`
+}
+
+func (patch *viewModelPatch) publish(publisher external.Publisher) {
+	err := publisher.PublishViewModelPatch(patch)
 
 	if err != nil {
-		log.Print(fmt.Sprintf("@updateAndPublishViewModel -> error publishing viewmodel: %s", err.Error()))
+		log.Print(fmt.Sprintf("@publish -> error publishing viewmodel patch: %s", err.Error()))
 		return
 	}
 }
 
+// JSON pointer to a fixture of the snapshot, or to one of its fields
+func patchPath(fixtureId string, segments ...string) string {
+	return jsonPointer(append([]string{"fixtures", fixtureId}, segments...)...)
+}
+
+// Build a JSON pointer from the given path segments, escaping them as per RFC 6901
+func jsonPointer(segments ...string) string {
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	var path strings.Builder
+	for _, segment := range segments {
+		path.WriteString("/")
+		path.WriteString(escaper.Replace(segment))
+	}
+	return path.String()
+}
+
 func (viewModel *ViewModel) Sort() {
 	// Sort viewmodel's fixtures by fixture id
 	sort.Slice(*viewModel, func(i, j int) bool {
@@ -55,9 +140,66 @@ func (viewModel *ViewModel) Sort() {
 	}
 }
 
+// Find team index by id using binary search, returns -1 if not found
+func (fixture *fixture) findTeamIndex(teamId string) int {
+	teamIndex := sort.Search(len(fixture.Teams), func(i int) bool {
+		return fixture.Teams[i].Id >= teamId
+	})
+
+	if teamIndex >= len(fixture.Teams) || fixture.Teams[teamIndex].Id != teamId {
+		return -1
+	}
+
+	return teamIndex
+}
+
 // Sort teams by team id
 func (fixture *fixture) sortTeams() {
 	sort.Slice(fixture.Teams, func(i, j int) bool {
 		return fixture.Teams[i].Id < fixture.Teams[j].Id
 	})
 }
+
+// Insert the team keeping the teams sorted by team id.
+// Returns the team index, or -1 if there's already a team with that id.
+func (fixture *fixture) insertTeam(team fixtureTeam) int {
+	teamIndex := sort.Search(len(fixture.Teams), func(i int) bool {
+		return fixture.Teams[i].Id >= team.Id
+	})
+	if teamIndex < len(fixture.Teams) && fixture.Teams[teamIndex].Id == team.Id {
+		return -1
+	}
+
+	fixture.Teams = append(fixture.Teams, fixtureTeam{})
+	copy(fixture.Teams[teamIndex+1:], fixture.Teams[teamIndex:])
+	fixture.Teams[teamIndex] = team
+
+	return teamIndex
+}
+
+// Remove the team with the given id, returns its former index or -1 if not found
+func (fixture *fixture) removeTeam(teamId string) int {
+	teamIndex := fixture.findTeamIndex(teamId)
+	if teamIndex < 0 {
+		return -1
+	}
+
+	fixture.Teams = append(fixture.Teams[:teamIndex], fixture.Teams[teamIndex+1:]...)
+
+	return teamIndex
+}
+
+// Deep copy, so the fixture can be handed out without sharing the viewmodel's teams
+func (fixture *fixture) clone() *fixture {
+	clone := *fixture
+	clone.Teams = append([]fixtureTeam(nil), fixture.Teams...)
+	if fixture.Series != nil {
+		clone.Series = fixture.Series.clone()
+	}
+	if fixture.Standings != nil {
+		// Standings are ranked into new rows, so the rows can be shared
+		standings := *fixture.Standings
+		clone.Standings = &standings
+	}
+	return &clone
+}
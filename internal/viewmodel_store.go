@@ -0,0 +1,371 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Holds the viewmodel's fixtures, indexed by fixture, team and tournament id so lookups are O(1)
+// and don't depend on the fixtures' order. Fixtures are serialized sorted by fixture id (and their
+// teams by team id), so the JSON stays deterministic.
+//
+// Reads never take a lock: fixtures and indexes are immutable snapshots, replaced (copy-on-write)
+// through atomic.Value on every change. Updates to a fixture only lock that fixture's entry, and
+// the store lock is only taken by structural changes (fixtures/teams added or removed, fixtures
+// moved to another tournament), which are rare.
+type ViewModelStore struct {
+	// Serializes structural changes. Lock order: store, then fixture entry.
+	sync.Mutex
+
+	// *viewModelIndex
+	index atomic.Value
+}
+
+// Immutable once stored, structural changes build a new one
+type viewModelIndex struct {
+	entries map[string]*fixtureEntry
+
+	// Sorted fixture ids, in serialization order
+	fixtureIds []string
+
+	// team id -> ids of the fixtures it plays in
+	teamFixtureIds map[string]map[string]struct{}
+
+	// tournament id -> ids of its fixtures
+	tournamentFixtureIds map[string]map[string]struct{}
+}
+
+type fixtureEntry struct {
+	// Serializes the updates to this fixture
+	sync.Mutex
+
+	// *fixtureSnapshot, nil once the fixture is removed
+	current atomic.Value
+
+	// Live updates applied to the fixture, to reject stale and duplicate ones: the highest sequence
+	// received per producer (to detect gaps), and the last updates by field (see commitEnvelope)
+	sequences eventSequences
+	fields    map[string]*fieldUpdates
+}
+
+// Immutable state of a fixture, updates store a modified copy
+type fixtureSnapshot struct {
+	fixture *fixture
+
+	// team id -> index in the fixture's teams
+	teamIndexes map[string]int
+}
+
+func newViewModelStore(viewModel *ViewModel) *ViewModelStore {
+	index := &viewModelIndex{
+		entries:              make(map[string]*fixtureEntry, len(*viewModel)),
+		fixtureIds:           make([]string, 0, len(*viewModel)),
+		teamFixtureIds:       make(map[string]map[string]struct{}),
+		tournamentFixtureIds: make(map[string]map[string]struct{}),
+	}
+
+	for i := range *viewModel {
+		newFixture := (*viewModel)[i].clone()
+		newFixture.sortTeams()
+		newFixture.initStatus()
+		if !newFixture.initSeries() {
+			log.Println(fmt.Sprintf("@newViewModelStore -> fixtureId '%s' has invalid match rules, ignored", newFixture.Id))
+			newFixture.Series = nil
+		}
+		if !newFixture.initStandings() {
+			log.Println(fmt.Sprintf("@newViewModelStore -> fixtureId '%s' has an invalid points table, ignored", newFixture.Id))
+			newFixture.Standings = nil
+		}
+		if _, found := index.entries[newFixture.Id]; found {
+			log.Println(fmt.Sprintf("@newViewModelStore -> duplicated fixtureId '%s' ignored", newFixture.Id))
+			continue
+		}
+		index.entries[newFixture.Id] = newFixtureEntry(newFixture)
+		index.fixtureIds = append(index.fixtureIds, newFixture.Id)
+		for _, team := range newFixture.Teams {
+			addToIndex(index.teamFixtureIds, team.Id, newFixture.Id)
+		}
+		addToIndex(index.tournamentFixtureIds, newFixture.Tournament.Id, newFixture.Id)
+	}
+	sort.Strings(index.fixtureIds)
+
+	store := &ViewModelStore{}
+	store.index.Store(index)
+	return store
+}
+
+func newFixtureEntry(fixture *fixture) *fixtureEntry {
+	entry := &fixtureEntry{
+		sequences: make(eventSequences),
+		fields:    make(map[string]*fieldUpdates),
+	}
+	entry.store(fixture)
+	return entry
+}
+
+func newFixtureSnapshot(fixture *fixture) *fixtureSnapshot {
+	teamIndexes := make(map[string]int, len(fixture.Teams))
+	for i, team := range fixture.Teams {
+		teamIndexes[team.Id] = i
+	}
+	return &fixtureSnapshot{
+		fixture:     fixture,
+		teamIndexes: teamIndexes,
+	}
+}
+
+// Returns the current fixture state, or nil if it has been removed
+func (entry *fixtureEntry) load() *fixtureSnapshot {
+	return entry.current.Load().(*fixtureSnapshot)
+}
+
+// Replace the fixture state, the fixture must not be modified afterwards.
+// Callers hold the entry lock.
+func (entry *fixtureEntry) store(fixture *fixture) {
+	if fixture == nil {
+		entry.current.Store((*fixtureSnapshot)(nil))
+		return
+	}
+
+	// Teams usually don't change, so reuse their indexes when we can
+	if current, ok := entry.current.Load().(*fixtureSnapshot); ok && current != nil && sameTeamIds(current.fixture, fixture) {
+		entry.current.Store(&fixtureSnapshot{fixture: fixture, teamIndexes: current.teamIndexes})
+		return
+	}
+	entry.current.Store(newFixtureSnapshot(fixture))
+}
+
+// Returns the index of the team in the fixture's teams, or -1 if not found
+func (snapshot *fixtureSnapshot) findTeamIndex(teamId string) int {
+	teamIndex, found := snapshot.teamIndexes[teamId]
+	if !found {
+		return -1
+	}
+	return teamIndex
+}
+
+func (store *ViewModelStore) loadIndex() *viewModelIndex {
+	return store.index.Load().(*viewModelIndex)
+}
+
+func (store *ViewModelStore) len() int {
+	return len(store.loadIndex().fixtureIds)
+}
+
+// Returns the fixture's entry, or nil if not found
+func (store *ViewModelStore) entry(fixtureId string) *fixtureEntry {
+	return store.loadIndex().entries[fixtureId]
+}
+
+// Returns the current fixture state, or nil if not found
+func (store *ViewModelStore) snapshot(fixtureId string) *fixtureSnapshot {
+	entry := store.entry(fixtureId)
+	if entry == nil {
+		return nil
+	}
+	return entry.load()
+}
+
+// Returns the fixture, or nil if not found. The fixture must not be modified.
+func (store *ViewModelStore) fixture(fixtureId string) *fixture {
+	snapshot := store.snapshot(fixtureId)
+	if snapshot == nil {
+		return nil
+	}
+	return snapshot.fixture
+}
+
+// Returns the index of the team in the fixture's teams, or -1 if not found
+func (store *ViewModelStore) findTeamIndex(fixtureId string, teamId string) int {
+	snapshot := store.snapshot(fixtureId)
+	if snapshot == nil {
+		return -1
+	}
+	return snapshot.findTeamIndex(teamId)
+}
+
+// Returns the fixture team, or nil if not found. The team must not be modified.
+func (store *ViewModelStore) fixtureTeam(fixtureId string, teamId string) *fixtureTeam {
+	snapshot := store.snapshot(fixtureId)
+	if snapshot == nil {
+		return nil
+	}
+	teamIndex := snapshot.findTeamIndex(teamId)
+	if teamIndex < 0 {
+		return nil
+	}
+	return &snapshot.fixture.Teams[teamIndex]
+}
+
+// Returns the fixtures of the tournament, sorted by fixture id
+func (store *ViewModelStore) fixturesByTournament(tournamentId string) []*fixture {
+	index := store.loadIndex()
+	return index.sortedFixtures(index.tournamentFixtureIds[tournamentId])
+}
+
+// Returns the fixtures the team plays in, sorted by fixture id
+func (store *ViewModelStore) fixturesByTeam(teamId string) []*fixture {
+	index := store.loadIndex()
+	return index.sortedFixtures(index.teamFixtureIds[teamId])
+}
+
+// Call fn for every fixture, in fixture id order
+func (store *ViewModelStore) each(fn func(fixture *fixture)) {
+	for _, fixture := range store.list() {
+		fn(fixture)
+	}
+}
+
+// Returns the fixtures in fixture id order, this is what gets serialized
+func (store *ViewModelStore) list() []*fixture {
+	index := store.loadIndex()
+	fixtures := make([]*fixture, 0, len(index.fixtureIds))
+	for _, fixtureId := range index.fixtureIds {
+		if snapshot := index.entries[fixtureId].load(); snapshot != nil {
+			fixtures = append(fixtures, snapshot.fixture)
+		}
+	}
+	return fixtures
+}
+
+// Returns a copy of the fixtures, in fixture id order
+func (store *ViewModelStore) viewModel() *ViewModel {
+	fixtures := store.list()
+	viewModel := make(ViewModel, 0, len(fixtures))
+	for _, fixture := range fixtures {
+		viewModel = append(viewModel, *fixture.clone())
+	}
+	return &viewModel
+}
+
+// Shallow copy, the inner fixture id sets are copied by addToIndex/removeFromIndex when modified
+func (index *viewModelIndex) copy() *viewModelIndex {
+	copied := &viewModelIndex{
+		entries:              make(map[string]*fixtureEntry, len(index.entries)),
+		fixtureIds:           index.fixtureIds,
+		teamFixtureIds:       make(map[string]map[string]struct{}, len(index.teamFixtureIds)),
+		tournamentFixtureIds: make(map[string]map[string]struct{}, len(index.tournamentFixtureIds)),
+	}
+	for fixtureId, entry := range index.entries {
+		copied.entries[fixtureId] = entry
+	}
+	for teamId, fixtureIds := range index.teamFixtureIds {
+		copied.teamFixtureIds[teamId] = fixtureIds
+	}
+	for tournamentId, fixtureIds := range index.tournamentFixtureIds {
+		copied.tournamentFixtureIds[tournamentId] = fixtureIds
+	}
+	return copied
+}
+
+// Returns a new index including the fixture
+func (index *viewModelIndex) withFixture(fixture *fixture, entry *fixtureEntry) *viewModelIndex {
+	copied := index.copy()
+	copied.entries[fixture.Id] = entry
+
+	// Keep the serialization order
+	position := sort.SearchStrings(index.fixtureIds, fixture.Id)
+	copied.fixtureIds = make([]string, 0, len(index.fixtureIds)+1)
+	copied.fixtureIds = append(copied.fixtureIds, index.fixtureIds[:position]...)
+	copied.fixtureIds = append(copied.fixtureIds, fixture.Id)
+	copied.fixtureIds = append(copied.fixtureIds, index.fixtureIds[position:]...)
+
+	for _, team := range fixture.Teams {
+		addToIndex(copied.teamFixtureIds, team.Id, fixture.Id)
+	}
+	addToIndex(copied.tournamentFixtureIds, fixture.Tournament.Id, fixture.Id)
+
+	return copied
+}
+
+// Returns a new index without the fixture
+func (index *viewModelIndex) withoutFixture(fixture *fixture) *viewModelIndex {
+	copied := index.copy()
+	delete(copied.entries, fixture.Id)
+
+	position := sort.SearchStrings(index.fixtureIds, fixture.Id)
+	copied.fixtureIds = make([]string, 0, len(index.fixtureIds))
+	copied.fixtureIds = append(copied.fixtureIds, index.fixtureIds[:position]...)
+	copied.fixtureIds = append(copied.fixtureIds, index.fixtureIds[position+1:]...)
+
+	for _, team := range fixture.Teams {
+		removeFromIndex(copied.teamFixtureIds, team.Id, fixture.Id)
+	}
+	removeFromIndex(copied.tournamentFixtureIds, fixture.Tournament.Id, fixture.Id)
+
+	return copied
+}
+
+// Returns a new index with the fixture's teams and tournament updated from previous to current
+func (index *viewModelIndex) withFixtureChanged(previous *fixture, current *fixture) *viewModelIndex {
+	copied := index.copy()
+	for _, team := range previous.Teams {
+		removeFromIndex(copied.teamFixtureIds, team.Id, previous.Id)
+	}
+	for _, team := range current.Teams {
+		addToIndex(copied.teamFixtureIds, team.Id, current.Id)
+	}
+	removeFromIndex(copied.tournamentFixtureIds, previous.Tournament.Id, previous.Id)
+	addToIndex(copied.tournamentFixtureIds, current.Tournament.Id, current.Id)
+	return copied
+}
+
+func (index *viewModelIndex) sortedFixtures(fixtureIds map[string]struct{}) []*fixture {
+	ids := make([]string, 0, len(fixtureIds))
+	for fixtureId := range fixtureIds {
+		ids = append(ids, fixtureId)
+	}
+	sort.Strings(ids)
+
+	fixtures := make([]*fixture, 0, len(ids))
+	for _, fixtureId := range ids {
+		if snapshot := index.entries[fixtureId].load(); snapshot != nil {
+			fixtures = append(fixtures, snapshot.fixture)
+		}
+	}
+	return fixtures
+}
+
+func sameTeamIds(a *fixture, b *fixture) bool {
+	if len(a.Teams) != len(b.Teams) {
+		return false
+	}
+	for i := range a.Teams {
+		if a.Teams[i].Id != b.Teams[i].Id {
+			return false
+		}
+	}
+	return true
+}
+
+// Add the fixture id to the key's set. The set is copied, as it may be shared with a previous index.
+func addToIndex(index map[string]map[string]struct{}, key string, fixtureId string) {
+	fixtureIds := make(map[string]struct{}, len(index[key])+1)
+	for id := range index[key] {
+		fixtureIds[id] = struct{}{}
+	}
+	fixtureIds[fixtureId] = struct{}{}
+	index[key] = fixtureIds
+}
+
+// Remove the fixture id from the key's set. The set is copied, as it may be shared with a previous index.
+func removeFromIndex(index map[string]map[string]struct{}, key string, fixtureId string) {
+	if _, found := index[key][fixtureId]; !found {
+		return
+	}
+	if len(index[key]) == 1 {
+		delete(index, key)
+		return
+	}
+
+	fixtureIds := make(map[string]struct{}, len(index[key])-1)
+	for id := range index[key] {
+		if id != fixtureId {
+			fixtureIds[id] = struct{}{}
+		}
+	}
+	index[key] = fixtureIds
+}
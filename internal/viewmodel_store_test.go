@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewModelStore(t *testing.T) {
+
+	setup := func() *ViewModelStore {
+		return newViewModelStore(&ViewModel{
+			fixture{
+				Id:         "fixture-id-2",
+				Tournament: fixtureTournament{Id: "tournament-id-1"},
+				Teams:      []fixtureTeam{{Id: "team-id-3"}, {Id: "team-id-1"}},
+			},
+			fixture{
+				Id:         "fixture-id-1",
+				Tournament: fixtureTournament{Id: "tournament-id-1"},
+				Teams:      []fixtureTeam{{Id: "team-id-1"}, {Id: "team-id-2"}},
+			},
+			fixture{
+				Id:         "fixture-id-3",
+				Tournament: fixtureTournament{Id: "tournament-id-2"},
+				Teams:      []fixtureTeam{{Id: "team-id-4"}},
+			},
+		})
+	}
+
+	fixtureIds := func(fixtures []*fixture) []string {
+		ids := make([]string, 0)
+		for _, fixture := range fixtures {
+			ids = append(ids, fixture.Id)
+		}
+		return ids
+	}
+
+	t.Run("when store is constructed it should index fixtures and teams", func(t *testing.T) {
+		// Act
+		store := setup()
+
+		// Assert
+		assert.Equal(t, 3, store.len())
+		assert.Equal(t, "fixture-id-2", store.fixture("fixture-id-2").Id)
+		assert.Nil(t, store.fixture("invalid-fixture-id"))
+		assert.Equal(t, 1, store.findTeamIndex("fixture-id-2", "team-id-3"))
+		assert.Equal(t, -1, store.findTeamIndex("fixture-id-2", "invalid-team-id"))
+		assert.Equal(t, "team-id-1", store.fixtureTeam("fixture-id-2", "team-id-1").Id)
+		assert.Nil(t, store.fixtureTeam("invalid-fixture-id", "team-id-1"))
+	})
+
+	t.Run("when fixturesByTournament and fixturesByTeam are called it should return sorted fixtures", func(t *testing.T) {
+		// Arrange
+		store := setup()
+
+		// Act
+		tournamentFixtures := store.fixturesByTournament("tournament-id-1")
+		teamFixtures := store.fixturesByTeam("team-id-1")
+
+		// Assert
+		assert.Equal(t, []string{"fixture-id-1", "fixture-id-2"}, fixtureIds(tournamentFixtures))
+		assert.Equal(t, []string{"fixture-id-1", "fixture-id-2"}, fixtureIds(teamFixtures))
+		assert.Equal(t, 0, len(store.fixturesByTeam("invalid-team-id")))
+	})
+
+	t.Run("when store is marshalled it should be sorted by fixture and team id", func(t *testing.T) {
+		// Arrange
+		store := setup()
+
+		// Act
+		jsonBytes, err := json.Marshal(store.list())
+
+		// Assert
+		assert.NoError(t, err)
+		expected, _ := json.Marshal(store.viewModel())
+		assert.Equal(t, string(expected), string(jsonBytes))
+		assert.Equal(t, "fixture-id-1", (*store.viewModel())[0].Id)
+		assert.Equal(t, "team-id-1", (*store.viewModel())[1].Teams[0].Id)
+	})
+
+	t.Run("when store is empty it should marshal an empty array", func(t *testing.T) {
+		// Act
+		jsonBytes, err := json.Marshal(newViewModelStore(&ViewModel{}).list())
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "[]", string(jsonBytes))
+	})
+
+	t.Run("when fixtures are added and removed it should keep indexes and order", func(t *testing.T) {
+		// Arrange
+		store := setup()
+		previousIndex := store.loadIndex()
+		added := &fixture{Id: "fixture-id-0", Tournament: fixtureTournament{Id: "tournament-id-2"}, Teams: []fixtureTeam{{Id: "team-id-4"}}}
+
+		// Act
+		index := previousIndex.withFixture(added, newFixtureEntry(added))
+		index = index.withoutFixture(store.fixture("fixture-id-3"))
+		store.index.Store(index)
+
+		// Assert
+		assert.Equal(t, []string{"fixture-id-0", "fixture-id-1", "fixture-id-2"}, store.loadIndex().fixtureIds)
+		assert.Equal(t, []string{"fixture-id-0"}, fixtureIds(store.fixturesByTournament("tournament-id-2")))
+		assert.Equal(t, []string{"fixture-id-0"}, fixtureIds(store.fixturesByTeam("team-id-4")))
+		assert.Nil(t, store.fixture("fixture-id-3"))
+	})
+
+	t.Run("when a new index is built it should leave the previous one untouched", func(t *testing.T) {
+		// Arrange
+		store := setup()
+		previousIndex := store.loadIndex()
+
+		// Act
+		_ = previousIndex.withoutFixture(store.fixture("fixture-id-3"))
+
+		// Assert
+		assert.Equal(t, []string{"fixture-id-1", "fixture-id-2", "fixture-id-3"}, previousIndex.fixtureIds)
+		assert.Equal(t, 1, len(previousIndex.tournamentFixtureIds["tournament-id-2"]))
+		assert.Equal(t, 1, len(previousIndex.teamFixtureIds["team-id-4"]))
+	})
+
+	t.Run("when a fixture's teams and tournament change it should update the indexes", func(t *testing.T) {
+		// Arrange
+		store := setup()
+		entry := store.entry("fixture-id-1")
+		current := entry.load().fixture
+		updated := current.clone()
+		updated.insertTeam(fixtureTeam{Id: "team-id-0"})
+		updated.removeTeam("team-id-1")
+		updated.Tournament = fixtureTournament{Id: "tournament-id-2"}
+
+		// Act
+		entry.store(updated)
+		store.index.Store(store.loadIndex().withFixtureChanged(current, updated))
+
+		// Assert
+		assert.Equal(t, 1, store.findTeamIndex("fixture-id-1", "team-id-2"))
+		assert.Equal(t, -1, store.findTeamIndex("fixture-id-1", "team-id-1"))
+		assert.Equal(t, []string{"fixture-id-1"}, fixtureIds(store.fixturesByTeam("team-id-0")))
+		assert.Equal(t, []string{"fixture-id-2"}, fixtureIds(store.fixturesByTeam("team-id-1")))
+		assert.Equal(t, []string{"fixture-id-2"}, fixtureIds(store.fixturesByTournament("tournament-id-1")))
+		assert.Equal(t, []string{"fixture-id-1", "fixture-id-3"}, fixtureIds(store.fixturesByTournament("tournament-id-2")))
+	})
+
+	t.Run("when a fixture is updated readers should keep their snapshot", func(t *testing.T) {
+		// Arrange
+		store := setup()
+		entry := store.entry("fixture-id-1")
+		snapshot := store.fixture("fixture-id-1")
+		updated := snapshot.clone()
+		updated.Teams[0].Score = 3
+
+		// Act
+		entry.store(updated)
+
+		// Assert
+		assert.Equal(t, 0, snapshot.Teams[0].Score)
+		assert.Equal(t, 3, store.fixture("fixture-id-1").Teams[0].Score)
+	})
+}
+
+const benchmarkFixtureCount = 10000
+
+func benchmarkViewModel() *ViewModel {
+	viewModel := make(ViewModel, 0, benchmarkFixtureCount)
+	for i := 0; i < benchmarkFixtureCount; i++ {
+		viewModel = append(viewModel, fixture{
+			Id:         fmt.Sprintf("fixture-id-%05d", i),
+			Tournament: fixtureTournament{Id: fmt.Sprintf("tournament-id-%d", i%100)},
+			Teams: []fixtureTeam{
+				{Id: fmt.Sprintf("team-id-%05d", (i*2)%benchmarkFixtureCount)},
+				{Id: fmt.Sprintf("team-id-%05d", (i*2+1)%benchmarkFixtureCount)},
+			},
+		})
+	}
+	return &viewModel
+}
+
+// Previous approach: binary search over the sorted viewmodel, then over the fixture's teams
+func searchFixtureTeam(viewModel *ViewModel, fixtureId string, teamId string) *fixtureTeam {
+	fixtureIndex := sort.Search(len(*viewModel), func(i int) bool {
+		return (*viewModel)[i].Id >= fixtureId
+	})
+	if fixtureIndex >= len(*viewModel) || (*viewModel)[fixtureIndex].Id != fixtureId {
+		return nil
+	}
+
+	fixture := &(*viewModel)[fixtureIndex]
+	teamIndex := fixture.findTeamIndex(teamId)
+	if teamIndex < 0 {
+		return nil
+	}
+	return &fixture.Teams[teamIndex]
+}
+
+func BenchmarkFindFixtureTeam(b *testing.B) {
+	viewModel := benchmarkViewModel()
+	viewModel.Sort()
+	store := newViewModelStore(viewModel)
+
+	b.Run("sorted viewmodel with binary search", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fixture := (*viewModel)[i%benchmarkFixtureCount]
+			if searchFixtureTeam(viewModel, fixture.Id, fixture.Teams[1].Id) == nil {
+				b.Fatal("team not found")
+			}
+		}
+	})
+
+	b.Run("store with map indexes", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fixture := (*viewModel)[i%benchmarkFixtureCount]
+			if store.fixtureTeam(fixture.Id, fixture.Teams[1].Id) == nil {
+				b.Fatal("team not found")
+			}
+		}
+	})
+}
+
+func BenchmarkFixturesByTournament(b *testing.B) {
+	viewModel := benchmarkViewModel()
+	viewModel.Sort()
+	store := newViewModelStore(viewModel)
+
+	b.Run("sorted viewmodel with linear scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tournamentId := fmt.Sprintf("tournament-id-%d", i%100)
+			fixtures := make([]*fixture, 0)
+			for j := range *viewModel {
+				if (*viewModel)[j].Tournament.Id == tournamentId {
+					fixtures = append(fixtures, &(*viewModel)[j])
+				}
+			}
+		}
+	})
+
+	b.Run("store with map indexes", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			store.fixturesByTournament(fmt.Sprintf("tournament-id-%d", i%100))
+		}
+	})
+}
+
+func BenchmarkMarshalViewModel(b *testing.B) {
+	viewModel := benchmarkViewModel()
+	viewModel.Sort()
+	store := newViewModelStore(viewModel)
+
+	b.Run("sorted viewmodel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = json.Marshal(viewModel)
+		}
+	})
+
+	b.Run("store", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = json.Marshal(store.list())
+		}
+	})
+}
@@ -3,6 +3,7 @@ package internal
 import (
 	"testing"
 
+	"github.com/Zedronar/go-dummy-app.git/internal/data"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -118,4 +119,61 @@ func TestViewModel(t *testing.T) {
 			assert.Equal(t, "team-id-2", (*viewModel)[i].Teams[1].Id)
 		}
 	})
+
+	t.Run("when findTeamIndex is called it should return the team index", func(t *testing.T) {
+		// Arrange
+		viewModel := setup()
+		viewModel.Sort()
+
+		// Act
+		teamIndex := (*viewModel)[0].findTeamIndex("team-id-2")
+
+		// Assert
+		assert.Equal(t, 1, teamIndex)
+	})
+
+	t.Run("when findTeamIndex is called with invalid teamId it should return -1", func(t *testing.T) {
+		// Arrange
+		viewModel := setup()
+		viewModel.Sort()
+
+		// Act
+		teamIndex := (*viewModel)[0].findTeamIndex("invalid-team-id")
+
+		// Assert
+		assert.Equal(t, -1, teamIndex)
+	})
+
+	t.Run("when patchPath is called it should return an escaped JSON pointer", func(t *testing.T) {
+		// Act
+		path := patchPath("F/1~", "teams", "0", "score")
+
+		// Assert
+		assert.Equal(t, "/fixtures/F~11~0/teams/0/score", path)
+	})
+
+	t.Run("when newViewModel is called it should convert the provider fixtures", func(t *testing.T) {
+		// Arrange
+		fixtures := []data.Fixture{{
+			Id:                 "fixture-id",
+			Title:              "fixture-title",
+			Tournament:         data.FixtureTournament{Id: "tournament-id", Name: "tournament-name"},
+			Teams:              []data.FixtureTeam{{Id: "team-id-1", Name: "team-name-1", Score: 1}},
+			ScheduledStartTime: 100,
+			WinningTeamId:      "team-id-1",
+		}}
+
+		// Act
+		viewModel := newViewModel(fixtures)
+
+		// Assert
+		assert.Equal(t, &ViewModel{{
+			Id:                 "fixture-id",
+			Title:              "fixture-title",
+			Tournament:         fixtureTournament{Id: "tournament-id", Name: "tournament-name"},
+			Teams:              []fixtureTeam{{Id: "team-id-1", Name: "team-name-1", Score: 1}},
+			ScheduledStartTime: 100,
+			WinningTeamId:      "team-id-1",
+		}}, viewModel)
+	})
 }
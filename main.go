@@ -1,30 +1,117 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"time"
 
+	"github.com/Zedronar/go-dummy-app.git/external"
 	"github.com/Zedronar/go-dummy-app.git/external/metrics"
 	"github.com/Zedronar/go-dummy-app.git/internal"
+	"github.com/Zedronar/go-dummy-app.git/internal/data"
 )
 
 const (
 	metricNameServiceStarts = "service.starts"
+
+	commandReplayDeadLetters = "replay-dead-letters"
 )
 
 func main() {
-	internal.InitLiveServer()
+	publisherConfig, err := external.PublisherConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Usage: go-dummy-app replay-dead-letters
+	if len(os.Args) > 1 && os.Args[1] == commandReplayDeadLetters {
+		replayDeadLetters(publisherConfig)
+		return
+	}
+
+	publisher, err := external.NewPublisher(publisherConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Live scores are published to the bus by the test server, which also serves the static fixtures.
+	// Their decisions are drawn from SIMULATION_SEED and their clock starts at SIMULATION_START_TIME, so a
+	// run can be replayed.
+	simulationConfig, err := external.SimulationConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	bus := external.NewEventBus(external.DefaultEventBusQueueSize)
+	external.StartTestServer(bus, simulationConfig)
+
+	// Fixtures are read from FIXTURES_FILE (JSON or YAML) when set, from the static data server otherwise
+	dataProvider := data.NewHttpProvider(data.DefaultFixturesUrl)
+	if fixturesFile := os.Getenv("FIXTURES_FILE"); fixturesFile != "" {
+		dataProvider = data.NewFileProvider(fixturesFile)
+	}
+
+	// Fixtures are re-polled every FIXTURES_SYNC_INTERVAL, "0" disables it
+	syncInterval := internal.DefaultFixtureSyncInterval
+	if value := os.Getenv("FIXTURES_SYNC_INTERVAL"); value != "" {
+		if syncInterval, err = time.ParseDuration(value); err != nil {
+			log.Fatal(fmt.Sprintf("invalid FIXTURES_SYNC_INTERVAL '%s': %s", value, err.Error()))
+		}
+	}
+
+	// Fixture, team, winner, status and bracket changes require "Authorization: Bearer <ADMIN_TOKEN>",
+	// they're refused when it isn't set
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		log.Println("ADMIN_TOKEN is not set, the live data mutation API is disabled")
+	}
+
+	liveDataServer, liveDataHandler, err := internal.InitLiveServer(context.Background(), publisher, bus, dataProvider, syncInterval, adminToken)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Live data is served next to the static data, by the test server listening on :8080
+	http.Handle("/livedata", liveDataHandler)
+	http.Handle("/livedata/", liveDataHandler)
+	http.Handle("/tournaments/", liveDataHandler)
 
 	metrics.Increment(metricNameServiceStarts)
 
 	runService()
+
+	liveDataServer.Close()
+	bus.Close()
+
+	// Sinks may still have deliveries queued (webhooks) or connections open (redis)
+	if closer, ok := publisher.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Println(fmt.Sprintf("error closing publisher: %s", err.Error()))
+		}
+	}
 }
 
 func runService() {
-	osStopChannel := make(chan os.Signal)
+	osStopChannel := make(chan os.Signal, 1)
 	signal.Notify(osStopChannel, os.Interrupt)
 	log.Println("service started")
 	<-osStopChannel
 	log.Println("service stopping...")
 }
+
+// Re-deliver the webhooks in the dead-letter file (PUBLISHER_WEBHOOK_DEAD_LETTER_PATH)
+func replayDeadLetters(publisherConfig external.PublisherConfig) {
+	delivered, failed, err := external.ReplayWebhookDeadLetters(publisherConfig.WebhookConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println(fmt.Sprintf("replayed dead letters: %d delivered, %d still failing", delivered, failed))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}